@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	vd "github.com/bytedance/go-tagexpr/v2/validator"
+	"github.com/casbin/casbin/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/alopt/go-admin-core/logger"
@@ -15,6 +16,7 @@ import (
 	"github.com/alopt/go-admin-core/sdk/pkg/response"
 	"github.com/alopt/go-admin-core/sdk/service"
 	"github.com/alopt/go-admin-core/storage"
+	"github.com/alopt/go-admin-core/tools/database"
 	"github.com/alopt/go-admin-core/tools/language"
 	"gorm.io/gorm"
 )
@@ -104,18 +106,59 @@ func (e *Api) MakeOrm() *Api {
 		e.Logger.Error(http.StatusInternalServerError, err, "数据库连接获取失败")
 		e.AddError(err)
 	}
+	// 同一请求中写入之后的查询走主库
+	if db != nil {
+		db = db.WithContext(database.WithStickyPrimary(db.Statement.Context))
+	}
 	e.Orm = db
 	return e
 }
 
+// PrimaryOrm 查询都走主库的Orm, 用于对一致性要求高的读
+func (e Api) PrimaryOrm() *gorm.DB {
+	if e.Orm == nil {
+		return nil
+	}
+	return e.Orm.WithContext(database.WithPrimary(e.Orm.Statement.Context))
+}
+
 func (e *Api) MakeService(c *service.Service) *Api {
 	c.Log = e.Logger
 	c.Orm = e.Orm
-	c.Cache = sdk.Runtime.GetCacheAdapter()
+	c.Cache = e.GetCache()
 	e.Cache = c.Cache
 	return e
 }
 
+// GetTenant 获取租户中间件解析的租户
+func (e Api) GetTenant() string {
+	return pkg.GetTenant(e.Context)
+}
+
+// GetCache 获取当前租户的cache, 未经过租户中间件时为全局cache
+func (e Api) GetCache() storage.AdapterCache {
+	if cache, ok := pkg.GetCache(e.Context); ok {
+		return cache
+	}
+	return sdk.Runtime.GetCacheAdapter()
+}
+
+// GetQueue 获取当前租户的queue, 未经过租户中间件时为全局queue
+func (e Api) GetQueue() storage.AdapterQueue {
+	if queue, ok := pkg.GetQueue(e.Context); ok {
+		return queue
+	}
+	return sdk.Runtime.GetQueueAdapter()
+}
+
+// GetEnforcer 获取当前租户的casbin
+func (e Api) GetEnforcer() *casbin.SyncedEnforcer {
+	if enforcer, ok := pkg.GetCasbin(e.Context); ok {
+		return enforcer
+	}
+	return sdk.Runtime.GetCasbinKey(e.GetTenant())
+}
+
 // Error 通常错误数据处理
 func (e Api) Error(code int, err error, msg string) {
 	response.Error(e.Context, code, err, msg)
@@ -1,19 +1,36 @@
 package config
 
 import (
+	"time"
+
 	"github.com/alopt/go-admin-core/storage"
 	"github.com/alopt/go-admin-core/storage/cache"
 )
 
 type Cache struct {
 	Redis  *RedisConnectOptions
+	Tiered *CacheTiered
 	Memory interface{}
 }
 
+// CacheTiered 二级缓存配置, 需同时配置redis
+type CacheTiered struct {
+	// Channel 失效广播频道, 默认 /cache
+	Channel string
+	// Expire 本地缓存最长保留秒数, 默认60
+	Expire int
+	// MaxEntries 本地缓存最大key数量, 0不限制
+	MaxEntries int
+	// MaxBytes 本地缓存最大字节数, 0不限制
+	MaxBytes int64
+	// Eviction 本地缓存淘汰策略 lru / lfu
+	Eviction string
+}
+
 // CacheConfig cache配置
 var CacheConfig = new(Cache)
 
-// Setup 构造cache 顺序 redis > 其他 > memory
+// Setup 构造cache 顺序 tiered > redis > 其他 > memory
 func (e Cache) Setup() (storage.AdapterCache, error) {
 	if e.Redis != nil {
 		options, err := e.Redis.GetRedisOptions()
@@ -27,7 +44,23 @@ func (e Cache) Setup() (storage.AdapterCache, error) {
 		if _redis == nil {
 			_redis = r.GetClient()
 		}
+		if e.Tiered != nil {
+			return e.Tiered.Setup(r)
+		}
 		return r, nil
 	}
 	return cache.NewMemory(), nil
 }
+
+// Setup 构造二级缓存
+func (e CacheTiered) Setup(remote *cache.Redis) (storage.AdapterCache, error) {
+	opts := []cache.Option{
+		cache.WithName("tiered"),
+		cache.WithMaxEntries(e.MaxEntries),
+		cache.WithMaxBytes(e.MaxBytes),
+	}
+	if e.Eviction != "" {
+		opts = append(opts, cache.WithEviction(cache.Eviction(e.Eviction)))
+	}
+	return cache.NewTiered(cache.NewMemory(opts...), remote, e.Channel, time.Duration(e.Expire)*time.Second)
+}
@@ -1,5 +1,18 @@
 package config
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/alopt/go-admin-core/sdk"
+	"github.com/alopt/go-admin-core/tools/database"
+)
+
 type Database struct {
 	Driver          string
 	Source          string
@@ -11,13 +24,91 @@ type Database struct {
 }
 
 type DBResolverConfig struct {
+	// Name 分组名称, 通过dbresolver.Use(name)使用; 不配置Name和Tables的为全局分组
+	Name     string
 	Sources  []string
 	Replicas []string
-	Policy   string
-	Tables   []string
+	// Policy random、round_robin、weighted、least_latency
+	Policy string
+	Tables []string
+	// Weights weighted策略的权重, 与Sources、Replicas合并后的顺序对应, 如[source, replica1, replica2]
+	Weights []int
+	// HealthCheckInterval 健康检查间隔秒数, 默认10, 小于0不检查
+	HealthCheckInterval int
+	// HealthCheckFailures 连续失败多少次后剔除, 默认3
+	HealthCheckFailures int
+}
+
+// options 转为database的分组选项
+func (e *DBResolverConfig) options() []database.ResolverOption {
+	opts := []database.ResolverOption{
+		database.WithResolverName(e.Name),
+		database.WithWeights(e.Weights...),
+	}
+	if e.HealthCheckInterval != 0 || e.HealthCheckFailures != 0 {
+		interval := 10 * time.Second
+		if e.HealthCheckInterval != 0 {
+			interval = time.Duration(e.HealthCheckInterval) * time.Second
+		}
+		opts = append(opts, database.WithHealthCheck(interval, e.HealthCheckFailures))
+	}
+	return opts
 }
 
 var (
 	DatabaseConfig  = new(Database)
 	DatabasesConfig = make(map[string]*Database)
 )
+
+// Open 按Driver从database的driver注册表中选择gorm.Dialector打开db
+func (e *Database) Open(config *gorm.Config) (*gorm.DB, error) {
+	open, err := database.GetDriver(e.Driver)
+	if err != nil {
+		return nil, err
+	}
+	registers := make([]database.ResolverConfigure, len(e.Registers))
+	for i := range e.Registers {
+		registers[i] = database.NewResolverConfigure(
+			e.Registers[i].Sources,
+			e.Registers[i].Replicas,
+			e.Registers[i].Policy,
+			e.Registers[i].Tables,
+			e.Registers[i].options()...)
+	}
+	return database.NewConfigure(e.Source,
+		e.MaxIdleConns,
+		e.MaxOpenConns,
+		e.ConnMaxIdleTime,
+		e.ConnMaxLifeTime,
+		registers).Init(config, open)
+}
+
+// SetupDatabases 打开DatabasesConfig中的所有db并注册到sdk.Runtime, 未配置databases时为database("*")
+func SetupDatabases(config *gorm.Config) error {
+	keys := make([]string, 0, len(DatabasesConfig))
+	for key := range DatabasesConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		db, err := DatabasesConfig[key].Open(config)
+		if err != nil {
+			return fmt.Errorf("database %s: %w", key, err)
+		}
+		sdk.Runtime.SetDb(key, db)
+	}
+	return nil
+}
+
+// PingDatabases 检查sdk.Runtime中所有db的连接, 用于listener.NewReadyz:
+//
+//	listener.NewReadyz(listener.WithCheck("database", config.PingDatabases))
+func PingDatabases(ctx context.Context) error {
+	var errs []error
+	for key, db := range sdk.Runtime.GetDb() {
+		if err := database.Ping(db)(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alopt/go-admin-core/sdk"
+)
+
+func TestSetupDatabases(t *testing.T) {
+	dir := t.TempDir()
+	DatabasesConfig = map[string]*Database{
+		"a.example.com": {Driver: "sqlite3", Source: filepath.Join(dir, "a.db")},
+		"b.example.com": {Driver: "sqlite3", Source: filepath.Join(dir, "b.db"), MaxOpenConns: 1},
+	}
+	defer func() {
+		DatabasesConfig = make(map[string]*Database)
+	}()
+	if err := SetupDatabases(&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}); err != nil {
+		t.Fatalf("SetupDatabases() error = %v", err)
+	}
+	for key := range DatabasesConfig {
+		if sdk.Runtime.GetDbByKey(key) == nil {
+			t.Errorf("db %s not registered", key)
+		}
+	}
+	if err := PingDatabases(context.TODO()); err != nil {
+		t.Errorf("PingDatabases() error = %v", err)
+	}
+	sqlDB, _ := sdk.Runtime.GetDbByKey("b.example.com").DB()
+	_ = sqlDB.Close()
+	if err := PingDatabases(context.TODO()); err == nil {
+		t.Errorf("PingDatabases() after close error = nil")
+	}
+
+	DatabasesConfig = map[string]*Database{"c": {Driver: "oracle"}}
+	if err := SetupDatabases(&gorm.Config{}); err == nil {
+		t.Errorf("SetupDatabases() unsupported driver error = nil")
+	}
+}
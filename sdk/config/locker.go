@@ -1,23 +1,36 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/alopt/go-admin-core/storage"
 	"github.com/alopt/go-admin-core/storage/locker"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 )
 
 var LockerConfig = new(Locker)
 
 type Locker struct {
-	Redis *RedisConnectOptions
+	Redis    *RedisConnectOptions
+	Database *LockerDatabase
+}
+
+// LockerDatabase 数据库锁, 没有redis的部署使用
+type LockerDatabase struct {
+	// Source mysql连接串
+	Source string
+	// Mode row(默认): 基于数据表; get_lock: MySQL GET_LOCK, 每个锁占用一个连接
+	Mode string
 }
 
 // Empty 空设置
 func (e Locker) Empty() bool {
-	return e.Redis == nil
+	return e.Redis == nil && e.Database == nil
 }
 
-// Setup 启用顺序 redis > 其他 > memory
+// Setup 启用顺序 redis > database > memory
 func (e Locker) Setup() (storage.AdapterLocker, error) {
 	if e.Redis != nil {
 		client := GetRedisClient()
@@ -31,5 +44,27 @@ func (e Locker) Setup() (storage.AdapterLocker, error) {
 		}
 		return locker.NewRedis(client), nil
 	}
-	return nil, nil
+	if e.Database != nil {
+		return e.Database.Setup()
+	}
+	return locker.NewMemory(), nil
+}
+
+// Setup 打开数据库, row模式会创建锁的表
+func (e LockerDatabase) Setup() (storage.AdapterLocker, error) {
+	db, err := gorm.Open(mysql.Open(e.Source), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	switch e.Mode {
+	case "", "row":
+		if err = locker.Migrate(db); err != nil {
+			return nil, err
+		}
+		return locker.NewDatabase(db), nil
+	case "get_lock":
+		return locker.NewMySQL(db), nil
+	default:
+		return nil, fmt.Errorf("locker mode %s not support", e.Mode)
+	}
 }
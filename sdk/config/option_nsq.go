@@ -23,6 +23,9 @@ type NSQOptions struct {
 	// Addresses is the local address to use when dialing an nsqd.
 	Addresses []string `opt:"addresses"`
 
+	// LookupdAddresses nsqlookupd地址, 设置后消费者通过lookupd发现nsqd
+	LookupdAddresses []string `opt:"lookupd_addresses"`
+
 	// Duration between polling lookupd for new producers, and fractional jitter to add to
 	// the lookupd pool loop. this helps evenly distribute requests even if multiple consumers
 	// restart at the same time
@@ -13,6 +13,7 @@ type Queue struct {
 	Redis  *QueueRedis
 	Memory *QueueMemory
 	NSQ    *QueueNSQ `json:"nsq" yaml:"nsq"`
+	File   *QueueFile
 }
 
 type QueueRedis struct {
@@ -30,11 +31,38 @@ type QueueNSQ struct {
 	ChannelPrefix string
 }
 
+// QueueFile 本地文件队列, 单节点部署时替代redis/nsq
+type QueueFile struct {
+	// Path 数据目录
+	Path string
+	// SegmentSize 单个日志段的最大字节数, 默认64MB
+	SegmentSize int64
+	// Sync 落盘策略: always, interval(默认), never
+	Sync string
+	// SyncInterval 落盘间隔(秒), 默认1
+	SyncInterval int
+}
+
+// Setup 创建文件队列
+func (e QueueFile) Setup() (*queue.File, error) {
+	opts := make([]queue.FileOption, 0)
+	if e.SegmentSize > 0 {
+		opts = append(opts, queue.WithSegmentSize(e.SegmentSize))
+	}
+	if e.Sync != "" {
+		opts = append(opts, queue.WithFileSync(queue.FileSync(e.Sync)))
+	}
+	if e.SyncInterval > 0 {
+		opts = append(opts, queue.WithSyncInterval(time.Duration(e.SyncInterval)*time.Second))
+	}
+	return queue.NewFile(e.Path, opts...)
+}
+
 var QueueConfig = new(Queue)
 
 // Empty 空设置
 func (e Queue) Empty() bool {
-	return e.Memory == nil && e.Redis == nil && e.NSQ == nil
+	return e.Memory == nil && e.Redis == nil && e.NSQ == nil && e.File == nil
 }
 
 // Setup 启用顺序 redis > 其他 > memory
@@ -54,14 +82,31 @@ func (e Queue) Setup() (storage.AdapterQueue, error) {
 		}
 		e.Redis.Producer.RedisClient = client
 		e.Redis.Consumer.RedisClient = client
-		return queue.NewRedis(e.Redis.Producer, e.Redis.Consumer)
+		q, err := queue.NewRedis(e.Redis.Producer, e.Redis.Consumer)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
 	}
 	if e.NSQ != nil {
 		cfg, err := e.NSQ.GetNSQOptions()
 		if err != nil {
 			return nil, err
 		}
-		return queue.NewNSQ(e.NSQ.Addresses, cfg, e.NSQ.ChannelPrefix)
+		q, err := queue.NewNSQ(e.NSQ.Addresses, cfg, e.NSQ.ChannelPrefix,
+			queue.WithLookupdAddresses(e.NSQ.LookupdAddresses...))
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	}
+	// 具体类型的nil指针不能直接作为接口返回
+	if e.File != nil {
+		q, err := e.File.Setup()
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
 	}
 	return queue.NewMemory(e.Memory.PoolSize), nil
 }
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueue_Setup(t *testing.T) {
+	// 以文件占位, 使创建队列目录失败
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		queue Queue
+	}{
+		{name: "file", queue: Queue{File: &QueueFile{Path: path}}},
+		{name: "nsq", queue: Queue{NSQ: &QueueNSQ{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := tt.queue.Setup()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if q != nil {
+				t.Errorf("Setup() = %#v, want nil interface", q)
+			}
+		})
+	}
+}
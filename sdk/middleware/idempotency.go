@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/sdk/pkg/response"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端提交的幂等key
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader 重放的响应带有该header
+	IdempotencyReplayedHeader = "Idempotency-Replayed"
+
+	idempotencyKeyMaxLength = 255
+	// idempotencySaveTimeout 保存响应的超时, 不受客户端断开影响
+	idempotencySaveTimeout = 5 * time.Second
+)
+
+type idempotencyOptions struct {
+	prefix  string
+	ttl     time.Duration
+	lockTTL time.Duration
+	wait    time.Duration
+	scope   func(c *gin.Context) string
+}
+
+// IdempotencyOption 幂等中间件选项
+type IdempotencyOption func(*idempotencyOptions)
+
+// WithIdempotencyPrefix 缓存与锁key的前缀, 默认idempotency
+func WithIdempotencyPrefix(prefix string) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithIdempotencyTTL 响应保存时长, 默认24h
+func WithIdempotencyTTL(ttl time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithIdempotencyLockTTL 处理中锁的有效期, 持有期间自动续期, 默认30s
+func WithIdempotencyLockTTL(ttl time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.lockTTL = ttl
+	}
+}
+
+// WithIdempotencyWait 重复请求等待首个请求完成的最长时间, 超时返回409, 默认10s
+func WithIdempotencyWait(wait time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.wait = wait
+	}
+}
+
+// WithIdempotencyScope key的作用域, 默认按jwt用户标识(未登录按ip), 不同作用域的相同key互不影响
+func WithIdempotencyScope(scope func(c *gin.Context) string) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.scope = scope
+	}
+}
+
+// idempotencyRecord 保存的首次响应
+type idempotencyRecord struct {
+	// Status http状态码
+	Status int `json:"status"`
+	// Code response设置的status
+	Code   int             `json:"code"`
+	Result json.RawMessage `json:"result"`
+	// Hash 请求体的sha256, 相同key不同请求体时拒绝重放
+	Hash string `json:"hash"`
+}
+
+// Idempotency 对带Idempotency-Key的POST/PUT/PATCH请求保存首次响应(response包设置的result/status), 重复请求直接重放;
+// 并发的重复请求通过locker等待首个请求完成. 返回5xx或未使用response包的响应不保存, 可以重试;
+// 相同key但请求体不同时返回422
+func Idempotency(cache storage.AdapterCache, locker storage.AdapterLocker, opts ...IdempotencyOption) gin.HandlerFunc {
+	o := &idempotencyOptions{
+		prefix:  "idempotency",
+		ttl:     24 * time.Hour,
+		lockTTL: 30 * time.Second,
+		wait:    10 * time.Second,
+		scope:   KeyByIdentity,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > idempotencyKeyMaxLength {
+			response.Error(c, http.StatusBadRequest, nil, "Idempotency-Key too long")
+			return
+		}
+		key := o.prefix + ":" + o.scope(c) + ":" + KeyByRoute(c) + ":" + idempotencyKey
+		ctx := c.Request.Context()
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, err, "read request body error")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		if replay(c, cache, key, hash) {
+			return
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, o.wait)
+		lock, err := locker.LockCtx(waitCtx, key+":lock",
+			storage.WithLockTTL(o.lockTTL),
+			storage.WithLockWatchdog(nil))
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, storage.ErrNotObtained) {
+				response.Error(c, http.StatusConflict, nil, "request with the same Idempotency-Key is in progress")
+				return
+			}
+			if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+				// 客户端已断开
+				c.Abort()
+				return
+			}
+			// 无法保证只执行一次时拒绝请求
+			log.Errorf("idempotency lock %s error, %s", key, err.Error())
+			response.Error(c, http.StatusServiceUnavailable, err, "idempotency lock unavailable")
+			return
+		}
+		defer func() {
+			if err := lock.Release(context.Background()); err != nil {
+				log.Errorf("idempotency release %s error, %s", key, err.Error())
+			}
+		}()
+		// 等待期间首个请求可能已经完成
+		if replay(c, cache, key, hash) {
+			return
+		}
+
+		c.Next()
+
+		result, ok := c.Get("result")
+		if !ok {
+			return
+		}
+		code := c.GetInt("status")
+		if code >= http.StatusInternalServerError || c.Writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		rb, err := json.Marshal(result)
+		if err != nil {
+			log.Errorf("idempotency marshal %s error, %s", key, err.Error())
+			return
+		}
+		rb, err = json.Marshal(&idempotencyRecord{
+			Status: c.Writer.Status(),
+			Code:   code,
+			Result: rb,
+			Hash:   hash,
+		})
+		if err == nil {
+			// 客户端超时或断开后会重试, 此时请求的ctx已取消, 仍需保存
+			saveCtx, cancel := context.WithTimeout(context.Background(), idempotencySaveTimeout)
+			err = cache.SetCtx(saveCtx, key, string(rb), o.ttl)
+			cancel()
+		}
+		if err != nil {
+			log.Errorf("idempotency save %s error, %s", key, err.Error())
+		}
+	}
+}
+
+// replay 存在已保存的响应时重放并返回true, 请求体与保存时不同时返回422
+func replay(c *gin.Context, cache storage.AdapterCache, key, hash string) bool {
+	val, err := cache.GetCtx(c.Request.Context(), key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Errorf("idempotency get %s error, %s", key, err.Error())
+		}
+		return false
+	}
+	record := &idempotencyRecord{}
+	if err = json.Unmarshal([]byte(val), record); err != nil {
+		log.Errorf("idempotency unmarshal %s error, %s", key, err.Error())
+		return false
+	}
+	if record.Hash != hash {
+		response.Error(c, http.StatusUnprocessableEntity, nil, "Idempotency-Key was used with a different request body")
+		return true
+	}
+	c.Set("result", record.Result)
+	c.Set("status", record.Code)
+	c.Header(IdempotencyReplayedHeader, "true")
+	c.Data(record.Status, "application/json; charset=utf-8", record.Result)
+	c.Abort()
+	return true
+}
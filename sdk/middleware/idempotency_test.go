@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alopt/go-admin-core/sdk/pkg/response"
+	"github.com/alopt/go-admin-core/storage"
+	"github.com/alopt/go-admin-core/storage/cache"
+	"github.com/alopt/go-admin-core/storage/locker"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var created int32
+	r := gin.New()
+	r.Use(Idempotency(cache.NewMemory(), locker.NewMemory()))
+	r.POST("/users", func(c *gin.Context) {
+		id := atomic.AddInt32(&created, 1)
+		time.Sleep(20 * time.Millisecond)
+		response.OK(c, id, "created")
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		atomic.AddInt32(&created, 1)
+		response.Error(c, http.StatusInternalServerError, nil, "failed")
+	})
+	do := func(path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 并发的重复请求只执行一次
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = do("/users", "a").Body.String()
+		}(i)
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Fatalf("created = %d, want 1", created)
+	}
+	for i := range bodies {
+		if bodies[i] != bodies[0] {
+			t.Errorf("body %d = %s, want %s", i, bodies[i], bodies[0])
+		}
+	}
+	if w := do("/users", "a"); w.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Errorf("repeat was not replayed")
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		key     string
+		created int32
+	}{
+		{"other key", "/users", "b", 2},
+		{"without key", "/users", "", 3},
+		{"without key again", "/users", "", 4},
+		{"server error", "/fail", "c", 5},
+		{"server error is not saved", "/fail", "c", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			do(tt.path, tt.key)
+			if created != tt.created {
+				t.Errorf("created = %d, want %d", created, tt.created)
+			}
+		})
+	}
+}
+
+// brokenLocker 模拟redis不可用
+type brokenLocker struct{}
+
+func (brokenLocker) String() string {
+	return "broken"
+}
+
+func (brokenLocker) Lock(string, int64, ...storage.LockOption) (storage.Lock, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (brokenLocker) LockCtx(context.Context, string, ...storage.LockOption) (storage.Lock, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestIdempotency_LockError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var created int32
+	r := gin.New()
+	r.Use(Idempotency(cache.NewMemory(), brokenLocker{}))
+	r.POST("/users", func(c *gin.Context) {
+		atomic.AddInt32(&created, 1)
+		response.OK(c, nil, "created")
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set(IdempotencyKeyHeader, "a")
+	r.ServeHTTP(w, req)
+	if created != 0 {
+		t.Errorf("created = %d, handler should not run without the lock", created)
+	}
+	if !strings.Contains(w.Body.String(), `"code":503`) {
+		t.Errorf("body = %s, want code 503", w.Body.String())
+	}
+}
+
+// cancelKey 请求ctx中保存cancel, 模拟客户端在处理完成前断开
+type cancelKey struct{}
+
+func TestIdempotency_Canceled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var created int32
+	r := gin.New()
+	r.Use(Idempotency(cache.NewMemory(), locker.NewMemory()))
+	r.POST("/users", func(c *gin.Context) {
+		atomic.AddInt32(&created, 1)
+		if cancel, ok := c.Request.Context().Value(cancelKey{}).(context.CancelFunc); ok {
+			cancel()
+		}
+		response.OK(c, nil, "created")
+	})
+	do := func(body string, cancel bool) *httptest.ResponseRecorder {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		if cancel {
+			ctx = context.WithValue(ctx, cancelKey{}, stop)
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)).WithContext(ctx)
+		req.Header.Set(IdempotencyKeyHeader, "a")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		cancel   bool
+		code     string
+		replayed bool
+	}{
+		{"canceled", `{"name":"a"}`, true, `"code":200`, false},
+		{"retry", `{"name":"a"}`, false, `"code":200`, true},
+		{"different body", `{"name":"b"}`, false, `"code":422`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.body, tt.cancel)
+			if !strings.Contains(w.Body.String(), tt.code) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.code)
+			}
+			if replayed := w.Header().Get(IdempotencyReplayedHeader) == "true"; replayed != tt.replayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replayed)
+			}
+			if created != 1 {
+				t.Errorf("created = %d, want 1", created)
+			}
+		})
+	}
+}
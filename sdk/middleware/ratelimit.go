@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/sdk/pkg/jwtauth"
+	"github.com/alopt/go-admin-core/sdk/pkg/response"
+	"github.com/alopt/go-admin-core/storage/ratelimit"
+)
+
+// RateLimitKeyFunc 从请求中取得限流的key, 返回空字符串时不限流
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// KeyByIP 按客户端ip限流
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByIdentity 按jwt中的用户标识限流, 未登录时按ip
+func KeyByIdentity(c *gin.Context) string {
+	if identity, ok := jwtauth.ExtractClaims(c)[jwtauth.IdentityKey]; ok && identity != nil {
+		return fmt.Sprintf("identity:%v", identity)
+	}
+	return KeyByIP(c)
+}
+
+// KeyByRoute 按路由限流, 所有客户端共享
+func KeyByRoute(c *gin.Context) string {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+	return "route:" + c.Request.Method + ":" + path
+}
+
+// KeyByTenant 按租户(host)限流
+func KeyByTenant(c *gin.Context) string {
+	return "tenant:" + c.Request.Host
+}
+
+// KeyJoin 组合多个key, 如每个用户在每个路由上单独限流; 任一为空时不限流
+func KeyJoin(keys ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(c *gin.Context) string {
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			part := key(c)
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// RateLimit 限流中间件, 超出时返回429并设置Retry-After; 限流器出错时放行
+func RateLimit(limiter ratelimit.Limiter, limit ratelimit.Limit, key RateLimitKeyFunc) gin.HandlerFunc {
+	if key == nil {
+		key = KeyByIP
+	}
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+		result, err := limiter.Allow(c.Request.Context(), k, limit, 1)
+		if err != nil {
+			log.Errorf("ratelimit %s error, %s", k, err.Error())
+			c.Next()
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Capacity()))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", seconds(result.ResetAfter))
+		if !result.Allowed {
+			c.Header("Retry-After", seconds(result.RetryAfter))
+			response.Error(c, http.StatusTooManyRequests, nil, "too many requests")
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds 向上取整的秒数
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alopt/go-admin-core/sdk/pkg/jwtauth"
+	"github.com/alopt/go-admin-core/storage/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set(jwtauth.JwtPayloadKey, jwtauth.MapClaims{jwtauth.IdentityKey: user})
+		}
+	})
+	r.GET("/ping", RateLimit(ratelimit.NewMemory(), ratelimit.PerMinute(2), KeyByIdentity), func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	tests := []struct {
+		user  string
+		limit bool
+	}{
+		{"1", false},
+		{"1", false},
+		{"1", true},
+		{"2", false},
+		{"", false},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set("X-User", tt.user)
+		r.ServeHTTP(w, req)
+		limited := w.Header().Get("Retry-After") != ""
+		if limited != tt.limit {
+			t.Errorf("request %d limited = %v, want %v", i, limited, tt.limit)
+		}
+		if limited && w.Body.String() == "pong" {
+			t.Errorf("request %d reached handler", i)
+		}
+		if w.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("request %d X-RateLimit-Limit = %s", i, w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alopt/go-admin-core/sdk"
+	"github.com/alopt/go-admin-core/sdk/pkg"
+	"github.com/alopt/go-admin-core/sdk/pkg/jwtauth"
+	"github.com/alopt/go-admin-core/sdk/pkg/response"
+	"github.com/alopt/go-admin-core/sdk/runtime"
+)
+
+// TenantResolver 从请求中解析租户, 返回空字符串表示未解析到
+type TenantResolver func(c *gin.Context) string
+
+// TenantByHost 按host(含端口)解析, 与go-admin按host配置多库一致
+func TenantByHost() TenantResolver {
+	return func(c *gin.Context) string {
+		return c.Request.Host
+	}
+}
+
+// TenantByHeader 按请求头解析, 如X-Tenant-Id
+func TenantByHeader(name string) TenantResolver {
+	return func(c *gin.Context) string {
+		return c.GetHeader(name)
+	}
+}
+
+// TenantBySubdomain 按domain的子域名解析, 如a.example.com解析为a
+func TenantBySubdomain(domain string) TenantResolver {
+	suffix := "." + strings.TrimPrefix(domain, ".")
+	return func(c *gin.Context) string {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !strings.HasSuffix(host, suffix) {
+			return ""
+		}
+		return strings.TrimSuffix(host, suffix)
+	}
+}
+
+// TenantByClaim 按jwt中的claim解析, 需要在jwt中间件之后使用
+func TenantByClaim(key string) TenantResolver {
+	return func(c *gin.Context) string {
+		if v, ok := jwtauth.ExtractClaims(c)[key].(string); ok {
+			return v
+		}
+		return ""
+	}
+}
+
+// TenantFirst 依次尝试, 返回第一个解析到的租户
+func TenantFirst(resolvers ...TenantResolver) TenantResolver {
+	return func(c *gin.Context) string {
+		for _, resolver := range resolvers {
+			if tenant := resolver(c); tenant != "" {
+				return tenant
+			}
+		}
+		return ""
+	}
+}
+
+type tenantOptions struct {
+	runtime  runtime.Runtime
+	resolver TenantResolver
+	fallback string
+}
+
+// TenantOption 租户中间件选项
+type TenantOption func(*tenantOptions)
+
+// WithTenantRuntime 使用的runtime, 默认sdk.Runtime
+func WithTenantRuntime(rt runtime.Runtime) TenantOption {
+	return func(o *tenantOptions) {
+		o.runtime = rt
+	}
+}
+
+// WithTenantResolver 租户解析方式, 默认TenantByHost
+func WithTenantResolver(resolver TenantResolver) TenantOption {
+	return func(o *tenantOptions) {
+		o.resolver = resolver
+	}
+}
+
+// WithTenantFallback 未解析到租户时使用的租户
+func WithTenantFallback(tenant string) TenantOption {
+	return func(o *tenantOptions) {
+		o.fallback = tenant
+	}
+}
+
+// Tenant 解析租户并将对应的db、带租户前缀的cache和queue、casbin写入gin上下文,
+// 通过pkg.GetOrm、api.Api获取; 租户没有对应的db时返回404, 不使用"*"的db,
+// 租户没有对应的casbin时使用"*"的casbin
+func Tenant(opts ...TenantOption) gin.HandlerFunc {
+	o := &tenantOptions{
+		runtime:  sdk.Runtime,
+		resolver: TenantByHost(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		tenant := o.resolver(c)
+		if tenant == "" {
+			tenant = o.fallback
+		}
+		db, ok := o.runtime.LookupDb(tenant)
+		if !ok || db == nil {
+			response.Error(c, http.StatusNotFound, nil, "tenant not found")
+			return
+		}
+		c.Set(pkg.TenantKey, tenant)
+		c.Set("db", db.WithContext(c.Request.Context()))
+		c.Set(pkg.CacheKey, o.runtime.GetCachePrefix(tenant))
+		c.Set(pkg.QueueKey, o.runtime.GetQueuePrefix(tenant))
+		if enforcer := o.runtime.GetCasbinKey(tenant); enforcer != nil {
+			c.Set(pkg.CasbinKey, enforcer)
+		}
+		c.Next()
+	}
+}
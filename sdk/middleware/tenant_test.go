@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alopt/go-admin-core/sdk/pkg"
+	"github.com/alopt/go-admin-core/sdk/runtime"
+	"github.com/alopt/go-admin-core/storage/cache"
+)
+
+func TestTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rt := runtime.NewConfig()
+	rt.SetCacheAdapter(cache.NewMemory())
+	for _, tenant := range []string{"*", "a", "b"} {
+		db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlDB, _ := db.DB()
+		sqlDB.SetMaxOpenConns(1)
+		db.Exec("create table tenant (name text)")
+		db.Exec("insert into tenant values (?)", tenant)
+		rt.SetDb(tenant, db)
+		enforcer, err := casbin.NewSyncedEnforcer(casbinModel(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rt.SetCasbin(tenant, enforcer)
+	}
+	tests := []struct {
+		name     string
+		resolver TenantResolver
+		host     string
+		header   string
+		want     string
+		code     int
+	}{
+		{"subdomain", TenantBySubdomain("example.com"), "a.example.com:8000", "", "a", http.StatusOK},
+		{"header", TenantByHeader("X-Tenant-Id"), "example.com", "b", "b", http.StatusOK},
+		{"first", TenantFirst(TenantByHeader("X-Tenant-Id"), TenantBySubdomain("example.com")), "a.example.com", "", "a", http.StatusOK},
+		{"unknown", TenantBySubdomain("example.com"), "c.example.com", "", "", http.StatusNotFound},
+		{"empty", TenantByHeader("X-Tenant-Id"), "example.com", "", "", http.StatusNotFound},
+		{"other domain", TenantBySubdomain("example.com"), "a.example.org", "", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			var tenant, name string
+			var enforcer *casbin.SyncedEnforcer
+			r.GET("/", Tenant(WithTenantRuntime(rt), WithTenantResolver(tt.resolver)), func(c *gin.Context) {
+				tenant = pkg.GetTenant(c)
+				enforcer, _ = pkg.GetCasbin(c)
+				db, err := pkg.GetOrm(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				db.Raw("select name from tenant").Scan(&name)
+				cache, ok := pkg.GetCache(c)
+				if !ok {
+					t.Fatal("cache not set")
+				}
+				if err = cache.Set("k", tenant, 60); err != nil {
+					t.Fatal(err)
+				}
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			req.Header.Set("X-Tenant-Id", tt.header)
+			r.ServeHTTP(w, req)
+			if tt.code == http.StatusNotFound {
+				if tenant != "" || !strings.Contains(w.Body.String(), `"code":404`) {
+					t.Errorf("tenant = %s, body = %s, want rejected", tenant, w.Body.String())
+				}
+				return
+			}
+			if tenant != tt.want {
+				t.Errorf("tenant = %s, want %s", tenant, tt.want)
+			}
+			if name != tt.want {
+				t.Errorf("db = %s, want %s", name, tt.want)
+			}
+			if enforcer != rt.GetCasbinKey(tt.want) || enforcer == rt.GetCasbinKey("*") {
+				t.Errorf("casbin of %s not used", tt.want)
+			}
+			if v, _ := rt.GetCachePrefix(tt.want).Get("k"); v != tt.want {
+				t.Errorf("cache = %s, want %s", v, tt.want)
+			}
+		})
+	}
+}
+
+func casbinModel(t *testing.T) model.Model {
+	m, err := model.NewModelFromString(`
+[request_definition]
+r = sub, obj, act
+[policy_definition]
+p = sub, obj, act
+[policy_effect]
+e = some(where (p.eft == allow))
+[matchers]
+m = r.sub == p.sub && r.obj == p.obj && r.act == p.act
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/log"
 	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
 	"github.com/alopt/go-admin-core/logger"
 	"github.com/alopt/go-admin-core/sdk"
 	"github.com/alopt/go-admin-core/sdk/config"
@@ -38,43 +39,14 @@ var (
 
 func Setup(db *gorm.DB, _ string) *casbin.SyncedEnforcer {
 	once.Do(func() {
-		Apter, err := gormAdapter.NewAdapterByDBUseTableName(db, "", "casbin_rule")
-		if err != nil && err.Error() != "invalid DDL" {
-			panic(err)
-		}
-
-		m, err := model.NewModelFromString(text)
-		if err != nil {
-			panic(err)
-		}
-		enforcer, err = casbin.NewSyncedEnforcer(m, Apter)
-		if err != nil {
-			panic(err)
-		}
-		err = enforcer.LoadPolicy()
+		var err error
+		enforcer, err = newEnforcer(db)
 		if err != nil {
 			panic(err)
 		}
 		// set redis watcher if redis config is not nil
 		if config.CacheConfig.Redis != nil {
-			w, err := redisWatcher.NewWatcher(config.CacheConfig.Redis.Addr, redisWatcher.WatcherOptions{
-				Options: redis.Options{
-					Network:  "tcp",
-					Password: config.CacheConfig.Redis.Password,
-				},
-				Channel:    "/casbin",
-				IgnoreSelf: false,
-			})
-			if err != nil {
-				panic(err)
-			}
-
-			err = w.SetUpdateCallback(updateCallback)
-			if err != nil {
-				panic(err)
-			}
-			err = enforcer.SetWatcher(w)
-			if err != nil {
+			if _, err = watch(enforcer, "/casbin", updateCallback); err != nil {
 				panic(err)
 			}
 		}
@@ -86,6 +58,75 @@ func Setup(db *gorm.DB, _ string) *casbin.SyncedEnforcer {
 	return enforcer
 }
 
+// New 为租户创建独立的casbin, 配置了redis时通过/casbin/{tenant}同步策略, 返回的close停止同步
+func New(tenant string, db *gorm.DB) (*casbin.SyncedEnforcer, func(), error) {
+	e, err := newEnforcer(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	closer := func() {}
+	if config.CacheConfig.Redis != nil {
+		w, err := watch(e, "/casbin/"+tenant, func(msg string) {
+			l := logger.NewHelper(sdk.Runtime.GetLogger())
+			l.Infof("casbin %s updateCallback msg: %v", tenant, msg)
+			if err := e.LoadPolicy(); err != nil {
+				l.Errorf("casbin %s LoadPolicy err: %v", tenant, err)
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		closer = w.Close
+	}
+	e.EnableLog(true)
+	return e, closer, nil
+}
+
+func newEnforcer(db *gorm.DB) (*casbin.SyncedEnforcer, error) {
+	Apter, err := gormAdapter.NewAdapterByDBUseTableName(db, "", "casbin_rule")
+	if err != nil && err.Error() != "invalid DDL" {
+		return nil, err
+	}
+
+	m, err := model.NewModelFromString(text)
+	if err != nil {
+		return nil, err
+	}
+	e, err := casbin.NewSyncedEnforcer(m, Apter)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.LoadPolicy(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// watch 通过redis在channel上同步策略变更
+func watch(e *casbin.SyncedEnforcer, channel string, callback func(string)) (persist.Watcher, error) {
+	w, err := redisWatcher.NewWatcher(config.CacheConfig.Redis.Addr, redisWatcher.WatcherOptions{
+		Options: redis.Options{
+			Network:  "tcp",
+			Password: config.CacheConfig.Redis.Password,
+		},
+		Channel:    channel,
+		IgnoreSelf: false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 设置失败时关闭watcher, 避免遗留redis订阅
+	if err = w.SetUpdateCallback(callback); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err = e.SetWatcher(w); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
 func updateCallback(msg string) {
 	l := logger.NewHelper(sdk.Runtime.GetLogger())
 	l.Infof("casbin updateCallback msg: %v", msg)
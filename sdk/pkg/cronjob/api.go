@@ -0,0 +1,50 @@
+package cronjob
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+
+	"github.com/alopt/go-admin-core/sdk/pkg/response"
+)
+
+// Routers 注册任务管理接口
+//
+//	GET  /jobs                列表
+//	GET  /jobs/:name/runs     执行记录, ?limit=20
+//	POST /jobs/:name/pause    暂停
+//	POST /jobs/:name/resume   恢复
+//	POST /jobs/:name/trigger  立即执行
+func (e *Scheduler) Routers(r *gin.RouterGroup, handlers ...gin.HandlerFunc) {
+	g := r.Group("/jobs", handlers...)
+	g.GET("", func(c *gin.Context) {
+		response.OK(c, e.Jobs(), "")
+	})
+	g.GET("/:name/runs", func(c *gin.Context) {
+		list, err := e.Runs(c.Param("name"), cast.ToInt(c.Query("limit")))
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, err, "")
+			return
+		}
+		response.OK(c, list, "")
+	})
+	g.POST("/:name/pause", e.action(e.Pause))
+	g.POST("/:name/resume", e.action(e.Resume))
+	g.POST("/:name/trigger", e.action(e.Trigger))
+}
+
+func (e *Scheduler) action(f func(name string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := f(c.Param("name")); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrJobNotFound) {
+				code = http.StatusNotFound
+			}
+			response.Error(c, code, err, "")
+			return
+		}
+		response.OK(c, nil, "")
+	}
+}
@@ -0,0 +1,76 @@
+package cronjob
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics 任务指标, Scheduler实现了prometheus.Collector, 注册后即可采集
+type metrics struct {
+	runs        *prometheus.CounterVec
+	skipped     *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	running     *prometheus.GaugeVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cronjob",
+			Name:      "runs_total",
+			Help:      "Number of job runs by status.",
+		}, []string{"job", "trigger", "status"}),
+		skipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cronjob",
+			Name:      "skipped_total",
+			Help:      "Number of scheduled ticks skipped by reason.",
+		}, []string{"job", "reason"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cronjob",
+			Name:      "duration_seconds",
+			Help:      "Job run duration in seconds.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"job"}),
+		running: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cronjob",
+			Name:      "running",
+			Help:      "Number of job runs in progress on this node.",
+		}, []string{"job"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "cronjob",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last successful run on this node.",
+		}, []string{"job"}),
+	}
+}
+
+func (m *metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.runs, m.skipped, m.duration, m.running, m.lastSuccess}
+}
+
+func (m *metrics) observe(name, trigger string, start time.Time, err error) {
+	status := StatusSuccess
+	if err != nil {
+		status = StatusFailed
+	} else {
+		m.lastSuccess.WithLabelValues(name).Set(float64(time.Now().Unix()))
+	}
+	m.runs.WithLabelValues(name, trigger, status).Inc()
+	m.duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
+
+// Describe 实现prometheus.Collector
+func (e *Scheduler) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range e.metrics.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect 实现prometheus.Collector
+func (e *Scheduler) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range e.metrics.collectors() {
+		c.Collect(ch)
+	}
+}
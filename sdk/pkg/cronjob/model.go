@@ -0,0 +1,50 @@
+package cronjob
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TriggerSchedule 按计划触发
+	TriggerSchedule = "schedule"
+	// TriggerManual 手动触发
+	TriggerManual = "manual"
+
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// JobState 任务状态, 集群共享
+type JobState struct {
+	Name      string `gorm:"primaryKey;size:128"`
+	Paused    bool   `gorm:"not null;default:false"`
+	UpdatedAt time.Time
+}
+
+func (JobState) TableName() string {
+	return "sys_cron_job_state"
+}
+
+// JobRun 任务执行记录
+type JobRun struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string     `gorm:"size:128;index:idx_cron_job_run_name,priority:1" json:"name"`
+	Trigger   string     `gorm:"size:16" json:"trigger"`
+	Node      string     `gorm:"size:128" json:"node"`
+	Status    string     `gorm:"size:16" json:"status"`
+	Error     string     `gorm:"type:text" json:"error"`
+	StartedAt time.Time  `gorm:"index:idx_cron_job_run_name,priority:2" json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt"`
+}
+
+func (JobRun) TableName() string {
+	return "sys_cron_job_run"
+}
+
+// Migrate 创建任务状态和执行记录表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&JobState{}, &JobRun{})
+}
@@ -0,0 +1,74 @@
+package cronjob
+
+import (
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// Option 调度器参数设置类型
+type Option func(*options)
+
+type options struct {
+	locker    storage.AdapterLocker
+	db        *gorm.DB
+	keyPrefix string
+	lockTTL   time.Duration
+	skew      time.Duration
+	node      string
+}
+
+func setDefaultOptions() options {
+	node, _ := os.Hostname()
+	return options{
+		keyPrefix: "cronjob",
+		lockTTL:   time.Minute,
+		skew:      time.Second,
+		node:      node,
+	}
+}
+
+// WithLocker 设置分布式锁, 每次触发先加锁, 多个实例中只有一个执行; 不设置时每个实例都执行
+func WithLocker(locker storage.AdapterLocker) Option {
+	return func(o *options) {
+		o.locker = locker
+	}
+}
+
+// WithDB 设置数据库, 用于保存暂停状态(集群共享)和执行记录, 需要先调用Migrate
+func WithDB(db *gorm.DB) Option {
+	return func(o *options) {
+		o.db = db
+	}
+}
+
+// WithKeyPrefix 设置锁的key前缀
+func WithKeyPrefix(prefix string) Option {
+	return func(o *options) {
+		o.keyPrefix = prefix
+	}
+}
+
+// WithLockTTL 设置锁的有效期, 需要大于实例之间的时钟误差, 不影响任务执行时长
+func WithLockTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.lockTTL = ttl
+	}
+}
+
+// WithSkew 设置允许的时钟误差, 误差内的实例对同一次触发使用相同的锁, 需要小于任务的最小间隔
+func WithSkew(skew time.Duration) Option {
+	return func(o *options) {
+		o.skew = skew
+	}
+}
+
+// WithNode 设置执行记录中的节点名称, 默认hostname
+func WithNode(node string) Option {
+	return func(o *options) {
+		o.node = node
+	}
+}
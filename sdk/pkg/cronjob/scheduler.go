@@ -0,0 +1,373 @@
+package cronjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm/clause"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/server"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+var (
+	// ErrJobNotFound 任务不存在
+	ErrJobNotFound = errors.New("cronjob: job not found")
+	// ErrJobExists 任务名称重复
+	ErrJobExists = errors.New("cronjob: job already exists")
+
+	_ server.Runnable = (*Scheduler)(nil)
+)
+
+// JobFunc 任务, ctx在调度器停止时取消
+type JobFunc func(ctx context.Context) error
+
+// JobInfo 任务信息, 计数为本节点的统计
+type JobInfo struct {
+	Name     string    `json:"name"`
+	Spec     string    `json:"spec"`
+	Paused   bool      `json:"paused"`
+	Running  int       `json:"running"`
+	Runs     int64     `json:"runs"`
+	Failures int64     `json:"failures"`
+	Prev     time.Time `json:"prev"`
+	Next     time.Time `json:"next"`
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	f        JobFunc
+	entry    cron.EntryID
+	paused   bool
+	running  int
+	runs     int64
+	failures int64
+}
+
+// NewScheduler 分布式调度器, 设置WithLocker后每次触发在集群中只执行一次
+func NewScheduler(opts ...Option) *Scheduler {
+	e := &Scheduler{
+		parser: cron.NewParser(cron.Second | cron.Minute |
+			cron.Hour | cron.Dom | cron.Month | cron.DowOptional | cron.Descriptor),
+		opts:    setDefaultOptions(),
+		jobs:    make(map[string]*job),
+		metrics: newMetrics(),
+	}
+	for _, o := range opts {
+		o(&e.opts)
+	}
+	e.cron = cron.New(cron.WithParser(e.parser), cron.WithChain())
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	return e
+}
+
+// Scheduler 分布式任务调度
+type Scheduler struct {
+	cron    *cron.Cron
+	parser  cron.Parser
+	opts    options
+	jobs    map[string]*job
+	metrics *metrics
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wait    sync.WaitGroup
+	started bool
+	stopped bool
+	mux     sync.Mutex
+}
+
+// AddJob 添加任务, spec支持秒
+func (e *Scheduler) AddJob(name, spec string, f JobFunc) error {
+	schedule, err := e.parser.Parse(spec)
+	if err != nil {
+		return err
+	}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if _, ok := e.jobs[name]; ok {
+		return ErrJobExists
+	}
+	j := &job{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		f:        f,
+	}
+	j.entry = e.cron.Schedule(schedule, cron.FuncJob(func() {
+		e.tick(j)
+	}))
+	e.jobs[name] = j
+	return nil
+}
+
+// RemoveJob 删除任务
+func (e *Scheduler) RemoveJob(name string) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if j, ok := e.jobs[name]; ok {
+		e.cron.Remove(j.entry)
+		delete(e.jobs, name)
+	}
+}
+
+func (e *Scheduler) getJob(name string) (*job, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	j, ok := e.jobs[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return j, nil
+}
+
+// tick 按计划触发, 取得本次触发的锁后执行
+func (e *Scheduler) tick(j *job) {
+	if e.isPaused(j) {
+		e.metrics.skipped.WithLabelValues(j.name, "paused").Inc()
+		return
+	}
+	if e.opts.locker != nil {
+		key, lockTTL := e.lockKey(j, time.Now())
+		ttl := int64(lockTTL / time.Second)
+		if ttl < 1 {
+			ttl = 1
+		}
+		// 不主动释放, 锁到期前迟到的实例不会重复执行
+		if _, err := e.opts.locker.Lock(key, ttl); err != nil {
+			if !errors.Is(err, storage.ErrNotObtained) {
+				log.Errorf("cronjob: lock %s error, %s", key, err.Error())
+			}
+			e.metrics.skipped.WithLabelValues(j.name, "locked").Inc()
+			return
+		}
+	}
+	e.run(j, TriggerSchedule)
+}
+
+// lockKey 本次触发在集群中的锁, 时钟误差内的实例得到相同的触发时间;
+// @every任务的触发时间取决于各实例的启动时间, 按间隔对齐到相同的时间窗口
+func (e *Scheduler) lockKey(j *job, now time.Time) (string, time.Duration) {
+	ttl := e.opts.lockTTL
+	var at time.Time
+	if s, ok := j.schedule.(cron.ConstantDelaySchedule); ok {
+		at = now.Truncate(s.Delay)
+		// 窗口内迟到的实例不能在锁过期后重复执行
+		if ttl < s.Delay {
+			ttl = s.Delay
+		}
+	} else {
+		at = j.schedule.Next(now.Add(-e.opts.skew))
+	}
+	return fmt.Sprintf("%s:%s:%d", e.opts.keyPrefix, j.name, at.Unix()), ttl
+}
+
+// run 执行任务并记录
+func (e *Scheduler) run(j *job, trigger string) {
+	e.mux.Lock()
+	if e.stopped {
+		e.mux.Unlock()
+		return
+	}
+	e.wait.Add(1)
+	j.running++
+	e.mux.Unlock()
+	e.metrics.running.WithLabelValues(j.name).Inc()
+	defer func() {
+		e.mux.Lock()
+		j.running--
+		e.mux.Unlock()
+		e.metrics.running.WithLabelValues(j.name).Dec()
+		e.wait.Done()
+	}()
+
+	start := time.Now()
+	record := &JobRun{
+		Name:      j.name,
+		Trigger:   trigger,
+		Node:      e.opts.node,
+		Status:    StatusRunning,
+		StartedAt: start,
+	}
+	if e.opts.db != nil {
+		if err := e.opts.db.Create(record).Error; err != nil {
+			log.Errorf("cronjob: save run of %s error, %s", j.name, err.Error())
+		}
+	}
+	err := e.call(j)
+	e.metrics.observe(j.name, trigger, start, err)
+	e.mux.Lock()
+	j.runs++
+	if err != nil {
+		j.failures++
+	}
+	e.mux.Unlock()
+	if err != nil {
+		log.Errorf("cronjob: job %s failed, %s", j.name, err.Error())
+	}
+	if e.opts.db == nil || record.ID == 0 {
+		return
+	}
+	end := time.Now()
+	record.EndedAt = &end
+	record.Status = StatusSuccess
+	if err != nil {
+		record.Status = StatusFailed
+		record.Error = err.Error()
+	}
+	if err = e.opts.db.Save(record).Error; err != nil {
+		log.Errorf("cronjob: save run of %s error, %s", j.name, err.Error())
+	}
+}
+
+// call 执行任务, panic作为错误记录
+func (e *Scheduler) call(j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.f(e.ctx)
+}
+
+// isPaused 设置了数据库时以数据库中的状态为准
+func (e *Scheduler) isPaused(j *job) bool {
+	if e.opts.db != nil {
+		var state JobState
+		err := e.opts.db.Where("name = ?", j.name).Limit(1).Find(&state).Error
+		if err != nil {
+			log.Errorf("cronjob: get state of %s error, %s", j.name, err.Error())
+		} else {
+			return state.Paused
+		}
+	}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return j.paused
+}
+
+func (e *Scheduler) setPaused(name string, paused bool) error {
+	j, err := e.getJob(name)
+	if err != nil {
+		return err
+	}
+	if e.opts.db != nil {
+		err = e.opts.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"paused", "updated_at"}),
+		}).Create(&JobState{Name: name, Paused: paused}).Error
+		if err != nil {
+			return err
+		}
+	}
+	e.mux.Lock()
+	j.paused = paused
+	e.mux.Unlock()
+	return nil
+}
+
+// Pause 暂停任务, 设置了数据库时对所有实例生效
+func (e *Scheduler) Pause(name string) error {
+	return e.setPaused(name, true)
+}
+
+// Resume 恢复任务
+func (e *Scheduler) Resume(name string) error {
+	return e.setPaused(name, false)
+}
+
+// Trigger 立即在本实例异步执行一次, 不受暂停影响
+func (e *Scheduler) Trigger(name string) error {
+	j, err := e.getJob(name)
+	if err != nil {
+		return err
+	}
+	go e.run(j, TriggerManual)
+	return nil
+}
+
+// Jobs 所有任务
+func (e *Scheduler) Jobs() []JobInfo {
+	e.mux.Lock()
+	list := make([]*job, 0, len(e.jobs))
+	for _, j := range e.jobs {
+		list = append(list, j)
+	}
+	e.mux.Unlock()
+	result := make([]JobInfo, 0, len(list))
+	for _, j := range list {
+		paused := e.isPaused(j)
+		entry := e.cron.Entry(j.entry)
+		e.mux.Lock()
+		result = append(result, JobInfo{
+			Name:     j.name,
+			Spec:     j.spec,
+			Paused:   paused,
+			Running:  j.running,
+			Runs:     j.runs,
+			Failures: j.failures,
+			Prev:     entry.Prev,
+			Next:     entry.Next,
+		})
+		e.mux.Unlock()
+	}
+	sort.Slice(result, func(i, k int) bool { return result[i].Name < result[k].Name })
+	return result
+}
+
+// Runs 最近limit次执行记录, 需要WithDB
+func (e *Scheduler) Runs(name string, limit int) ([]JobRun, error) {
+	if e.opts.db == nil {
+		return nil, errors.New("cronjob: run history requires a database")
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	var list []JobRun
+	err := e.opts.db.Where("name = ?", name).
+		Order("started_at DESC").Order("id DESC").
+		Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// String 服务名称
+func (e *Scheduler) String() string {
+	return "cronjob-scheduler"
+}
+
+// Start 启动调度直到ctx结束, 然后取消任务的context并等待执行中的任务返回
+func (e *Scheduler) Start(ctx context.Context) error {
+	e.mux.Lock()
+	if e.started {
+		e.mux.Unlock()
+		return errors.New("scheduler was started more than once. " +
+			"This is likely to be caused by being added to a manager multiple times")
+	}
+	e.started = true
+	e.mux.Unlock()
+
+	e.cron.Start()
+	<-ctx.Done()
+	stopped := e.cron.Stop()
+	e.mux.Lock()
+	e.stopped = true
+	e.mux.Unlock()
+	e.cancel()
+	<-stopped.Done()
+	e.wait.Wait()
+	return nil
+}
+
+// Attempt 判断是否可以启动
+func (e *Scheduler) Attempt() bool {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return !e.started
+}
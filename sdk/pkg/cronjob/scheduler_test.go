@@ -0,0 +1,138 @@
+package cronjob
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alopt/go-admin-core/storage/locker"
+)
+
+func TestScheduler_Cluster(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		stagger  time.Duration
+		duration time.Duration
+		min, max int32
+	}{
+		// 2.5s内触发2~3次, 每次只有一个实例执行
+		{name: "cron", spec: "* * * * * *", duration: 2500 * time.Millisecond, min: 2, max: 3},
+		{name: "every", spec: "@every 1s", duration: 2500 * time.Millisecond, min: 2, max: 3},
+		// 实例依次晚1s启动, 各自在第3、4、5秒触发, 跨度小于间隔, 最多落在两个窗口
+		{name: "every staggered", spec: "@every 3s", stagger: time.Second, duration: 5500 * time.Millisecond, min: 1, max: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutex := locker.NewMemory()
+			var runs int32
+			ctx, cancel := context.WithCancel(context.Background())
+			var wait sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				s := NewScheduler(WithLocker(mutex))
+				if err := s.AddJob("count", tt.spec, func(ctx context.Context) error {
+					atomic.AddInt32(&runs, 1)
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+				wait.Add(1)
+				go func(delay time.Duration) {
+					defer wait.Done()
+					time.Sleep(delay)
+					_ = s.Start(ctx)
+				}(time.Duration(i) * tt.stagger)
+			}
+			time.Sleep(tt.duration)
+			cancel()
+			wait.Wait()
+			if n := atomic.LoadInt32(&runs); n < tt.min || n > tt.max {
+				t.Fatalf("runs = %d, want %d~%d", n, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestScheduler_PauseTrigger(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	s := NewScheduler(WithDB(db))
+	done := make(chan struct{}, 10)
+	fail := errors.New("fail")
+	if err = s.AddJob("job", "@every 1h", func(ctx context.Context) error {
+		done <- struct{}{}
+		return fail
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.AddJob("job", "@every 1h", nil); err != ErrJobExists {
+		t.Fatalf("AddJob() = %v, want ErrJobExists", err)
+	}
+	if err = s.Pause("job"); err != nil {
+		t.Fatal(err)
+	}
+	// 其他实例读取到共享的暂停状态
+	other := NewScheduler(WithDB(db))
+	_ = other.AddJob("job", "@every 1h", func(ctx context.Context) error {
+		t.Error("paused job should not run")
+		return nil
+	})
+	other.tick(other.jobs["job"])
+	if jobs := other.Jobs(); len(jobs) != 1 || !jobs[0].Paused {
+		t.Fatalf("Jobs() = %+v", jobs)
+	}
+
+	// 暂停不影响手动触发
+	if err = s.Trigger("job"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("triggered job not run")
+	}
+	var list []JobRun
+	for i := 0; i < 100; i++ {
+		list, _ = s.Runs("job", 10)
+		if len(list) == 1 && list[0].Status != StatusRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(list) != 1 || list[0].Status != StatusFailed || list[0].Error != "fail" ||
+		list[0].Trigger != TriggerManual || list[0].EndedAt == nil {
+		t.Fatalf("Runs() = %+v", list)
+	}
+	if jobs := s.Jobs(); jobs[0].Runs != 1 || jobs[0].Failures != 1 {
+		t.Fatalf("Jobs() = %+v", jobs)
+	}
+
+	if err = s.Resume("job"); err != nil {
+		t.Fatal(err)
+	}
+	s.tick(s.jobs["job"])
+	select {
+	case <-done:
+	default:
+		t.Fatal("resumed job not run")
+	}
+	if err = s.Trigger("missing"); err != ErrJobNotFound {
+		t.Fatalf("Trigger() = %v, want ErrJobNotFound", err)
+	}
+}
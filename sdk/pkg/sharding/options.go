@@ -0,0 +1,22 @@
+package sharding
+
+type Option func(*options)
+
+type options struct {
+	concurrency int
+}
+
+func setDefaultOptions() options {
+	return options{
+		concurrency: 8,
+	}
+}
+
+// WithConcurrency 跨表查询时同时查询的分表数, 默认8
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package sharding
+
+import (
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// Rule 分表规则, 分表名为 基础表名_后缀
+type Rule interface {
+	// Shard 根据分表键的值计算后缀
+	Shard(value interface{}) (string, error)
+	// Suffixes 所有分表的后缀, 用于建表和跨表查询
+	Suffixes() []string
+}
+
+// validator 规则在Register时校验参数
+type validator interface {
+	validate() error
+}
+
+// count 分表数量, 必须大于0
+func count(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("sharding: shard count must be positive, got %d", n)
+	}
+	return nil
+}
+
+// Hash crc32取模分n张表, 与table.Crc32Hash等的结果一致; n<=0时Register返回错误
+func Hash(n int) Rule {
+	return hashRule(n)
+}
+
+type hashRule int
+
+func (e hashRule) Shard(value interface{}) (string, error) {
+	s, err := cast.ToStringE(indirect(value))
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(int(crc32.ChecksumIEEE([]byte(s)) % uint32(e))), nil
+}
+
+func (e hashRule) Suffixes() []string {
+	return sequence(int(e))
+}
+
+func (e hashRule) validate() error {
+	return count(int(e))
+}
+
+// Modulo 整数取模分n张表, 如按用户id; n<=0时Register返回错误
+func Modulo(n int) Rule {
+	return moduloRule(n)
+}
+
+type moduloRule int
+
+func (e moduloRule) Shard(value interface{}) (string, error) {
+	i, err := cast.ToInt64E(indirect(value))
+	if err != nil {
+		return "", err
+	}
+	// 先取余再取绝对值, 避免math.MinInt64取反溢出
+	r := i % int64(e)
+	if r < 0 {
+		r = -r
+	}
+	return strconv.FormatInt(r, 10), nil
+}
+
+func (e moduloRule) Suffixes() []string {
+	return sequence(int(e))
+}
+
+func (e moduloRule) validate() error {
+	return count(int(e))
+}
+
+func sequence(n int) []string {
+	suffixes := make([]string, n)
+	for i := range suffixes {
+		suffixes[i] = strconv.Itoa(i)
+	}
+	return suffixes
+}
+
+// dateRule 按时间范围分表
+type dateRule struct {
+	layout string
+	// start 所在周期的开始时间
+	start func(t time.Time) time.Time
+	next  func(t time.Time) time.Time
+	from  time.Time
+	to    time.Time
+}
+
+// Daily 按天分表, 后缀如20260102; to为零值时到明天为止
+func Daily(from, to time.Time) Rule {
+	return &dateRule{
+		layout: "20060102",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		},
+		next: func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+		from: from,
+		to:   to,
+	}
+}
+
+// Monthly 按月分表, 后缀如202601; to为零值时到下个月为止
+func Monthly(from, to time.Time) Rule {
+	return &dateRule{
+		layout: "200601",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		},
+		next: func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+		from: from,
+		to:   to,
+	}
+}
+
+// Yearly 按年分表, 后缀如2026; to为零值时到明年为止
+func Yearly(from, to time.Time) Rule {
+	return &dateRule{
+		layout: "2006",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		},
+		next: func(t time.Time) time.Time { return t.AddDate(1, 0, 0) },
+		from: from,
+		to:   to,
+	}
+}
+
+func (e *dateRule) Shard(value interface{}) (string, error) {
+	t, err := cast.ToTimeE(indirect(value))
+	if err != nil {
+		return "", err
+	}
+	if t.IsZero() {
+		return "", fmt.Errorf("sharding: zero time")
+	}
+	t = t.In(e.from.Location())
+	// 只能落在Suffixes范围内的分表
+	if start := e.start(t); start.Before(e.start(e.from)) || start.After(e.until()) {
+		return "", fmt.Errorf("%w: %s", ErrOutOfRange, t.Format(e.layout))
+	}
+	return t.Format(e.layout), nil
+}
+
+// until 最后一个周期所在的时间, 未指定to时为下一个周期
+func (e *dateRule) until() time.Time {
+	if e.to.IsZero() {
+		return e.next(time.Now().In(e.from.Location()))
+	}
+	return e.to
+}
+
+// Suffixes 从from到to(含)的所有周期, 未指定to时包含下一个周期, 需要定期Migrate创建新表
+func (e *dateRule) Suffixes() []string {
+	to := e.until()
+	var suffixes []string
+	for t := e.start(e.from); !t.After(to); t = e.next(t) {
+		suffixes = append(suffixes, t.Format(e.layout))
+	}
+	return suffixes
+}
+
+// indirect 取指针指向的值
+func indirect(value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
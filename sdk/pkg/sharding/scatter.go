@@ -0,0 +1,190 @@
+package sharding
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Order 跨表分页的排序列
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// each 并发在每张分表上执行f, 返回第一个错误
+func (e *Sharding) each(tables []string, f func(i int, table string) error) error {
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		err  error
+		sem  = make(chan struct{}, e.opts.concurrency)
+	)
+	for i := range tables {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if e := f(i, tables[i]); e != nil {
+				once.Do(func() { err = e })
+			}
+		}(i)
+	}
+	wg.Wait()
+	return err
+}
+
+// Find 在所有分表中查询, 按分表顺序合并到dest(切片指针); db上的条件和scopes作用于每张分表
+func (e *Sharding) Find(db *gorm.DB, dest interface{}, scopes ...func(*gorm.DB) *gorm.DB) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("sharding: dest must be a pointer to slice")
+	}
+	tables, err := e.Tables(db, dest)
+	if err != nil {
+		return err
+	}
+	results := make([]reflect.Value, len(tables))
+	err = e.each(tables, func(i int, table string) error {
+		v := reflect.New(rv.Elem().Type())
+		if err := db.Session(&gorm.Session{}).Table(table).Scopes(scopes...).Find(v.Interface()).Error; err != nil {
+			return err
+		}
+		results[i] = v.Elem()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	list := rv.Elem().Slice(0, 0)
+	for i := range results {
+		list = reflect.AppendSlice(list, results[i])
+	}
+	rv.Elem().Set(list)
+	return nil
+}
+
+// Count 所有分表的总数
+func (e *Sharding) Count(db *gorm.DB, model interface{}, scopes ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	tables, err := e.Tables(db, model)
+	if err != nil {
+		return 0, err
+	}
+	counts := make([]int64, len(tables))
+	err = e.each(tables, func(i int, table string) error {
+		return db.Session(&gorm.Session{}).Table(table).Scopes(scopes...).Count(&counts[i]).Error
+	})
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	return total, err
+}
+
+// Paginate 跨表分页, 每张分表取前pageIndex*pageSize条, 按order合并排序后截取, 返回总数;
+// 页数越大每张表读取的数据越多, 深分页应改用按分表键查询
+func (e *Sharding) Paginate(db *gorm.DB, dest interface{}, order Order, pageIndex, pageSize int,
+	scopes ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	if pageIndex < 1 {
+		pageIndex = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	count, err := e.Count(db, dest, scopes...)
+	if err != nil {
+		return 0, err
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err = stmt.Parse(dest); err != nil {
+		return 0, err
+	}
+	field := stmt.Schema.LookUpField(order.Column)
+	if field == nil {
+		return 0, errors.New("sharding: unknown order column " + order.Column)
+	}
+	direction := " ASC"
+	if order.Desc {
+		direction = " DESC"
+	}
+	offset := (pageIndex - 1) * pageSize
+	err = e.Find(db, dest, append(scopes[:len(scopes):len(scopes)], func(db *gorm.DB) *gorm.DB {
+		return db.Order(field.DBName + direction).Limit(offset + pageSize)
+	})...)
+	if err != nil {
+		return 0, err
+	}
+
+	list := reflect.ValueOf(dest).Elem()
+	values := make([]interface{}, list.Len())
+	for i := range values {
+		values[i], _ = field.ValueOf(stmt.Context, reflect.Indirect(list.Index(i)))
+	}
+	index := make([]int, list.Len())
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		c := compare(values[index[i]], values[index[j]])
+		if order.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	page := reflect.MakeSlice(list.Type(), 0, pageSize)
+	for i := offset; i < len(index) && i < offset+pageSize; i++ {
+		page = reflect.Append(page, list.Index(index[i]))
+	}
+	list.Set(page)
+	return count, nil
+}
+
+// compare 比较排序列的值, 支持数字、字符串、时间
+func compare(a, b interface{}) int {
+	a, b = indirect(a), indirect(b)
+	switch x := a.(type) {
+	case time.Time:
+		y, _ := b.(time.Time)
+		return x.Compare(y)
+	case string:
+		y, _ := b.(string)
+		return strings.Compare(x, y)
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		switch {
+		case va.IsValid():
+			return 1
+		case vb.IsValid():
+			return -1
+		}
+		return 0
+	}
+	switch va.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return three(va.Int() < vb.Int(), va.Int() > vb.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return three(va.Uint() < vb.Uint(), va.Uint() > vb.Uint())
+	case reflect.Float32, reflect.Float64:
+		return three(va.Float() < vb.Float(), va.Float() > vb.Float())
+	}
+	return 0
+}
+
+func three(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
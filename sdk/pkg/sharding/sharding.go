@@ -0,0 +1,307 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrMissingShardingKey 条件或数据中没有分表键, 跨表查询使用Find、Count、Paginate
+	ErrMissingShardingKey = errors.New("sharding: sharding key not found")
+	// ErrCrossShard 同一条语句涉及多张分表
+	ErrCrossShard = errors.New("sharding: values belong to different shards")
+	// ErrNotRegistered 模型没有注册分表规则
+	ErrNotRegistered = errors.New("sharding: model not registered")
+	// ErrOutOfRange 分表键的值超出规则的范围, 对应的分表不存在
+	ErrOutOfRange = errors.New("sharding: value out of range")
+)
+
+// exprPattern 匹配 "key = ?" 和 "key IN ?" 形式的条件, 列名可以带引号
+var exprPattern = regexp.MustCompile("^\\s*(?:[`\"]?\\w+[`\"]?\\.)?[`\"]?(\\w+)[`\"]?\\s*(=|(?i:in))\\s*\\(?\\?\\)?\\s*$")
+
+// config 单个模型的分表配置
+type config struct {
+	model interface{}
+	key   string
+	rule  Rule
+	table string
+}
+
+func (e *config) name(suffix string) string {
+	return e.table + "_" + suffix
+}
+
+// Sharding gorm插件, 根据条件中的分表键把基础表名改写为分表名
+//
+//	s := sharding.New(sharding.WithConcurrency(4))
+//	_ = s.Register(&Order{}, "user_id", sharding.Modulo(16))
+//	err := db.Use(s)
+//	err = s.Migrate(db)
+//	db.Where("user_id = ?", 3).Find(&orders) // 查询orders_3
+type Sharding struct {
+	db      *gorm.DB
+	opts    options
+	pending []*config
+	configs map[string]*config
+	mux     sync.RWMutex
+}
+
+func New(opts ...Option) *Sharding {
+	o := setDefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Sharding{
+		opts:    o,
+		configs: make(map[string]*config),
+	}
+}
+
+func (*Sharding) Name() string {
+	return "go-admin:sharding"
+}
+
+// Register 为模型注册分表规则, key为分表键的列名; 在db.Use之前注册时, 错误由db.Use返回
+func (e *Sharding) Register(model interface{}, key string, rule Rule) error {
+	if rule == nil {
+		return errors.New("sharding: rule is nil")
+	}
+	if v, ok := rule.(validator); ok {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+	c := &config{model: model, key: key, rule: rule}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.db == nil {
+		e.pending = append(e.pending, c)
+		return nil
+	}
+	return e.parse(c)
+}
+
+// parse 解析模型的基础表名, 需要持有e.mux
+func (e *Sharding) parse(c *config) error {
+	stmt := &gorm.Statement{DB: e.db}
+	if err := stmt.Parse(c.model); err != nil {
+		return err
+	}
+	if stmt.Schema.LookUpField(c.key) == nil {
+		return fmt.Errorf("sharding: %s has no field %s", stmt.Schema.Table, c.key)
+	}
+	c.table = stmt.Schema.Table
+	e.configs[c.table] = c
+	return nil
+}
+
+func (e *Sharding) Initialize(db *gorm.DB) error {
+	e.mux.Lock()
+	e.db = db
+	for _, c := range e.pending {
+		if err := e.parse(c); err != nil {
+			e.mux.Unlock()
+			return err
+		}
+	}
+	e.pending = nil
+	e.mux.Unlock()
+
+	callbacks := []error{
+		db.Callback().Create().Before("gorm:create").Register("go-admin:sharding", e.rewrite),
+		db.Callback().Query().Before("gorm:query").Register("go-admin:sharding", e.rewrite),
+		db.Callback().Update().Before("gorm:update").Register("go-admin:sharding", e.rewrite),
+		db.Callback().Delete().Before("gorm:delete").Register("go-admin:sharding", e.rewrite),
+		db.Callback().Row().Before("gorm:row").Register("go-admin:sharding", e.rewrite),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup 获取基础表的配置
+func (e *Sharding) lookup(table string) (*config, bool) {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	c, ok := e.configs[table]
+	return c, ok
+}
+
+// modelConfig 获取模型或模型切片的配置
+func (e *Sharding) modelConfig(db *gorm.DB, model interface{}) (*config, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	c, ok := e.lookup(stmt.Schema.Table)
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	return c, nil
+}
+
+// Migrate 按基础模型创建或迁移所有分表, 按时间分表时需要定期执行以创建新的分表
+func (e *Sharding) Migrate(db *gorm.DB) error {
+	e.mux.RLock()
+	configs := make([]*config, 0, len(e.configs))
+	for _, c := range e.configs {
+		configs = append(configs, c)
+	}
+	e.mux.RUnlock()
+	for _, c := range configs {
+		for _, suffix := range c.rule.Suffixes() {
+			if err := db.Table(c.name(suffix)).AutoMigrate(c.model); err != nil {
+				return fmt.Errorf("sharding: migrate %s: %w", c.name(suffix), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Tables 模型的所有分表名
+func (e *Sharding) Tables(db *gorm.DB, model interface{}) ([]string, error) {
+	c, err := e.modelConfig(db, model)
+	if err != nil {
+		return nil, err
+	}
+	suffixes := c.rule.Suffixes()
+	tables := make([]string, len(suffixes))
+	for i := range suffixes {
+		tables[i] = c.name(suffixes[i])
+	}
+	return tables, nil
+}
+
+// rewrite 语句使用基础表名时改写为分表名
+func (e *Sharding) rewrite(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Table == "" || stmt.TableExpr != nil {
+		return
+	}
+	c, ok := e.lookup(stmt.Table)
+	if !ok {
+		return
+	}
+	suffix, err := e.suffix(stmt, c)
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("%w: %s", err, c.table))
+		return
+	}
+	stmt.Table = c.name(suffix)
+}
+
+// suffix 先从where条件中找分表键, 找不到时从写入的数据中找
+func (e *Sharding) suffix(stmt *gorm.Statement, c *config) (string, error) {
+	if where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where); ok {
+		if values, ok := find(where.Exprs, c.key); ok {
+			return same(c.rule, values)
+		}
+	}
+	if stmt.Schema == nil || !stmt.ReflectValue.IsValid() {
+		return "", ErrMissingShardingKey
+	}
+	field := stmt.Schema.LookUpField(c.key)
+	if field == nil {
+		return "", ErrMissingShardingKey
+	}
+	var values []interface{}
+	add := func(rv reflect.Value) {
+		if v, zero := field.ValueOf(stmt.Context, rv); !zero {
+			values = append(values, v)
+		}
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			add(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		add(stmt.ReflectValue)
+	}
+	if len(values) == 0 {
+		return "", ErrMissingShardingKey
+	}
+	return same(c.rule, values)
+}
+
+// same 所有值必须落在同一张分表
+func same(rule Rule, values []interface{}) (string, error) {
+	var suffix string
+	for i, value := range values {
+		s, err := rule.Shard(value)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 && s != suffix {
+			return "", ErrCrossShard
+		}
+		suffix = s
+	}
+	return suffix, nil
+}
+
+// find 在AND条件中查找分表键的值, 不处理OR条件
+func find(exprs []clause.Expression, key string) ([]interface{}, bool) {
+	for _, expr := range exprs {
+		switch v := expr.(type) {
+		case clause.Eq:
+			if column(v.Column) == key {
+				return []interface{}{v.Value}, true
+			}
+		case clause.IN:
+			if column(v.Column) == key && len(v.Values) > 0 {
+				return v.Values, true
+			}
+		case clause.Expr:
+			if m := exprPattern.FindStringSubmatch(v.SQL); m != nil && m[1] == key && len(v.Vars) == 1 {
+				if m[2] == "=" {
+					return []interface{}{v.Vars[0]}, true
+				}
+				if values := expand(v.Vars[0]); len(values) > 0 {
+					return values, true
+				}
+			}
+		case clause.AndConditions:
+			if values, ok := find(v.Exprs, key); ok {
+				return values, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// expand 展开IN条件的切片参数
+func expand(value interface{}) []interface{} {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+func column(c interface{}) string {
+	switch v := c.(type) {
+	case clause.Column:
+		return v.Name
+	case string:
+		if i := strings.LastIndexByte(v, '.'); i >= 0 {
+			v = v[i+1:]
+		}
+		return strings.Trim(v, "`\"")
+	}
+	return ""
+}
@@ -0,0 +1,218 @@
+package sharding
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alopt/go-admin-core/sdk/pkg/table"
+)
+
+type Trade struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    int
+	Amount    int
+	CreatedAt time.Time
+}
+
+func newDB(t *testing.T) (*gorm.DB, *Sharding) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	s := New(WithConcurrency(2))
+	if err = s.Register(&Trade{}, "user_id", Modulo(4)); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Use(s); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db, s
+}
+
+func TestRule(t *testing.T) {
+	from := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		rule     Rule
+		value    interface{}
+		want     string
+		suffixes []string
+		err      error
+	}{
+		{"hash", Hash(32), "小圈圈", table.Crc32Hash("小圈圈"), nil, nil},
+		{"modulo", Modulo(4), int64(7), "3", []string{"0", "1", "2", "3"}, nil},
+		{"modulo pointer", Modulo(4), func() *int { i := 6; return &i }(), "2", nil, nil},
+		{"monthly", Monthly(from, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)), time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC), "202602", []string{"202601", "202602", "202603"}, nil},
+		{"daily string", Daily(from, from.AddDate(0, 0, 1)), "2026-01-16", "20260116", []string{"20260115", "20260116"}, nil},
+		{"yearly", Yearly(from, from), from, "2026", []string{"2026"}, nil},
+		{"modulo negative", Modulo(4), int64(-7), "3", nil, nil},
+		{"modulo min int64", Modulo(3), int64(math.MinInt64), "2", nil, nil},
+		{"monthly before from", Monthly(from, from.AddDate(0, 2, 0)), time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), "", nil, ErrOutOfRange},
+		{"monthly after to", Monthly(from, from.AddDate(0, 2, 0)), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), "", nil, ErrOutOfRange},
+		{"daily beyond next period", Daily(from, time.Time{}), time.Now().AddDate(0, 0, 3), "", nil, ErrOutOfRange},
+		{"daily next period", Daily(from, time.Time{}), time.Now().AddDate(0, 0, 1), time.Now().AddDate(0, 0, 1).In(time.UTC).Format("20060102"), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.Shard(tt.value)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Shard() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Shard() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Shard() = %s, want %s", got, tt.want)
+			}
+			if tt.suffixes != nil {
+				if got := tt.rule.Suffixes(); len(got) != len(tt.suffixes) || got[len(got)-1] != tt.suffixes[len(tt.suffixes)-1] {
+					t.Errorf("Suffixes() = %v, want %v", got, tt.suffixes)
+				}
+			}
+		})
+	}
+}
+
+func TestSharding_Register(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"modulo", Modulo(4), false},
+		{"modulo zero", Modulo(0), true},
+		{"modulo negative", Modulo(-1), true},
+		{"hash zero", Hash(0), true},
+		{"nil", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := New().Register(&Trade{}, "user_id", tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSharding_Rewrite(t *testing.T) {
+	db, _ := newDB(t)
+	orders := []Trade{
+		{UserID: 1, Amount: 10}, {UserID: 5, Amount: 20}, {UserID: 2, Amount: 30}, {UserID: 3, Amount: 40},
+	}
+	for i := range orders {
+		if err := db.Create(&orders[i]).Error; err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if err := db.Create(&[]Trade{{UserID: 1}, {UserID: 2}}).Error; !errors.Is(err, ErrCrossShard) {
+		t.Errorf("Create() across shards error = %v, want %v", err, ErrCrossShard)
+	}
+	var count int64
+	db.Table("trades_1").Count(&count)
+	if count != 2 {
+		t.Errorf("orders_1 count = %d, want 2", count)
+	}
+
+	tests := []struct {
+		name    string
+		query   func(db *gorm.DB) *gorm.DB
+		want    int
+		wantErr error
+	}{
+		{"expr", func(db *gorm.DB) *gorm.DB { return db.Where("user_id = ?", 1) }, 1, nil},
+		{"quoted expr", func(db *gorm.DB) *gorm.DB { return db.Where("`user_id` = ?", 5).Where("amount > ?", 10) }, 1, nil},
+		{"in expr", func(db *gorm.DB) *gorm.DB { return db.Where("user_id in (?)", []int{1, 5}) }, 2, nil},
+		{"struct", func(db *gorm.DB) *gorm.DB { return db.Where(&Trade{UserID: 2}) }, 1, nil},
+		{"map", func(db *gorm.DB) *gorm.DB { return db.Where(map[string]interface{}{"user_id": 3}) }, 1, nil},
+		{"in same shard", func(db *gorm.DB) *gorm.DB { return db.Where("user_id IN ?", []int{1, 5}) }, 2, nil},
+		{"in cross shard", func(db *gorm.DB) *gorm.DB { return db.Where(map[string]interface{}{"user_id": []int{1, 2}}) }, 0, ErrCrossShard},
+		{"missing key", func(db *gorm.DB) *gorm.DB { return db.Where("amount > ?", 0) }, 0, ErrMissingShardingKey},
+		{"or is not used", func(db *gorm.DB) *gorm.DB { return db.Where("amount = ?", 0).Or("user_id = ?", 1) }, 0, ErrMissingShardingKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list []Trade
+			err := tt.query(db).Find(&list).Error
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Find() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(list) != tt.want {
+				t.Errorf("Find() = %d rows, want %d", len(list), tt.want)
+			}
+		})
+	}
+
+	order := orders[0]
+	if err := db.Model(&order).Update("amount", 11).Error; err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := db.Where("user_id = ?", 2).Delete(&Trade{}).Error; err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	var amount int
+	db.Model(&Trade{}).Where("user_id = ?", 1).Where("id = ?", order.ID).Pluck("amount", &amount)
+	if amount != 11 {
+		t.Errorf("amount = %d, want 11", amount)
+	}
+}
+
+func TestSharding_Scatter(t *testing.T) {
+	db, s := newDB(t)
+	for i := 1; i <= 10; i++ {
+		if err := db.Create(&Trade{UserID: i, Amount: i * 10}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	var list []Trade
+	if err := s.Find(db.Where("amount > ?", 50), &list); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if len(list) != 5 {
+		t.Errorf("Find() = %d rows, want 5", len(list))
+	}
+	count, err := s.Count(db, &Trade{}, func(db *gorm.DB) *gorm.DB { return db.Where("amount <= ?", 30) })
+	if err != nil || count != 3 {
+		t.Errorf("Count() = %d, %v, want 3", count, err)
+	}
+	tests := []struct {
+		order Order
+		page  int
+		want  []int
+	}{
+		{Order{"amount", true}, 1, []int{100, 90, 80, 70}},
+		{Order{"amount", true}, 3, []int{20, 10}},
+		{Order{"user_id", false}, 2, []int{50, 60, 70, 80}},
+	}
+	for _, tt := range tests {
+		var page []*Trade
+		count, err := s.Paginate(db, &page, tt.order, tt.page, 4)
+		if err != nil {
+			t.Fatalf("Paginate() error = %v", err)
+		}
+		if count != 10 || len(page) != len(tt.want) {
+			t.Fatalf("Paginate() = %d rows of %d, want %d of 10", len(page), count, len(tt.want))
+		}
+		for i := range page {
+			if page[i].Amount != tt.want[i] {
+				t.Errorf("Paginate(%v, %d)[%d] = %d, want %d", tt.order, tt.page, i, page[i].Amount, tt.want[i])
+			}
+		}
+	}
+	if _, err = s.Tables(db, &struct{ ID int }{}); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Tables() unregistered error = %v", err)
+	}
+}
@@ -35,6 +35,9 @@ func DynamicTable(f func(string) string, baseTable, fieldValue string) func(db *
 	}
 }
 
+// CreateSubTable 未实现
+//
+// Deprecated: 使用sharding.Sharding.Migrate按分表规则创建分表
 func CreateSubTable(f func(string) string) {
 
 }
@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// 租户中间件写入gin上下文的key, db沿用GetOrm读取的"db"
+const (
+	TenantKey = "_go-admin-tenant"
+	CacheKey  = "_go-admin-cache"
+	QueueKey  = "_go-admin-queue"
+	CasbinKey = "_go-admin-casbin"
+)
+
+// GetTenant 获取当前请求的租户, 未经过租户中间件时为空
+func GetTenant(c *gin.Context) string {
+	return c.GetString(TenantKey)
+}
+
+// GetCache 获取当前租户的cache
+func GetCache(c *gin.Context) (storage.AdapterCache, bool) {
+	cache, ok := c.Value(CacheKey).(storage.AdapterCache)
+	return cache, ok
+}
+
+// GetQueue 获取当前租户的queue
+func GetQueue(c *gin.Context) (storage.AdapterQueue, bool) {
+	queue, ok := c.Value(QueueKey).(storage.AdapterQueue)
+	return queue, ok
+}
+
+// GetCasbin 获取当前租户的casbin
+func GetCasbin(c *gin.Context) (*casbin.SyncedEnforcer, bool) {
+	enforcer, ok := c.Value(CasbinKey).(*casbin.SyncedEnforcer)
+	return enforcer, ok && enforcer != nil
+}
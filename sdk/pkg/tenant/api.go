@@ -0,0 +1,50 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alopt/go-admin-core/sdk/pkg/response"
+)
+
+// Routers 注册租户管理接口, 新增时传入数据库连接串, handlers需要做好鉴权
+//
+//	GET    /tenants        列表
+//	POST   /tenants        新增
+//	DELETE /tenants/:name  移除
+func (e *Registry) Routers(r *gin.RouterGroup, handlers ...gin.HandlerFunc) {
+	g := r.Group("/tenants", handlers...)
+	g.GET("", func(c *gin.Context) {
+		response.OK(c, e.List(), "")
+	})
+	g.POST("", func(c *gin.Context) {
+		req := &TenantReq{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			response.Error(c, http.StatusBadRequest, err, "")
+			return
+		}
+		t := req.Generate()
+		if err := e.Add(c.Request.Context(), t); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrTenantExists) {
+				code = http.StatusConflict
+			}
+			response.Error(c, code, err, "")
+			return
+		}
+		response.OK(c, t, "")
+	})
+	g.DELETE("/:name", func(c *gin.Context) {
+		if err := e.Remove(c.Request.Context(), c.Param("name")); err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrTenantNotFound) {
+				code = http.StatusNotFound
+			}
+			response.Error(c, code, err, "")
+			return
+		}
+		response.OK(c, nil, "")
+	})
+}
@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/alopt/go-admin-core/sdk/config"
+)
+
+// Tenant 租户定义, 持久化在控制面的库中, 启动时通过Registry.Load重新打开
+// 连接串含数据库密码, 不会序列化到接口响应中, 新增时通过TenantReq传入
+type Tenant struct {
+	ID              uint                      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name            string                    `gorm:"size:128;uniqueIndex;not null" json:"name"`
+	Driver          string                    `gorm:"size:32;not null" json:"driver"`
+	Source          string                    `gorm:"size:1024;not null" json:"-"`
+	ConnMaxIdleTime int                       `json:"connMaxIdleTime"`
+	ConnMaxLifeTime int                       `json:"connMaxLifeTime"`
+	MaxIdleConns    int                       `json:"maxIdleConns"`
+	MaxOpenConns    int                       `json:"maxOpenConns"`
+	Registers       []config.DBResolverConfig `gorm:"type:text;serializer:json" json:"-"`
+	Config          map[string]interface{}    `gorm:"type:text;serializer:json" json:"config"`
+	CreatedAt       time.Time                 `json:"createdAt"`
+	UpdatedAt       time.Time                 `json:"updatedAt"`
+}
+
+func (Tenant) TableName() string {
+	return "sys_tenant"
+}
+
+// Migrate 创建租户表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Tenant{})
+}
+
+// Database 转为sdk/config的db配置, 按driver注册表打开
+func (e *Tenant) Database() *config.Database {
+	return &config.Database{
+		Driver:          e.Driver,
+		Source:          e.Source,
+		ConnMaxIdleTime: e.ConnMaxIdleTime,
+		ConnMaxLifeTime: e.ConnMaxLifeTime,
+		MaxIdleConns:    e.MaxIdleConns,
+		MaxOpenConns:    e.MaxOpenConns,
+		Registers:       e.Registers,
+	}
+}
+
+// TenantReq 新增租户的请求参数
+type TenantReq struct {
+	Name            string                    `json:"name"`
+	Driver          string                    `json:"driver"`
+	Source          string                    `json:"source"`
+	ConnMaxIdleTime int                       `json:"connMaxIdleTime"`
+	ConnMaxLifeTime int                       `json:"connMaxLifeTime"`
+	MaxIdleConns    int                       `json:"maxIdleConns"`
+	MaxOpenConns    int                       `json:"maxOpenConns"`
+	Registers       []config.DBResolverConfig `json:"registers"`
+	Config          map[string]interface{}    `json:"config"`
+}
+
+// Generate 转为租户模型
+func (e *TenantReq) Generate() *Tenant {
+	return &Tenant{
+		Name:            e.Name,
+		Driver:          e.Driver,
+		Source:          e.Source,
+		ConnMaxIdleTime: e.ConnMaxIdleTime,
+		ConnMaxLifeTime: e.ConnMaxLifeTime,
+		MaxIdleConns:    e.MaxIdleConns,
+		MaxOpenConns:    e.MaxOpenConns,
+		Registers:       e.Registers,
+		Config:          e.Config,
+	}
+}
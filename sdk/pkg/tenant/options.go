@@ -0,0 +1,58 @@
+package tenant
+
+import (
+	"github.com/casbin/casbin/v2"
+	"gorm.io/gorm"
+
+	"github.com/alopt/go-admin-core/sdk"
+	"github.com/alopt/go-admin-core/sdk/runtime"
+	"github.com/alopt/go-admin-core/tools/database"
+)
+
+// EnforcerFunc 为租户创建casbin, 返回的close在移除租户时调用, 可以为nil
+type EnforcerFunc func(tenant string, db *gorm.DB) (enforcer *casbin.SyncedEnforcer, close func(), err error)
+
+type Option func(*options)
+
+type options struct {
+	runtime    runtime.Runtime
+	gormConfig *gorm.Config
+	enforcer   EnforcerFunc
+}
+
+func setDefaultOptions() options {
+	return options{
+		runtime:    sdk.Runtime,
+		gormConfig: &gorm.Config{},
+	}
+}
+
+// WithRuntime 注册到的runtime, 默认sdk.Runtime
+func WithRuntime(rt runtime.Runtime) Option {
+	return func(o *options) {
+		o.runtime = rt
+	}
+}
+
+// WithOpener 注册driver对应的gorm.Dialector
+//
+// Deprecated: 租户db按database的driver注册表打开, 注册全局生效, 使用 database.RegisterDriver
+func WithOpener(driver string, open func(string) gorm.Dialector) Option {
+	return func(*options) {
+		database.RegisterDriver(driver, open)
+	}
+}
+
+// WithGormConfig 打开租户db使用的gorm配置
+func WithGormConfig(config *gorm.Config) Option {
+	return func(o *options) {
+		o.gormConfig = config
+	}
+}
+
+// WithEnforcer 为租户创建casbin, 如mycasbin.New; 不设置时不创建
+func WithEnforcer(f EnforcerFunc) Option {
+	return func(o *options) {
+		o.enforcer = f
+	}
+}
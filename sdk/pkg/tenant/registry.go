@@ -0,0 +1,209 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/casbin/casbin/v2"
+	"gorm.io/gorm"
+
+	log "github.com/alopt/go-admin-core/logger"
+)
+
+var (
+	ErrTenantExists   = errors.New("tenant: tenant already exists")
+	ErrTenantNotFound = errors.New("tenant: tenant not found")
+)
+
+// entry 已注册租户打开的资源
+type entry struct {
+	tenant   Tenant
+	db       *gorm.DB
+	enforcer *casbin.SyncedEnforcer
+	close    func()
+}
+
+// Registry 运行时增删租户, 租户定义保存在控制面db的sys_tenant表中,
+// db、casbin、config注册到runtime, 配合租户中间件使用; 租户的db、casbin优先于"*"的db、casbin
+type Registry struct {
+	db      *gorm.DB
+	opts    options
+	entries map[string]*entry
+	mux     sync.Mutex
+}
+
+// NewRegistry db为控制面的库, 需要先执行Migrate
+func NewRegistry(db *gorm.DB, opts ...Option) *Registry {
+	o := setDefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Registry{
+		db:      db,
+		opts:    o,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Load 打开控制面中保存的所有租户, 一般在启动时调用; 单个租户失败不影响其他租户
+func (e *Registry) Load(ctx context.Context) error {
+	var list []Tenant
+	if err := e.db.WithContext(ctx).Order("id").Find(&list).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for i := range list {
+		e.mux.Lock()
+		_, ok := e.entries[list[i].Name]
+		e.mux.Unlock()
+		if ok {
+			continue
+		}
+		if err := e.register(&list[i]); err != nil {
+			log.Errorf("tenant %s load error, %s", list[i].Name, err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", list[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Add 打开租户的db和casbin, 保存到控制面并注册到runtime
+func (e *Registry) Add(ctx context.Context, t *Tenant) error {
+	if t.Name == "" {
+		return errors.New("tenant: name is empty")
+	}
+	e.mux.Lock()
+	_, ok := e.entries[t.Name]
+	e.mux.Unlock()
+	if ok {
+		return ErrTenantExists
+	}
+	item, err := e.open(t)
+	if err != nil {
+		return err
+	}
+	if err = e.db.WithContext(ctx).Create(t).Error; err != nil {
+		item.release()
+		return err
+	}
+	item.tenant = *t
+	if !e.publish(item) {
+		item.release()
+		e.db.WithContext(ctx).Delete(t)
+		return ErrTenantExists
+	}
+	return nil
+}
+
+// Remove 从控制面删除租户, 从runtime移除并关闭其db和casbin
+func (e *Registry) Remove(ctx context.Context, name string) error {
+	e.mux.Lock()
+	item, ok := e.entries[name]
+	e.mux.Unlock()
+	if !ok {
+		return ErrTenantNotFound
+	}
+	if err := e.db.WithContext(ctx).Where("name = ?", name).Delete(&Tenant{}).Error; err != nil {
+		return err
+	}
+	e.mux.Lock()
+	delete(e.entries, name)
+	e.mux.Unlock()
+	e.opts.runtime.RemoveDb(name)
+	e.opts.runtime.RemoveCasbin(name)
+	e.opts.runtime.RemoveConfigByTenant(name)
+	item.release()
+	return nil
+}
+
+// Get 获取已注册的租户
+func (e *Registry) Get(name string) (Tenant, bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	item, ok := e.entries[name]
+	if !ok {
+		return Tenant{}, false
+	}
+	return item.tenant, true
+}
+
+// List 已注册的租户, 按名称排序
+func (e *Registry) List() []Tenant {
+	e.mux.Lock()
+	list := make([]Tenant, 0, len(e.entries))
+	for _, item := range e.entries {
+		list = append(list, item.tenant)
+	}
+	e.mux.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// register 打开并注册已保存的租户
+func (e *Registry) register(t *Tenant) error {
+	item, err := e.open(t)
+	if err != nil {
+		return err
+	}
+	item.tenant = *t
+	if !e.publish(item) {
+		item.release()
+	}
+	return nil
+}
+
+// open 打开租户的db和casbin
+func (e *Registry) open(t *Tenant) (*entry, error) {
+	db, err := t.Database().Open(e.opts.gormConfig)
+	if err != nil {
+		return nil, err
+	}
+	item := &entry{db: db}
+	if e.opts.enforcer != nil {
+		item.enforcer, item.close, err = e.opts.enforcer(t.Name, db)
+		if err != nil {
+			item.release()
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+// publish 注册到runtime, 同名租户已存在时返回false
+func (e *Registry) publish(item *entry) bool {
+	name := item.tenant.Name
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if _, ok := e.entries[name]; ok {
+		return false
+	}
+	e.entries[name] = item
+	e.opts.runtime.SetDb(name, item.db)
+	if item.enforcer != nil {
+		e.opts.runtime.SetCasbin(name, item.enforcer)
+	}
+	if item.tenant.Config != nil {
+		e.opts.runtime.SetConfigByTenant(name, item.tenant.Config)
+	}
+	return true
+}
+
+// release 关闭租户打开的资源
+func (e *entry) release() {
+	if e.enforcer != nil {
+		e.enforcer.StopAutoLoadPolicy()
+	}
+	if e.close != nil {
+		e.close()
+	}
+	if sqlDB, err := e.db.DB(); err == nil {
+		if err = sqlDB.Close(); err != nil {
+			log.Errorf("tenant close db error, %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alopt/go-admin-core/sdk/middleware"
+	"github.com/alopt/go-admin-core/sdk/pkg"
+	"github.com/alopt/go-admin-core/sdk/runtime"
+)
+
+func newRegistry(t *testing.T, rt runtime.Runtime, closed *int) *Registry {
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "control.db")), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return NewRegistry(db,
+		WithRuntime(rt),
+		WithGormConfig(config),
+		WithOpener("sqlite3", sqlite.Open),
+		WithEnforcer(func(tenant string, db *gorm.DB) (*casbin.SyncedEnforcer, func(), error) {
+			enforcer, err := newEnforcer()
+			return enforcer, func() { *closed++ }, err
+		}))
+}
+
+func newEnforcer() (*casbin.SyncedEnforcer, error) {
+	m, err := model.NewModelFromString("[request_definition]\nr = sub\n[policy_definition]\np = sub\n[policy_effect]\ne = some(where (p.eft == allow))\n[matchers]\nm = r.sub == p.sub")
+	if err != nil {
+		return nil, err
+	}
+	return casbin.NewSyncedEnforcer(m)
+}
+
+func TestRegistry(t *testing.T) {
+	rt := runtime.NewConfig()
+	var closed int
+	registry := newRegistry(t, rt, &closed)
+	dir := t.TempDir()
+	ctx := context.TODO()
+
+	a := &Tenant{
+		Name:   "a",
+		Driver: "sqlite3",
+		Source: filepath.Join(dir, "a.db"),
+		Config: map[string]interface{}{"title": "A"},
+	}
+	if err := registry.Add(ctx, a); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := registry.Add(ctx, &Tenant{Name: "a", Driver: "sqlite3", Source: filepath.Join(dir, "a.db")}); !errors.Is(err, ErrTenantExists) {
+		t.Errorf("Add() duplicate error = %v, want %v", err, ErrTenantExists)
+	}
+	if err := registry.Add(ctx, &Tenant{Name: "b", Driver: "oracle", Source: "b"}); err == nil {
+		t.Errorf("Add() unsupported driver error = nil")
+	}
+	if db := rt.GetDbByKey("a"); db == nil || db.Exec("create table t (id int)").Error != nil {
+		t.Fatalf("db of a not registered")
+	}
+	if rt.GetCasbinKey("a") == nil {
+		t.Errorf("casbin of a not registered")
+	}
+	if got := rt.GetConfig("a", "title"); got != "A" {
+		t.Errorf("config title = %v, want A", got)
+	}
+	if list := registry.List(); len(list) != 1 || list[0].Name != "a" {
+		t.Errorf("List() = %v", list)
+	}
+
+	// 新的实例从控制面恢复
+	other := runtime.NewConfig()
+	reload := NewRegistry(registry.db, WithRuntime(other), WithOpener("sqlite3", sqlite.Open),
+		WithGormConfig(registry.opts.gormConfig))
+	if err := reload.Load(ctx); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, ok := reload.Get("a"); !ok || other.GetDbByKey("a") == nil {
+		t.Errorf("Load() did not register a")
+	}
+	if got := other.GetConfig("a", "title"); got != "A" {
+		t.Errorf("reloaded config title = %v, want A", got)
+	}
+
+	if err := registry.Remove(ctx, "a"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := registry.Remove(ctx, "a"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("Remove() missing error = %v, want %v", err, ErrTenantNotFound)
+	}
+	if rt.GetDbByKey("a") != nil || rt.GetCasbinKey("a") != nil || rt.GetConfig("a", "title") != nil {
+		t.Errorf("a still registered in runtime")
+	}
+	if closed != 1 {
+		t.Errorf("enforcer closed %d times, want 1", closed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.db")); err != nil {
+		t.Errorf("tenant db file: %v", err)
+	}
+	var count int64
+	registry.db.Model(&Tenant{}).Count(&count)
+	if count != 0 {
+		t.Errorf("control plane still has %d tenants", count)
+	}
+}
+
+// TestRegistry_Shared 配置了database("*")时, 运行时添加的租户使用自己的db和casbin
+func TestRegistry_Shared(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rt := runtime.NewConfig()
+	var closed int
+	registry := newRegistry(t, rt, &closed)
+	rt.SetDb("*", registry.db)
+	shared, err := newEnforcer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.SetCasbin("*", shared)
+	ctx := context.TODO()
+	a := &Tenant{Name: "a", Driver: "sqlite3", Source: filepath.Join(t.TempDir(), "a.db")}
+	if err = registry.Add(ctx, a); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	rt.GetDbByKey("a").Exec("create table tenant (name text)").Exec("insert into tenant values ('a')")
+
+	r := gin.New()
+	r.GET("/", middleware.Tenant(middleware.WithTenantRuntime(rt),
+		middleware.WithTenantResolver(middleware.TenantByHeader("X-Tenant-Id"))), func(c *gin.Context) {
+		db, err := pkg.GetOrm(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var name string
+		db.Raw("select name from tenant").Scan(&name)
+		if name != "a" {
+			t.Errorf("db = %q, want a", name)
+		}
+		if enforcer, _ := pkg.GetCasbin(c); enforcer == nil || enforcer == shared {
+			t.Errorf("casbin of a not used")
+		}
+		c.Status(http.StatusOK)
+	})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant-Id", "a")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := get(); w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"code":404`) {
+		t.Fatalf("GET a = %d %s", w.Code, w.Body.String())
+	}
+	if err = registry.Remove(ctx, "a"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if w := get(); !strings.Contains(w.Body.String(), `"code":404`) {
+		t.Errorf("GET removed tenant = %s, want 404", w.Body.String())
+	}
+}
+
+func TestRegistry_Routers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var closed int
+	registry := newRegistry(t, runtime.NewConfig(), &closed)
+	r := gin.New()
+	registry.Routers(r.Group(""))
+	source := filepath.Join(t.TempDir(), "secret.db")
+
+	w := httptest.NewRecorder()
+	body := `{"name":"a","driver":"sqlite3","source":"` + source + `"}`
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/tenants", strings.NewReader(body)))
+	if !strings.Contains(w.Body.String(), `"name":"a"`) {
+		t.Fatalf("POST /tenants = %s", w.Body.String())
+	}
+	if list := registry.List(); len(list) != 1 || list[0].Source != source {
+		t.Fatalf("List() = %v, want source saved", list)
+	}
+	bodies := []string{w.Body.String()}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tenants", nil))
+	bodies = append(bodies, w.Body.String())
+	for _, b := range bodies {
+		if strings.Contains(b, source) || strings.Contains(b, `"source"`) {
+			t.Errorf("response leaks source: %s", b)
+		}
+	}
+}
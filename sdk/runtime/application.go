@@ -7,6 +7,7 @@ import (
 
 	"github.com/casbin/casbin/v2"
 	"github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/sdk/pkg/cronjob"
 	"github.com/alopt/go-admin-core/storage"
 	"github.com/alopt/go-admin-core/storage/queue"
 	"github.com/robfig/cron/v3"
@@ -18,6 +19,7 @@ type Application struct {
 	casbins       map[string]*casbin.SyncedEnforcer
 	engine        http.Handler
 	crontab       map[string]*cron.Cron
+	scheduler     *cronjob.Scheduler
 	mux           sync.RWMutex
 	middlewares   map[string]interface{}
 	cache         storage.AdapterCache
@@ -79,21 +81,42 @@ func (e *Application) SetDb(key string, db *gorm.DB) {
 	e.dbs[key] = db
 }
 
-// GetDb 获取所有map里的db数据
+// GetDb 获取所有map里的db数据, 返回副本, 可以与SetDb、RemoveDb并发
 func (e *Application) GetDb() map[string]*gorm.DB {
 	e.mux.Lock()
 	defer e.mux.Unlock()
-	return e.dbs
+	dbs := make(map[string]*gorm.DB, len(e.dbs))
+	for key, db := range e.dbs {
+		dbs[key] = db
+	}
+	return dbs
 }
 
-// GetDbByKey 根据key获取db
+// GetDbByKey 根据key获取db, key不存在时使用"*"的db
 func (e *Application) GetDbByKey(key string) *gorm.DB {
 	e.mux.Lock()
 	defer e.mux.Unlock()
-	if db, ok := e.dbs["*"]; ok {
+	if db, ok := e.dbs[key]; ok {
 		return db
 	}
-	return e.dbs[key]
+	return e.dbs["*"]
+}
+
+// LookupDb 根据key获取db, 不使用"*"的db
+func (e *Application) LookupDb(key string) (*gorm.DB, bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	db, ok := e.dbs[key]
+	return db, ok
+}
+
+// RemoveDb 移除并返回key的db
+func (e *Application) RemoveDb(key string) *gorm.DB {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	db := e.dbs[key]
+	delete(e.dbs, key)
+	return db
 }
 
 func (e *Application) SetCasbin(key string, enforcer *casbin.SyncedEnforcer) {
@@ -106,14 +129,23 @@ func (e *Application) GetCasbin() map[string]*casbin.SyncedEnforcer {
 	return e.casbins
 }
 
-// GetCasbinKey 根据key获取casbin
+// GetCasbinKey 根据key获取casbin, key不存在时使用"*"的casbin
 func (e *Application) GetCasbinKey(key string) *casbin.SyncedEnforcer {
 	e.mux.Lock()
 	defer e.mux.Unlock()
-	if e, ok := e.casbins["*"]; ok {
-		return e
+	if enforcer, ok := e.casbins[key]; ok {
+		return enforcer
 	}
-	return e.casbins[key]
+	return e.casbins["*"]
+}
+
+// RemoveCasbin 移除并返回key的casbin
+func (e *Application) RemoveCasbin(key string) *casbin.SyncedEnforcer {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	enforcer := e.casbins[key]
+	delete(e.casbins, key)
+	return enforcer
 }
 
 // SetEngine 设置路由引擎
@@ -192,6 +224,20 @@ func (e *Application) GetCrontabKey(key string) *cron.Cron {
 	return e.crontab[key]
 }
 
+// SetScheduler 设置分布式调度器
+func (e *Application) SetScheduler(scheduler *cronjob.Scheduler) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.scheduler = scheduler
+}
+
+// GetScheduler 获取分布式调度器
+func (e *Application) GetScheduler() *cronjob.Scheduler {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.scheduler
+}
+
 // SetMiddleware 设置中间件
 func (e *Application) SetMiddleware(key string, middleware interface{}) {
 	e.mux.Lock()
@@ -246,12 +292,13 @@ func (e *Application) SetLockerAdapter(c storage.AdapterLocker) {
 	e.locker = c
 }
 
-// GetLockerAdapter 获取分布式锁
-func (e *Application) GetLockerAdapter() storage.AdapterLocker {
+// GetLockerAdapter 获取分布式锁, 可直接使用LockCtx/WithLock
+func (e *Application) GetLockerAdapter() *Locker {
 	return NewLocker("", e.locker)
 }
 
-func (e *Application) GetLockerPrefix(key string) storage.AdapterLocker {
+// GetLockerPrefix 获取带租户前缀的分布式锁
+func (e *Application) GetLockerPrefix(key string) *Locker {
 	return NewLocker(key, e.locker)
 }
 
@@ -317,6 +364,13 @@ func (e *Application) GetConfigByTenant(tenant string) interface{} {
 	return e.configs[tenant]
 }
 
+// RemoveConfigByTenant 移除对应租户的config
+func (e *Application) RemoveConfigByTenant(tenant string) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	delete(e.configs, tenant)
+}
+
 // SetAppRouters 设置app的路由
 func (e *Application) SetAppRouters(appRouters func()) {
 	e.appRouters = append(e.appRouters, appRouters)
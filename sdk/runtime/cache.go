@@ -1,10 +1,12 @@
 package runtime
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
 	"github.com/chanxuehong/wechat/oauth2"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/alopt/go-admin-core/storage"
 )
@@ -44,6 +46,11 @@ func (e *Cache) SetPrefix(prefix string) {
 	e.prefix = prefix
 }
 
+// GetPrefix 获取前缀
+func (e Cache) GetPrefix() string {
+	return e.prefix
+}
+
 // Connect 初始化
 func (e Cache) Connect() error {
 	return nil
@@ -67,12 +74,12 @@ func (e Cache) Del(key string) error {
 
 // HashGet get val in hashtable cache
 func (e Cache) HashGet(hk, key string) (string, error) {
-	return e.store.HashGet(hk, e.prefix+intervalTenant+key)
+	return e.store.HashGet(e.prefix+intervalTenant+hk, key)
 }
 
 // HashDel delete one key:value pair in hashtable cache
 func (e Cache) HashDel(hk, key string) error {
-	return e.store.HashDel(hk, e.prefix+intervalTenant+key)
+	return e.store.HashDel(e.prefix+intervalTenant+hk, key)
 }
 
 // Increase value
@@ -88,6 +95,117 @@ func (e Cache) Expire(key string, dur time.Duration) error {
 	return e.store.Expire(e.prefix+intervalTenant+key, dur)
 }
 
+// GetCtx val in cache
+func (e Cache) GetCtx(ctx context.Context, key string) (string, error) {
+	return e.store.GetCtx(ctx, e.prefix+intervalTenant+key)
+}
+
+// SetCtx val in cache
+func (e Cache) SetCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	return e.store.SetCtx(ctx, e.prefix+intervalTenant+key, val, expire)
+}
+
+// DelCtx delete keys in cache
+func (e Cache) DelCtx(ctx context.Context, keys ...string) error {
+	return e.store.DelCtx(ctx, e.keys(keys)...)
+}
+
+func (e Cache) ExpireCtx(ctx context.Context, key string, dur time.Duration) error {
+	return e.store.ExpireCtx(ctx, e.prefix+intervalTenant+key, dur)
+}
+
+// MGet values in cache, the result is keyed without prefix
+func (e Cache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values, err := e.store.MGet(ctx, e.keys(keys)...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(values))
+	for i := range keys {
+		if v, ok := values[e.prefix+intervalTenant+keys[i]]; ok {
+			result[keys[i]] = v
+		}
+	}
+	return result, nil
+}
+
+// MSet values in cache
+func (e Cache) MSet(ctx context.Context, values map[string]interface{}, expire time.Duration) error {
+	data := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		data[e.prefix+intervalTenant+k] = v
+	}
+	return e.store.MSet(ctx, data, expire)
+}
+
+func (e Cache) SetNX(ctx context.Context, key string, val interface{}, expire time.Duration) (bool, error) {
+	return e.store.SetNX(ctx, e.prefix+intervalTenant+key, val, expire)
+}
+
+func (e Cache) GetSet(ctx context.Context, key string, val interface{}) (string, error) {
+	return e.store.GetSet(ctx, e.prefix+intervalTenant+key, val)
+}
+
+func (e Cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return e.store.TTL(ctx, e.prefix+intervalTenant+key)
+}
+
+func (e Cache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	return e.store.Exists(ctx, e.keys(keys)...)
+}
+
+// IncrBy increase value and return it
+func (e Cache) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return e.store.IncrBy(ctx, e.prefix+intervalTenant+key, n)
+}
+
+// DecrBy decrease value and return it
+func (e Cache) DecrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return e.store.DecrBy(ctx, e.prefix+intervalTenant+key, n)
+}
+
+// HashSet set fields in hashtable cache
+func (e Cache) HashSet(ctx context.Context, hk string, values map[string]interface{}) error {
+	return e.store.HashSet(ctx, e.prefix+intervalTenant+hk, values)
+}
+
+// HashGetAll get all fields in hashtable cache
+func (e Cache) HashGetAll(ctx context.Context, hk string) (map[string]string, error) {
+	return e.store.HashGetAll(ctx, e.prefix+intervalTenant+hk)
+}
+
+// HashKeys get all field names in hashtable cache
+func (e Cache) HashKeys(ctx context.Context, hk string) ([]string, error) {
+	return e.store.HashKeys(ctx, e.prefix+intervalTenant+hk)
+}
+
+// HashLen count fields in hashtable cache
+func (e Cache) HashLen(ctx context.Context, hk string) (int64, error) {
+	return e.store.HashLen(ctx, e.prefix+intervalTenant+hk)
+}
+
+// HashIncrBy increase field in hashtable cache
+func (e Cache) HashIncrBy(ctx context.Context, hk, key string, incr int64) (int64, error) {
+	return e.store.HashIncrBy(ctx, e.prefix+intervalTenant+hk, key, incr)
+}
+
+// keys 批量加前缀
+func (e Cache) keys(keys []string) []string {
+	list := make([]string, len(keys))
+	for i := range keys {
+		list[i] = e.prefix + intervalTenant + keys[i]
+	}
+	return list
+}
+
+// GetClient 底层缓存为redis时暴露原生client, 否则返回nil
+func (e Cache) GetClient() *redis.Client {
+	if c, ok := e.store.(interface{ GetClient() *redis.Client }); ok {
+		return c.GetClient()
+	}
+	return nil
+}
+
 // Token 获取微信oauth2 token
 func (e Cache) Token() (token *oauth2.Token, err error) {
 	var str string
@@ -1,13 +1,16 @@
 package runtime
 
 import (
-	"github.com/bsm/redislock"
+	"context"
 
 	"github.com/alopt/go-admin-core/storage"
+	"github.com/alopt/go-admin-core/storage/locker"
 )
 
+var _ storage.AdapterLocker = (*Locker)(nil)
+
 // NewLocker 创建对应上下文分布式锁
-func NewLocker(prefix string, locker storage.AdapterLocker) storage.AdapterLocker {
+func NewLocker(prefix string, locker storage.AdapterLocker) *Locker {
 	return &Locker{
 		prefix: prefix,
 		locker: locker,
@@ -24,6 +27,17 @@ func (e *Locker) String() string {
 }
 
 // Lock 返回分布式锁对象
-func (e *Locker) Lock(key string, ttl int64, options *redislock.Options) (*redislock.Lock, error) {
-	return e.locker.Lock(e.prefix+intervalTenant+key, ttl, options)
+func (e *Locker) Lock(key string, ttl int64, opts ...storage.LockOption) (storage.Lock, error) {
+	return e.locker.Lock(e.prefix+intervalTenant+key, ttl, opts...)
+}
+
+// LockCtx 等待直到获得锁或ctx结束
+func (e *Locker) LockCtx(ctx context.Context, key string, opts ...storage.LockOption) (storage.Lock, error) {
+	return e.locker.LockCtx(ctx, e.prefix+intervalTenant+key, opts...)
+}
+
+// WithLock 获取锁后执行fn, 持有期间自动续期, 锁丢失时取消fn的ctx
+func (e *Locker) WithLock(ctx context.Context, key string,
+	fn func(ctx context.Context) error, opts ...storage.LockOption) error {
+	return locker.WithLock(ctx, e, key, fn, opts...)
 }
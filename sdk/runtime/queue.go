@@ -1,6 +1,12 @@
 package runtime
 
-import "github.com/alopt/go-admin-core/storage"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alopt/go-admin-core/storage"
+)
 
 // NewQueue 创建对应上下文队列
 func NewQueue(prefix string, queue storage.AdapterQueue) storage.AdapterQueue {
@@ -20,18 +26,52 @@ func (e *Queue) String() string {
 }
 
 // Register 注册消费者
-func (e *Queue) Register(name string, f storage.ConsumerFunc) {
-	e.queue.Register(name, f)
+func (e *Queue) Register(name string, f storage.ConsumerFunc, opts ...storage.RegisterOption) {
+	e.queue.Register(name, f, opts...)
+}
+
+// RegisterCtx 注册带context的消费者
+func (e *Queue) RegisterCtx(name string, f storage.ConsumerCtxFunc, opts ...storage.RegisterOption) {
+	e.queue.RegisterCtx(name, f, opts...)
+}
+
+// DeadLetters 查看死信, 队列不支持时返回错误
+func (e *Queue) DeadLetters(stream string, limit int) ([]storage.Messager, error) {
+	q, ok := e.queue.(storage.AdapterDeadLetter)
+	if !ok {
+		return nil, fmt.Errorf("queue %s not support dead letter", e.queue.String())
+	}
+	return q.DeadLetters(stream, limit)
+}
+
+// Replay 重放死信, 队列不支持时返回错误
+func (e *Queue) Replay(stream string, limit int) (int, error) {
+	q, ok := e.queue.(storage.AdapterDeadLetter)
+	if !ok {
+		return 0, fmt.Errorf("queue %s not support dead letter", e.queue.String())
+	}
+	return q.Replay(stream, limit)
 }
 
 // Append 增加数据到生产者
 func (e *Queue) Append(message storage.Messager) error {
-	values := message.GetValues()
-	if values == nil {
-		values = make(map[string]interface{})
-	}
-	values[storage.PrefixKey] = e.prefix
-	return e.queue.Append(message)
+	return e.queue.Append(e.withPrefix(message))
+}
+
+// AppendDelay 延迟delay后投递
+func (e *Queue) AppendDelay(message storage.Messager, delay time.Duration) error {
+	return e.queue.AppendDelay(e.withPrefix(message), delay)
+}
+
+// AppendAt 在指定时间投递
+func (e *Queue) AppendAt(message storage.Messager, at time.Time) error {
+	return e.queue.AppendAt(e.withPrefix(message), at)
+}
+
+// withPrefix 租户前缀写入消息头 storage.HeaderTenant
+func (e *Queue) withPrefix(message storage.Messager) storage.Messager {
+	message.SetPrefix(e.prefix)
+	return message
 }
 
 // Run 运行
@@ -45,3 +85,11 @@ func (e *Queue) Shutdown() {
 		e.queue.Shutdown()
 	}
 }
+
+// ShutdownCtx 停止并等待处理中的消息完成
+func (e *Queue) ShutdownCtx(ctx context.Context) error {
+	if e.queue == nil {
+		return nil
+	}
+	return e.queue.ShutdownCtx(ctx)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/casbin/casbin/v2"
 	"github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/sdk/pkg/cronjob"
 	"github.com/alopt/go-admin-core/storage"
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
@@ -15,7 +16,12 @@ type Runtime interface {
 	// SetDb 多db设置，⚠️SetDbs不允许并发,可以根据自己的业务，例如app分库、host分库
 	SetDb(key string, db *gorm.DB)
 	GetDb() map[string]*gorm.DB
+	// GetDbByKey key不存在时使用"*"的db
 	GetDbByKey(key string) *gorm.DB
+	// LookupDb 只按key查找, 不使用"*"的db
+	LookupDb(key string) (*gorm.DB, bool)
+	// RemoveDb 移除并返回key的db, 由调用方关闭连接
+	RemoveDb(key string) *gorm.DB
 
 	SetBefore(f func())
 	GetBefore() []func()
@@ -27,6 +33,7 @@ type Runtime interface {
 	SetCasbin(key string, enforcer *casbin.SyncedEnforcer)
 	GetCasbin() map[string]*casbin.SyncedEnforcer
 	GetCasbinKey(key string) *casbin.SyncedEnforcer
+	RemoveCasbin(key string) *casbin.SyncedEnforcer
 
 	// SetEngine 使用的路由
 	SetEngine(engine http.Handler)
@@ -42,6 +49,9 @@ type Runtime interface {
 	SetCrontab(key string, crontab *cron.Cron)
 	GetCrontab() map[string]*cron.Cron
 	GetCrontabKey(key string) *cron.Cron
+	// SetScheduler 分布式调度器, 集群中每次触发只执行一次
+	SetScheduler(scheduler *cronjob.Scheduler)
+	GetScheduler() *cronjob.Scheduler
 
 	// SetMiddleware middleware
 	SetMiddleware(string, interface{})
@@ -59,8 +69,8 @@ type Runtime interface {
 	GetQueuePrefix(string) storage.AdapterQueue
 
 	SetLockerAdapter(storage.AdapterLocker)
-	GetLockerAdapter() storage.AdapterLocker
-	GetLockerPrefix(string) storage.AdapterLocker
+	GetLockerAdapter() *Locker
+	GetLockerPrefix(string) *Locker
 
 	SetHandler(key string, routerGroup func(r *gin.RouterGroup, hand ...*gin.HandlerFunc))
 	GetHandler() map[string][]func(r *gin.RouterGroup, hand ...*gin.HandlerFunc)
@@ -72,6 +82,7 @@ type Runtime interface {
 	GetConfig(tenant, key string) interface{}
 	SetConfigByTenant(tenant string, value map[string]interface{})
 	SetConfig(tenant, key string, value interface{})
+	RemoveConfigByTenant(tenant string)
 
 	// SetAppRouters set AppRouter
 	SetAppRouters(appRouters func())
@@ -1,10 +1,14 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/alopt/go-admin-core/logger"
 	"github.com/alopt/go-admin-core/storage"
+	"github.com/alopt/go-admin-core/storage/cache"
+	"github.com/alopt/go-admin-core/storage/outbox"
 	"gorm.io/gorm"
 )
 
@@ -25,3 +29,14 @@ func (db *Service) AddError(err error) error {
 	}
 	return db.Error
 }
+
+// GetOrLoad 读取缓存, 未命中时调用load加载并写入缓存, 并发加载同一个key只执行一次
+func (db *Service) GetOrLoad(ctx context.Context, key string, ttl time.Duration,
+	load cache.LoadFunc, opts ...cache.LoadOption) (string, error) {
+	return cache.GetOrLoad(ctx, db.Cache, key, ttl, load, opts...)
+}
+
+// AppendOutbox 通过Orm写入outbox, Orm为事务时随事务提交, 由outbox.Relay发布到队列
+func (db *Service) AppendOutbox(message storage.Messager) error {
+	return outbox.Append(db.Orm, message)
+}
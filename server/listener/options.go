@@ -8,7 +8,9 @@
 package listener
 
 import (
+	"context"
 	"net/http"
+	"time"
 )
 
 // Option 参数设置类型
@@ -19,6 +21,14 @@ type options struct {
 	handler                 http.Handler
 	startedHook             func()
 	endHook                 func()
+	checks                  []check
+	checkTimeout            time.Duration
+}
+
+// check 就绪检查项
+type check struct {
+	name string
+	f    func(ctx context.Context) error
 }
 
 func setDefaultOption() options {
@@ -27,6 +37,7 @@ func setDefaultOption() options {
 		handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		}),
+		checkTimeout: 3 * time.Second,
 	}
 }
 
@@ -70,3 +81,17 @@ func WithKey(s string) Option {
 		o.keyFile = s
 	}
 }
+
+// WithCheck 添加就绪检查项, 用于NewReadyz, 任一失败时返回503
+func WithCheck(name string, f func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.checks = append(o.checks, check{name: name, f: f})
+	}
+}
+
+// WithCheckTimeout 就绪检查超时时间, 默认3s
+func WithCheckTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.checkTimeout = timeout
+	}
+}
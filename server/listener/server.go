@@ -11,6 +11,7 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"strings"
 
 	log "github.com/alopt/go-admin-core/logger"
 	"github.com/alopt/go-admin-core/server"
@@ -72,14 +73,31 @@ func NewReadyz(opts ...Option) server.Runnable {
 	}
 	s.opts.addr = ":2000"
 	h := http.NewServeMux()
-	h.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+	h.HandleFunc("/readyz", s.readyz)
 	s.opts.handler = h
 	s.Options(opts...)
 	return s
 }
 
+// readyz 依次执行就绪检查, 返回失败的检查项
+func (e *Server) readyz(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), e.opts.checkTimeout)
+	defer cancel()
+	var failed []string
+	for _, c := range e.opts.checks {
+		if err := c.f(ctx); err != nil {
+			log.Errorf("readyz check %s failed, %s", c.name, err.Error())
+			failed = append(failed, c.name+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(strings.Join(failed, "\n")))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 // Options 设置参数
 func (e *Server) Options(opts ...Option) {
 	for _, o := range opts {
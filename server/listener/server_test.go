@@ -0,0 +1,40 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewReadyz(t *testing.T) {
+	var ready error
+	s := NewReadyz(
+		WithCheck("ok", func(ctx context.Context) error { return nil }),
+		WithCheck("database", func(ctx context.Context) error { return ready }),
+	).(*Server)
+	tests := []struct {
+		name  string
+		ready error
+		code  int
+		body  string
+	}{
+		{"ready", nil, http.StatusOK, ""},
+		{"not ready", errors.New("connection refused"), http.StatusServiceUnavailable, "database: connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready = tt.ready
+			w := httptest.NewRecorder()
+			s.opts.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.code {
+				t.Errorf("code = %d, want %d", w.Code, tt.code)
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
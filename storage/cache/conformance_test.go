@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// adapters 同一组行为测试在所有cache实现上运行
+func adapters(t *testing.T) map[string]storage.AdapterCache {
+	s := miniredis.RunT(t)
+	r, err := NewRedis(nil, &redis.Options{Addr: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory(WithName("conformance"))
+	t.Cleanup(m.Stop)
+	tiered := newTestTiered(t, miniredis.RunT(t).Addr(), "conformance_tiered")
+	return map[string]storage.AdapterCache{
+		"memory": m,
+		"redis":  r,
+		"tiered": tiered,
+	}
+}
+
+func TestConformance(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c storage.AdapterCache)
+	}{
+		{"GetSet", testGetSet},
+		{"TTL", testTTL},
+		{"SetNX", testSetNX},
+		{"MultiKey", testMultiKey},
+		{"IncrBy", testIncrBy},
+		{"Hash", testHash},
+	}
+	for name, c := range adapters(t) {
+		for _, tt := range tests {
+			c := c
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				tt.run(t, c)
+			})
+		}
+	}
+}
+
+func testGetSet(t *testing.T, c storage.AdapterCache) {
+	ctx := context.Background()
+	if _, err := c.GetCtx(ctx, "gs"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetCtx() error = %v, want %v", err, storage.ErrNotFound)
+	}
+	if err := c.SetCtx(ctx, "gs", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetCtx(ctx, "gs"); err != nil || got != "1" {
+		t.Fatalf("GetCtx() = %v, %v, want 1", got, err)
+	}
+	if old, err := c.GetSet(ctx, "gs", "2"); err != nil || old != "1" {
+		t.Fatalf("GetSet() = %v, %v, want 1", old, err)
+	}
+	if old, err := c.GetSet(ctx, "gs_missing", "2"); err != nil || old != "" {
+		t.Fatalf("GetSet() = %v, %v, want empty", old, err)
+	}
+	if err := c.DelCtx(ctx, "gs", "gs_missing"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Exists(ctx, "gs", "gs_missing"); err != nil || n != 0 {
+		t.Fatalf("Exists() = %v, %v, want 0", n, err)
+	}
+}
+
+func testTTL(t *testing.T, c storage.AdapterCache) {
+	ctx := context.Background()
+	if _, err := c.TTL(ctx, "ttl"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("TTL() error = %v, want %v", err, storage.ErrNotFound)
+	}
+	if err := c.ExpireCtx(ctx, "ttl", time.Minute); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("ExpireCtx() error = %v, want %v", err, storage.ErrNotFound)
+	}
+	_ = c.SetCtx(ctx, "ttl", "v", 0)
+	if got, err := c.TTL(ctx, "ttl"); err != nil || got != -1 {
+		t.Fatalf("TTL() = %v, %v, want -1", got, err)
+	}
+	if err := c.ExpireCtx(ctx, "ttl", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.TTL(ctx, "ttl"); err != nil || got <= 0 || got > time.Minute {
+		t.Fatalf("TTL() = %v, %v, want (0, 1m]", got, err)
+	}
+}
+
+func testSetNX(t *testing.T, c storage.AdapterCache) {
+	ctx := context.Background()
+	if ok, err := c.SetNX(ctx, "nx", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("SetNX() = %v, %v, want true", ok, err)
+	}
+	if ok, err := c.SetNX(ctx, "nx", "b", time.Minute); err != nil || ok {
+		t.Fatalf("SetNX() = %v, %v, want false", ok, err)
+	}
+	if got, _ := c.GetCtx(ctx, "nx"); got != "a" {
+		t.Fatalf("GetCtx() got = %v, want a", got)
+	}
+}
+
+func testMultiKey(t *testing.T, c storage.AdapterCache) {
+	ctx := context.Background()
+	if err := c.MSet(ctx, map[string]interface{}{"m1": "1", "m2": 2}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.MGet(ctx, "m1", "m2", "m3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["m1"] != "1" || got["m2"] != "2" {
+		t.Fatalf("MGet() got = %v", got)
+	}
+	if n, err := c.Exists(ctx, "m1", "m2", "m3"); err != nil || n != 2 {
+		t.Fatalf("Exists() = %v, %v, want 2", n, err)
+	}
+}
+
+func testIncrBy(t *testing.T, c storage.AdapterCache) {
+	ctx := context.Background()
+	if n, err := c.IncrBy(ctx, "counter", 5); err != nil || n != 5 {
+		t.Fatalf("IncrBy() = %v, %v, want 5", n, err)
+	}
+	if n, err := c.DecrBy(ctx, "counter", 2); err != nil || n != 3 {
+		t.Fatalf("DecrBy() = %v, %v, want 3", n, err)
+	}
+	if err := c.Increase("counter_legacy"); err != nil {
+		t.Fatalf("Increase() on missing key error = %v", err)
+	}
+	if got, _ := c.GetCtx(ctx, "counter_legacy"); got != "1" {
+		t.Fatalf("GetCtx() got = %v, want 1", got)
+	}
+	_ = c.SetCtx(ctx, "counter_ttl", 10, time.Minute)
+	if n, err := c.IncrBy(ctx, "counter_ttl", 1); err != nil || n != 11 {
+		t.Fatalf("IncrBy() = %v, %v, want 11", n, err)
+	}
+	if ttl, err := c.TTL(ctx, "counter_ttl"); err != nil || ttl <= 0 {
+		t.Fatalf("TTL() = %v, %v, want ttl kept", ttl, err)
+	}
+	_ = c.SetCtx(ctx, "counter_str", "abc", time.Minute)
+	if _, err := c.IncrBy(ctx, "counter_str", 1); err == nil {
+		t.Fatal("IncrBy() on non integer should return error")
+	}
+}
+
+func testHash(t *testing.T, c storage.AdapterCache) {
+	ctx := context.Background()
+	if err := c.HashSet(ctx, "hash", map[string]interface{}{"a": "1", "b": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.HashIncrBy(ctx, "hash", "c", 3); err != nil || n != 3 {
+		t.Fatalf("HashIncrBy() = %v, %v, want 3", n, err)
+	}
+	if v, err := c.HashGet("hash", "b"); err != nil || v != "2" {
+		t.Fatalf("HashGet() = %v, %v, want 2", v, err)
+	}
+	keys, err := c.HashKeys(ctx, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 3 || keys[0] != "a" || keys[2] != "c" {
+		t.Fatalf("HashKeys() got = %v", keys)
+	}
+	if err = c.HashDel("hash", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.HashLen(ctx, "hash"); err != nil || n != 2 {
+		t.Fatalf("HashLen() = %v, %v, want 2", n, err)
+	}
+	all, err := c.HashGetAll(ctx, "hash_missing")
+	if err != nil || len(all) != 0 {
+		t.Fatalf("HashGetAll() = %v, %v, want empty", all, err)
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// evictor 记录key的访问情况, 选出需要淘汰的key
+type evictor interface {
+	add(key string)
+	access(key string)
+	remove(key string)
+	victim() (string, bool)
+}
+
+func newEvictor(e Eviction) evictor {
+	if e == EvictionLFU {
+		return newLFU()
+	}
+	return newLRU()
+}
+
+type lru struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (e *lru) add(key string) {
+	if el, ok := e.items[key]; ok {
+		e.ll.MoveToFront(el)
+		return
+	}
+	e.items[key] = e.ll.PushFront(key)
+}
+
+func (e *lru) access(key string) {
+	if el, ok := e.items[key]; ok {
+		e.ll.MoveToFront(el)
+	}
+}
+
+func (e *lru) remove(key string) {
+	if el, ok := e.items[key]; ok {
+		e.ll.Remove(el)
+		delete(e.items, key)
+	}
+}
+
+func (e *lru) victim() (string, bool) {
+	el := e.ll.Back()
+	if el == nil {
+		return "", false
+	}
+	return el.Value.(string), true
+}
+
+type lfuEntry struct {
+	key   string
+	freq  int64
+	seq   int64
+	index int
+}
+
+// lfuHeap 访问次数最少的在堆顶, 次数相同时先淘汰最久未访问的
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].freq < h[j].freq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+type lfu struct {
+	heap  lfuHeap
+	items map[string]*lfuEntry
+	seq   int64
+}
+
+func newLFU() *lfu {
+	return &lfu{
+		items: make(map[string]*lfuEntry),
+	}
+}
+
+func (e *lfu) add(key string) {
+	if _, ok := e.items[key]; ok {
+		e.access(key)
+		return
+	}
+	e.seq++
+	entry := &lfuEntry{key: key, freq: 1, seq: e.seq}
+	e.items[key] = entry
+	heap.Push(&e.heap, entry)
+}
+
+func (e *lfu) access(key string) {
+	entry, ok := e.items[key]
+	if !ok {
+		return
+	}
+	e.seq++
+	entry.freq++
+	entry.seq = e.seq
+	heap.Fix(&e.heap, entry.index)
+}
+
+func (e *lfu) remove(key string) {
+	entry, ok := e.items[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&e.heap, entry.index)
+	delete(e.items, key)
+}
+
+func (e *lfu) victim() (string, bool) {
+	if len(e.heap) == 0 {
+		return "", false
+	}
+	return e.heap[0].key, true
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	json "github.com/json-iterator/go"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// SetJSON 序列化为json后写入缓存
+func SetJSON(ctx context.Context, c storage.AdapterCache, key string, v interface{}, expire time.Duration) error {
+	rb, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.SetCtx(ctx, key, string(rb), expire)
+}
+
+// GetJSON 读取缓存并反序列化为T, key不存在时返回storage.ErrNotFound
+func GetJSON[T any](ctx context.Context, c storage.AdapterCache, key string) (T, error) {
+	var v T
+	s, err := c.GetCtx(ctx, key)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal([]byte(s), &v)
+	return v, err
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"golang.org/x/sync/singleflight"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+const (
+	// notFoundPlaceholder 负缓存占位值
+	notFoundPlaceholder = "\x00go-admin:not-found"
+	// defaultLoadTimeout 共享加载的默认超时时间
+	defaultLoadTimeout = 30 * time.Second
+)
+
+// LoadFunc 缓存未命中时加载数据, 数据不存在时返回 storage.ErrNotFound
+type LoadFunc func(ctx context.Context) (string, error)
+
+// LoadOption GetOrLoad参数设置类型
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	negativeTTL  time.Duration
+	refreshAhead time.Duration
+	timeout      time.Duration
+}
+
+// WithNegativeTTL 加载结果为 storage.ErrNotFound 时缓存该结果d时长, 防止缓存穿透
+func WithNegativeTTL(d time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.negativeTTL = d
+	}
+}
+
+// WithRefreshAhead 剩余ttl小于d时后台刷新, 当前请求仍返回旧值
+func WithRefreshAhead(d time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.refreshAhead = d
+	}
+}
+
+// WithLoadTimeout 共享加载的超时时间, 默认30s
+// 加载与发起请求的ctx分离, 单个请求取消不会影响其他等待者
+func WithLoadTimeout(d time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.timeout = d
+	}
+}
+
+// prefixer runtime.Cache 等带租户前缀的缓存, 用于区分相同key
+type prefixer interface {
+	GetPrefix() string
+}
+
+var loadGroup singleflight.Group
+
+// GetOrLoad 读取缓存, 未命中时调用load加载并写入缓存
+// 同一个key的并发加载只会执行一次load
+func GetOrLoad(ctx context.Context, c storage.AdapterCache, key string, ttl time.Duration,
+	load LoadFunc, opts ...LoadOption) (string, error) {
+	o := loadOptions{timeout: defaultLoadTimeout}
+	for i := range opts {
+		opts[i](&o)
+	}
+	val, err := c.GetCtx(ctx, key)
+	if err == nil {
+		if val == notFoundPlaceholder {
+			return "", storage.ErrNotFound
+		}
+		if o.refreshAhead > 0 {
+			refreshAhead(c, key, ttl, load, o)
+		}
+		return val, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	ch := loadGroup.DoChan(flightKey(c, key), func() (interface{}, error) {
+		return loadDetached(c, key, ttl, load, o)
+	})
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return "", r.Err
+		}
+		return r.Val.(string), nil
+	}
+}
+
+// GetOrLoadJSON GetOrLoad的json类型版本
+func GetOrLoadJSON[T any](ctx context.Context, c storage.AdapterCache, key string, ttl time.Duration,
+	load func(ctx context.Context) (T, error), opts ...LoadOption) (T, error) {
+	var v T
+	s, err := GetOrLoad(ctx, c, key, ttl, func(ctx context.Context) (string, error) {
+		data, err := load(ctx)
+		if err != nil {
+			return "", err
+		}
+		rb, err := json.Marshal(data)
+		return string(rb), err
+	}, opts...)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal([]byte(s), &v)
+	return v, err
+}
+
+// flightKey 按缓存实例区分, 同类型的不同实例不共享加载结果
+func flightKey(c storage.AdapterCache, key string) string {
+	id := c.String()
+	if v := reflect.ValueOf(c); v.Kind() == reflect.Ptr {
+		id = fmt.Sprintf("%s@%x", id, v.Pointer())
+	}
+	if p, ok := c.(prefixer); ok {
+		return id + ":" + p.GetPrefix() + key
+	}
+	return id + ":" + key
+}
+
+// loadDetached 在独立的ctx上加载, 超时时间为o.timeout
+func loadDetached(c storage.AdapterCache, key string, ttl time.Duration,
+	load LoadFunc, o loadOptions) (interface{}, error) {
+	ctx := context.Background()
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	return loadAndSet(ctx, c, key, ttl, load, o)
+}
+
+func loadAndSet(ctx context.Context, c storage.AdapterCache, key string, ttl time.Duration,
+	load LoadFunc, o loadOptions) (interface{}, error) {
+	val, err := load(ctx)
+	if errors.Is(err, storage.ErrNotFound) && o.negativeTTL > 0 {
+		if err := c.SetCtx(ctx, key, notFoundPlaceholder, o.negativeTTL); err != nil {
+			log.Errorf("cache set %s error: %s", key, err.Error())
+		}
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = c.SetCtx(ctx, key, val, ttl); err != nil {
+		log.Errorf("cache set %s error: %s", key, err.Error())
+	}
+	return val, nil
+}
+
+// refreshAhead 快过期时后台刷新, 不影响当前请求
+func refreshAhead(c storage.AdapterCache, key string, ttl time.Duration, load LoadFunc, o loadOptions) {
+	remain, err := c.TTL(context.Background(), key)
+	if err != nil || remain < 0 || remain > o.refreshAhead {
+		return
+	}
+	go func() {
+		_, _, _ = loadGroup.Do(flightKey(c, key), func() (interface{}, error) {
+			val, err := loadDetached(c, key, ttl, load, o)
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Errorf("cache refresh %s error: %s", key, err.Error())
+			}
+			return val, err
+		})
+	}()
+}
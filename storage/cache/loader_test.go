@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithName("test_loader"))
+	defer m.Stop()
+	var calls int32
+	load := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := GetOrLoad(ctx, m, "dict", time.Minute, load)
+			if err != nil || got != "value" {
+				t.Errorf("GetOrLoad() = %v, %v, want value", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("load called %d times, want 1", calls)
+	}
+	if got, _ := m.GetCtx(ctx, "dict"); got != "value" {
+		t.Errorf("GetCtx() got = %v, want value", got)
+	}
+}
+
+func TestGetOrLoad_Negative(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithName("test_loader_negative"))
+	defer m.Stop()
+	var calls int32
+	load := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", storage.ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := GetOrLoad(ctx, m, "missing", time.Minute, load, WithNegativeTTL(time.Minute)); err != storage.ErrNotFound {
+			t.Fatalf("GetOrLoad() error = %v, want %v", err, storage.ErrNotFound)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("load called %d times, want 1", calls)
+	}
+}
+
+func TestGetOrLoad_RefreshAhead(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithName("test_loader_refresh"))
+	defer m.Stop()
+	_ = m.SetCtx(ctx, "config", "old", 5*time.Second)
+	refreshed := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		defer close(refreshed)
+		return "new", nil
+	}
+	got, err := GetOrLoad(ctx, m, "config", time.Minute, load, WithRefreshAhead(10*time.Second))
+	if err != nil || got != "old" {
+		t.Fatalf("GetOrLoad() = %v, %v, want old", got, err)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("refresh ahead not triggered")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got, _ = m.GetCtx(ctx, "config"); got != "new" {
+		t.Errorf("GetCtx() got = %v, want new", got)
+	}
+}
+
+func TestGetOrLoadJSON(t *testing.T) {
+	type dict struct {
+		Label string
+		Value int
+	}
+	ctx := context.Background()
+	m := NewMemory(WithName("test_loader_json"))
+	defer m.Stop()
+	got, err := GetOrLoadJSON(ctx, m, "dict:1", time.Minute, func(ctx context.Context) (dict, error) {
+		return dict{Label: "enable", Value: 1}, nil
+	})
+	if err != nil || got.Label != "enable" || got.Value != 1 {
+		t.Errorf("GetOrLoadJSON() = %v, %v", got, err)
+	}
+}
+
+func TestGetOrLoad_CallerCancel(t *testing.T) {
+	m := NewMemory(WithName("test_loader_cancel"))
+	defer m.Stop()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		close(started)
+		select {
+		case <-release:
+			return "value", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	first, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := GetOrLoad(first, m, "shared", time.Minute, load)
+		errc <- err
+	}()
+	<-started
+	done := make(chan string, 1)
+	go func() {
+		got, err := GetOrLoad(context.Background(), m, "shared", time.Minute, load)
+		if err != nil {
+			t.Errorf("GetOrLoad() error = %v", err)
+		}
+		done <- got
+	}()
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("first caller error = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	if got := <-done; got != "value" {
+		t.Errorf("waiter got = %v, want value", got)
+	}
+}
+
+func TestGetOrLoad_Instances(t *testing.T) {
+	ctx := context.Background()
+	a := NewMemory(WithName("test_loader_a"))
+	defer a.Stop()
+	b := NewMemory(WithName("test_loader_b"))
+	defer b.Stop()
+	release := make(chan struct{})
+	load := func(v string) LoadFunc {
+		return func(ctx context.Context) (string, error) {
+			<-release
+			return v, nil
+		}
+	}
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+	values := []string{"a", "b"}
+	for i, c := range []*Memory{a, b} {
+		wg.Add(1)
+		go func(i int, c *Memory) {
+			defer wg.Done()
+			results[i], _ = GetOrLoad(ctx, c, "same", time.Minute, load(values[i]))
+		}(i, c)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if results[0] != "a" || results[1] != "b" {
+		t.Errorf("results = %v, want each instance's own value", results)
+	}
+}
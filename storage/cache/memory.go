@@ -1,29 +1,85 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cast"
+
+	"github.com/alopt/go-admin-core/storage"
 )
 
 type item struct {
 	Value   string
+	Hash    map[string]string
 	Expired time.Time
 }
 
+// expired zero Expired means the item never expires
+func (e *item) expired(now time.Time) bool {
+	return !e.Expired.IsZero() && e.Expired.Before(now)
+}
+
+// size key与value的长度之和, 用于容量限制
+func (e *item) size(key string) int64 {
+	n := len(key) + len(e.Value)
+	for k, v := range e.Hash {
+		n += len(k) + len(v)
+	}
+	return int64(n)
+}
+
+type entry struct {
+	item *item
+	size int64
+}
+
 // NewMemory memory模式
-func NewMemory() *Memory {
-	return &Memory{
-		items: new(sync.Map),
+// 默认不限制容量, 首次写入带有效期的key时启动后台清理(每分钟一次);
+// 不再使用时需调用Stop, 停止后台清理并从items指标中移除该实例的key数量
+func NewMemory(opts ...Option) *Memory {
+	m := &Memory{
+		items: make(map[string]*entry),
+		opts:  setDefaultOptions(),
+		stop:  make(chan struct{}),
+	}
+	for i := range opts {
+		opts[i](&m.opts)
 	}
+	m.evictor = newEvictor(m.opts.eviction)
+	setupMetrics()
+	m.hitCounter = memoryHits.WithLabelValues(m.opts.name)
+	m.missCounter = memoryMisses.WithLabelValues(m.opts.name)
+	m.capacityCounter = memoryEvictions.WithLabelValues(m.opts.name, evictReasonCapacity)
+	m.expiredCounter = memoryEvictions.WithLabelValues(m.opts.name, evictReasonExpired)
+	m.itemsGauge = memoryItems.WithLabelValues(m.opts.name)
+	return m
 }
 
 type Memory struct {
-	items *sync.Map
-	mutex sync.RWMutex
+	items    map[string]*entry
+	mutex    sync.Mutex
+	opts     options
+	evictor  evictor
+	bytes    int64
+	stop     chan struct{}
+	stopOnce sync.Once
+	// janitorOnce 首次写入带有效期的key时启动后台清理
+	janitorOnce sync.Once
+	// stopped Stop后不再更新items指标
+	stopped bool
+
+	hits, misses, evictions uint64
+	hitCounter              prometheus.Counter
+	missCounter             prometheus.Counter
+	capacityCounter         prometheus.Counter
+	expiredCounter          prometheus.Counter
+	itemsGauge              prometheus.Gauge
 }
 
 func (*Memory) String() string {
@@ -33,115 +89,567 @@ func (*Memory) String() string {
 func (m *Memory) connect() {
 }
 
+// Stop 停止后台过期清理, 并从items指标中减去当前的key数量, 之后仍可读写但不再计入指标
+func (m *Memory) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.itemsGauge.Sub(float64(len(m.items)))
+		m.stopped = true
+	})
+}
+
+// countItems 更新items指标, 同名实例的key数量累加, 调用方需持有锁
+func (m *Memory) countItems(delta float64) {
+	if !m.stopped {
+		m.itemsGauge.Add(delta)
+	}
+}
+
+// Stats 获取统计数据
+func (m *Memory) Stats() Stats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return Stats{
+		Items:     len(m.items),
+		Bytes:     m.bytes,
+		Hits:      atomic.LoadUint64(&m.hits),
+		Misses:    atomic.LoadUint64(&m.misses),
+		Evictions: atomic.LoadUint64(&m.evictions),
+	}
+}
+
+// DeleteExpired 清理所有过期key
+func (m *Memory) DeleteExpired() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	for key, e := range m.items {
+		if e.item.expired(now) {
+			m.remove(key, evictReasonExpired)
+		}
+	}
+}
+
+func (m *Memory) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.DeleteExpired()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
 func (m *Memory) Get(key string) (string, error) {
-	item, err := m.getItem(key)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.getString(m.lookup(key), key)
 	if err != nil || item == nil {
 		return "", err
 	}
 	return item.Value, nil
 }
 
-func (m *Memory) getItem(key string) (*item, error) {
-	var err error
-	i, ok := m.items.Load(key)
+// getItem 获取未过期的item, 调用方需持有锁
+func (m *Memory) getItem(key string) *item {
+	e, ok := m.items[key]
 	if !ok {
-		return nil, nil
-	}
-	switch i.(type) {
-	case *item:
-		item := i.(*item)
-		if item.Expired.Before(time.Now()) {
-			//过期
-			_ = m.del(key)
-			//过期后删除
-			return nil, nil
-		}
-		return item, nil
-	default:
-		err = fmt.Errorf("value of %s type error", key)
-		return nil, err
+		return nil
+	}
+	if e.item.expired(time.Now()) {
+		//过期后删除
+		m.remove(key, evictReasonExpired)
+		return nil
+	}
+	m.evictor.access(key)
+	return e.item
+}
+
+// lookup 读取item并记录命中情况, 调用方需持有锁
+func (m *Memory) lookup(key string) *item {
+	item := m.getItem(key)
+	if item == nil {
+		atomic.AddUint64(&m.misses, 1)
+		m.missCounter.Inc()
+		return nil
+	}
+	atomic.AddUint64(&m.hits, 1)
+	m.hitCounter.Inc()
+	return item
+}
+
+// getString 校验item为字符串类型
+func (m *Memory) getString(item *item, key string) (*item, error) {
+	if item != nil && item.Hash != nil {
+		return nil, fmt.Errorf("value of %s type error", key)
 	}
+	return item, nil
+}
+
+// getHash 校验item为hash类型
+func (m *Memory) getHash(item *item, key string) (*item, error) {
+	if item != nil && item.Hash == nil {
+		return nil, fmt.Errorf("value of %s type error", key)
+	}
+	return item, nil
 }
 
 func (m *Memory) Set(key string, val interface{}, expire int) error {
+	return m.SetCtx(context.Background(), key, val, time.Duration(expire)*time.Second)
+}
+
+func (m *Memory) newItem(val interface{}, expire time.Duration) (*item, error) {
 	s, err := cast.ToStringE(val)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	item := &item{
-		Value:   s,
-		Expired: time.Now().Add(time.Duration(expire) * time.Second),
+		Value: s,
 	}
-	return m.setItem(key, item)
+	if expire > 0 {
+		item.Expired = time.Now().Add(expire)
+	}
+	return item, nil
 }
 
+// setItem 写入item并按容量淘汰, 调用方需持有锁
 func (m *Memory) setItem(key string, item *item) error {
-	m.items.Store(key, item)
+	size := item.size(key)
+	if e, ok := m.items[key]; ok {
+		m.bytes += size - e.size
+		e.item, e.size = item, size
+		m.evictor.access(key)
+	} else {
+		m.items[key] = &entry{item: item, size: size}
+		m.bytes += size
+		m.evictor.add(key)
+		m.countItems(1)
+	}
+	if !item.Expired.IsZero() && m.opts.cleanupInterval > 0 {
+		m.janitorOnce.Do(func() {
+			go m.janitor(m.opts.cleanupInterval)
+		})
+	}
+	m.evict()
 	return nil
 }
 
+// evict 超出容量时按策略淘汰, 调用方需持有锁
+func (m *Memory) evict() {
+	for (m.opts.maxEntries > 0 && len(m.items) > m.opts.maxEntries) ||
+		(m.opts.maxBytes > 0 && m.bytes > m.opts.maxBytes) {
+		key, ok := m.evictor.victim()
+		if !ok {
+			return
+		}
+		m.remove(key, evictReasonCapacity)
+	}
+}
+
+// remove 删除key并记录淘汰原因, 调用方需持有锁
+func (m *Memory) remove(key, reason string) {
+	if _, ok := m.items[key]; !ok {
+		return
+	}
+	_ = m.del(key)
+	atomic.AddUint64(&m.evictions, 1)
+	switch reason {
+	case evictReasonCapacity:
+		m.capacityCounter.Inc()
+	case evictReasonExpired:
+		m.expiredCounter.Inc()
+	}
+}
+
 func (m *Memory) Del(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	return m.del(key)
 }
 
+// del 删除key, 调用方需持有锁
 func (m *Memory) del(key string) error {
-	m.items.Delete(key)
+	e, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	delete(m.items, key)
+	m.bytes -= e.size
+	m.evictor.remove(key)
+	m.countItems(-1)
 	return nil
 }
 
 func (m *Memory) HashGet(hk, key string) (string, error) {
-	item, err := m.getItem(hk + key)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.getHash(m.lookup(hk), hk)
 	if err != nil || item == nil {
 		return "", err
 	}
-	return item.Value, err
+	return item.Hash[key], nil
 }
 
 func (m *Memory) HashDel(hk, key string) error {
-	return m.del(hk + key)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.getHash(m.getItem(hk), hk)
+	if err != nil || item == nil {
+		return err
+	}
+	delete(item.Hash, key)
+	if len(item.Hash) == 0 {
+		return m.del(hk)
+	}
+	return m.setItem(hk, item)
+}
+
+// HashSet set fields in hashtable, create it if not exist
+func (m *Memory) HashSet(ctx context.Context, hk string, values map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fields := make(map[string]string, len(values))
+	for k, v := range values {
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return err
+		}
+		fields[k] = s
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.hashOrCreate(hk)
+	if err != nil {
+		return err
+	}
+	for k := range fields {
+		item.Hash[k] = fields[k]
+	}
+	return m.setItem(hk, item)
+}
+
+// HashGetAll get all fields in hashtable
+func (m *Memory) HashGetAll(ctx context.Context, hk string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.getHash(m.lookup(hk), hk)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if item == nil {
+		return values, nil
+	}
+	for k, v := range item.Hash {
+		values[k] = v
+	}
+	return values, nil
+}
+
+// HashKeys get all field names in hashtable
+func (m *Memory) HashKeys(ctx context.Context, hk string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.getHash(m.getItem(hk), hk)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	if item == nil {
+		return keys, nil
+	}
+	for k := range item.Hash {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// HashLen count fields in hashtable
+func (m *Memory) HashLen(ctx context.Context, hk string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.getHash(m.getItem(hk), hk)
+	if err != nil || item == nil {
+		return 0, err
+	}
+	return int64(len(item.Hash)), nil
+}
+
+// HashIncrBy increase field in hashtable, missing field starts at 0
+func (m *Memory) HashIncrBy(ctx context.Context, hk, key string, incr int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.hashOrCreate(hk)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if v, ok := item.Hash[key]; ok {
+		n, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value of %s.%s is not an integer", hk, key)
+		}
+	}
+	n += incr
+	item.Hash[key] = strconv.FormatInt(n, 10)
+	return n, m.setItem(hk, item)
+}
+
+// hashOrCreate 获取hash类型的item, 不存在时创建, 调用方需持有锁
+func (m *Memory) hashOrCreate(hk string) (*item, error) {
+	i, err := m.getHash(m.getItem(hk), hk)
+	if err != nil {
+		return nil, err
+	}
+	if i == nil {
+		i = &item{Hash: make(map[string]string)}
+	}
+	return i, nil
 }
 
 func (m *Memory) Increase(key string) error {
-	return m.calculate(key, 1)
+	_, err := m.calculate(key, 1)
+	return err
 }
 
 func (m *Memory) Decrease(key string) error {
-	return m.calculate(key, -1)
+	_, err := m.calculate(key, -1)
+	return err
+}
+
+// IncrBy increase value, missing key starts at 0
+func (m *Memory) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return m.calculate(key, n)
 }
 
-func (m *Memory) calculate(key string, num int) error {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
-	item, err := m.getItem(key)
+// DecrBy decrease value, missing key starts at 0
+func (m *Memory) DecrBy(ctx context.Context, key string, n int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return m.calculate(key, -n)
+}
+
+// calculate 与redis INCRBY一致, key不存在时从0开始, 保留原有过期时间
+func (m *Memory) calculate(key string, num int64) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	old, err := m.getString(m.getItem(key), key)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	calculated := &item{Value: "0"}
+	if old != nil {
+		*calculated = *old
 	}
+	var n int64
+	n, err = strconv.ParseInt(calculated.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of %s is not an integer", key)
+	}
+	n += num
+	calculated.Value = strconv.FormatInt(n, 10)
+	return n, m.setItem(key, calculated)
+}
 
+func (m *Memory) Expire(key string, dur time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item := m.getItem(key)
 	if item == nil {
-		err = fmt.Errorf("%s not exist", key)
+		return fmt.Errorf("%s not exist", key)
+	}
+	expired := *item
+	expired.Expired = time.Now().Add(dur)
+	return m.setItem(key, &expired)
+}
+
+// GetCtx get value, storage.ErrNotFound if key not exist
+func (m *Memory) GetCtx(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item, err := m.getString(m.lookup(key), key)
+	if err != nil {
+		return "", err
+	}
+	if item == nil {
+		return "", storage.ErrNotFound
+	}
+	return item.Value, nil
+}
+
+// SetCtx set value with expire, expire <= 0 never expire
+func (m *Memory) SetCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	var n int
-	n, err = cast.ToIntE(item.Value)
+	item, err := m.newItem(val, expire)
 	if err != nil {
 		return err
 	}
-	n += num
-	item.Value = strconv.Itoa(n)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	return m.setItem(key, item)
 }
 
-func (m *Memory) Expire(key string, dur time.Duration) error {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
-	item, err := m.getItem(key)
-	if err != nil {
+// DelCtx delete keys
+func (m *Memory) DelCtx(ctx context.Context, keys ...string) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	if item == nil {
-		err = fmt.Errorf("%s not exist", key)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i := range keys {
+		_ = m.del(keys[i])
+	}
+	return nil
+}
+
+// ExpireCtx set ttl, storage.ErrNotFound if key not exist
+func (m *Memory) ExpireCtx(ctx context.Context, key string, dur time.Duration) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	item.Expired = time.Now().Add(dur)
-	return m.setItem(key, item)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	old := m.getItem(key)
+	if old == nil {
+		return storage.ErrNotFound
+	}
+	expired := *old
+	expired.Expired = time.Now().Add(dur)
+	return m.setItem(key, &expired)
+}
+
+// MGet get values of keys, missing keys are omitted
+func (m *Memory) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	values := make(map[string]string, len(keys))
+	for i := range keys {
+		item := m.lookup(keys[i])
+		if item != nil && item.Hash == nil {
+			values[keys[i]] = item.Value
+		}
+	}
+	return values, nil
+}
+
+// MSet set values with the same expire
+func (m *Memory) MSet(ctx context.Context, values map[string]interface{}, expire time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	items := make(map[string]*item, len(values))
+	for k, v := range values {
+		item, err := m.newItem(v, expire)
+		if err != nil {
+			return err
+		}
+		items[k] = item
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for k := range items {
+		_ = m.setItem(k, items[k])
+	}
+	return nil
+}
+
+// SetNX set value if key not exist
+func (m *Memory) SetNX(ctx context.Context, key string, val interface{}, expire time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	item, err := m.newItem(val, expire)
+	if err != nil {
+		return false, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.getItem(key) != nil {
+		return false, nil
+	}
+	return true, m.setItem(key, item)
+}
+
+// GetSet set value without expire and return old value
+func (m *Memory) GetSet(ctx context.Context, key string, val interface{}) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	item, err := m.newItem(val, 0)
+	if err != nil {
+		return "", err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	old, err := m.getString(m.getItem(key), key)
+	if err != nil {
+		return "", err
+	}
+	if err = m.setItem(key, item); err != nil || old == nil {
+		return "", err
+	}
+	return old.Value, nil
+}
+
+// TTL get remaining time to live, -1 if key never expire
+func (m *Memory) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	item := m.getItem(key)
+	if item == nil {
+		return 0, storage.ErrNotFound
+	}
+	if item.Expired.IsZero() {
+		return -1, nil
+	}
+	return time.Until(item.Expired), nil
+}
+
+// Exists count existing keys
+func (m *Memory) Exists(ctx context.Context, keys ...string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var n int64
+	for i := range keys {
+		if m.getItem(keys[i]) != nil {
+			n++
+		}
+	}
+	return n, nil
 }
@@ -1,9 +1,14 @@
 package cache
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/alopt/go-admin-core/storage"
 )
 
 func TestMemory_Get(t *testing.T) {
@@ -68,3 +73,190 @@ func TestMemory_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestMemory_SetNX(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	ok, err := m.SetNX(ctx, "nx", "a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("SetNX() = %v, %v, want true", ok, err)
+	}
+	ok, err = m.SetNX(ctx, "nx", "b", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("SetNX() = %v, %v, want false", ok, err)
+	}
+	old, err := m.GetSet(ctx, "nx", "c")
+	if err != nil || old != "a" {
+		t.Fatalf("GetSet() = %v, %v, want a", old, err)
+	}
+	ttl, err := m.TTL(ctx, "nx")
+	if err != nil || ttl != -1 {
+		t.Fatalf("TTL() = %v, %v, want -1", ttl, err)
+	}
+}
+
+func TestMemory_MGet(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	if err := m.MSet(ctx, map[string]interface{}{"a": 1, "b": "2"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.MGet(ctx, "a", "b", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["a"] != "1" || got["b"] != "2" {
+		t.Errorf("MGet() got = %v", got)
+	}
+	n, err := m.Exists(ctx, "a", "c")
+	if err != nil || n != 1 {
+		t.Errorf("Exists() = %v, %v, want 1", n, err)
+	}
+	if err = m.DelCtx(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.GetCtx(ctx, "a"); err != storage.ErrNotFound {
+		t.Errorf("GetCtx() error = %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	ctx := context.Background()
+	m := NewMemory()
+	if err := SetJSON(ctx, m, "user", user{Name: "admin", Age: 18}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetJSON[user](ctx, m, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "admin" || got.Age != 18 {
+		t.Errorf("GetJSON() got = %v", got)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = GetJSON[user](ctx, m, "user"); err != context.Canceled {
+		t.Errorf("GetJSON() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestMemory_Hash(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	if err := m.HashSet(ctx, "hash", map[string]interface{}{"a": "1", "b": 2}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := m.HashIncrBy(ctx, "hash", "b", 3)
+	if err != nil || n != 5 {
+		t.Fatalf("HashIncrBy() = %v, %v, want 5", n, err)
+	}
+	if n, err = m.HashIncrBy(ctx, "hash", "c", -1); err != nil || n != -1 {
+		t.Fatalf("HashIncrBy() = %v, %v, want -1", n, err)
+	}
+	all, err := m.HashGetAll(ctx, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all["a"] != "1" || all["b"] != "5" || all["c"] != "-1" {
+		t.Errorf("HashGetAll() got = %v", all)
+	}
+	if v, _ := m.HashGet("hash", "a"); v != "1" {
+		t.Errorf("HashGet() got = %v, want 1", v)
+	}
+	if _, err = m.GetCtx(ctx, "hash"); err == nil {
+		t.Error("GetCtx() on hashtable should return error")
+	}
+	if err = m.ExpireCtx(ctx, "hash", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if l, err := m.HashLen(ctx, "hash"); err != nil || l != 0 {
+		t.Errorf("HashLen() = %v, %v, want 0", l, err)
+	}
+	_ = m.HashSet(ctx, "hash", map[string]interface{}{"a": 1})
+	_ = m.HashDel("hash", "a")
+	if keys, _ := m.HashKeys(ctx, "hash"); len(keys) != 0 {
+		t.Errorf("HashKeys() got = %v, want empty", keys)
+	}
+}
+
+func TestMemory_Eviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		eviction Eviction
+		want     []string
+	}{
+		{"lru", EvictionLRU, []string{"a", "c"}},
+		{"lfu", EvictionLFU, []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewMemory(WithName("test_"+tt.name), WithMaxEntries(2), WithEviction(tt.eviction))
+			defer m.Stop()
+			_ = m.SetCtx(ctx, "a", 1, 0)
+			_ = m.SetCtx(ctx, "b", 2, 0)
+			// a 被访问过, 无论LRU还是LFU都应淘汰b
+			_, _ = m.GetCtx(ctx, "a")
+			_, _ = m.GetCtx(ctx, "a")
+			_ = m.SetCtx(ctx, "c", 3, 0)
+			got, _ := m.MGet(ctx, "a", "b", "c")
+			for _, key := range tt.want {
+				if _, ok := got[key]; !ok {
+					t.Errorf("key %s should not be evicted, got %v", key, got)
+				}
+			}
+			if stats := m.Stats(); stats.Items != 2 || stats.Evictions != 1 {
+				t.Errorf("Stats() got = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestMemory_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithName("test_bytes"), WithMaxBytes(9))
+	defer m.Stop()
+	_ = m.SetCtx(ctx, "a", "1234", 0)
+	_ = m.SetCtx(ctx, "b", "1234", 0)
+	if n, _ := m.Exists(ctx, "a", "b"); n != 1 {
+		t.Errorf("Exists() got = %v, want 1", n)
+	}
+	if stats := m.Stats(); stats.Bytes > 9 {
+		t.Errorf("Stats() bytes = %v, want <= 9", stats.Bytes)
+	}
+}
+
+func TestMemory_Janitor(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(WithName("test_janitor"), WithCleanupInterval(10*time.Millisecond))
+	defer m.Stop()
+	_ = m.SetCtx(ctx, "a", 1, 5*time.Millisecond)
+	_ = m.SetCtx(ctx, "b", 1, 0)
+	time.Sleep(50 * time.Millisecond)
+	if stats := m.Stats(); stats.Items != 1 || stats.Evictions != 1 {
+		t.Errorf("Stats() got = %+v", stats)
+	}
+}
+
+func TestMemory_ItemsGauge(t *testing.T) {
+	ctx := context.Background()
+	gauge := memoryItems.WithLabelValues("test_gauge")
+	a, b := NewMemory(WithName("test_gauge")), NewMemory(WithName("test_gauge"))
+	defer b.Stop()
+	_ = a.SetCtx(ctx, "a", 1, 0)
+	_ = a.SetCtx(ctx, "b", 1, 0)
+	_ = b.SetCtx(ctx, "a", 1, 0)
+	if got := testutil.ToFloat64(gauge); got != 3 {
+		t.Errorf("items = %v, want 3", got)
+	}
+	a.Stop()
+	_ = a.SetCtx(ctx, "c", 1, 0)
+	if got := testutil.ToFloat64(gauge); got != 1 {
+		t.Errorf("items after Stop = %v, want 1", got)
+	}
+}
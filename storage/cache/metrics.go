@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	evictReasonCapacity = "capacity"
+	evictReasonExpired  = "expired"
+)
+
+var (
+	memoryHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "go_admin",
+		Subsystem: "cache_memory",
+		Name:      "hits_total",
+		Help:      "Number of memory cache lookups that found the key.",
+	}, []string{"name"})
+	memoryMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "go_admin",
+		Subsystem: "cache_memory",
+		Name:      "misses_total",
+		Help:      "Number of memory cache lookups that did not find the key.",
+	}, []string{"name"})
+	memoryEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "go_admin",
+		Subsystem: "cache_memory",
+		Name:      "evictions_total",
+		Help:      "Number of keys removed from the memory cache by capacity limit or expiration.",
+	}, []string{"name", "reason"})
+	memoryItems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "go_admin",
+		Subsystem: "cache_memory",
+		Name:      "items",
+		Help:      "Number of keys currently stored in the memory caches with the same name.",
+	}, []string{"name"})
+	registerMetrics sync.Once
+)
+
+// setupMetrics 注册到prometheus默认registry, 由listener.NewMetrics暴露
+func setupMetrics() {
+	registerMetrics.Do(func() {
+		for _, c := range []prometheus.Collector{memoryHits, memoryMisses, memoryEvictions, memoryItems} {
+			if err := prometheus.Register(c); err != nil {
+				if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+					panic(err)
+				}
+			}
+		}
+	})
+}
+
+// Stats 内存缓存统计
+type Stats struct {
+	Items     int
+	Bytes     int64
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
@@ -0,0 +1,67 @@
+package cache
+
+import "time"
+
+// Eviction 内存缓存淘汰策略
+type Eviction string
+
+const (
+	// EvictionLRU 淘汰最久未访问的key
+	EvictionLRU Eviction = "lru"
+	// EvictionLFU 淘汰访问次数最少的key
+	EvictionLFU Eviction = "lfu"
+)
+
+// Option 内存缓存参数设置类型
+type Option func(*options)
+
+type options struct {
+	name            string
+	maxEntries      int
+	maxBytes        int64
+	eviction        Eviction
+	cleanupInterval time.Duration
+}
+
+func setDefaultOptions() options {
+	return options{
+		name:            "memory",
+		eviction:        EvictionLRU,
+		cleanupInterval: time.Minute,
+	}
+}
+
+// WithName 设置名称, 用于区分监控指标, 同名实例的items指标累加
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithMaxEntries 设置最大key数量, 0不限制
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxBytes 设置最大占用字节数(key与value长度之和), 0不限制
+func WithMaxBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+// WithEviction 设置淘汰策略
+func WithEviction(e Eviction) Option {
+	return func(o *options) {
+		o.eviction = e
+	}
+}
+
+// WithCleanupInterval 设置过期清理间隔, 0不启动后台清理
+func WithCleanupInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.cleanupInterval = d
+	}
+}
@@ -2,8 +2,12 @@ package cache
 
 import (
 	"context"
-	"github.com/redis/go-redis/v9"
+	"errors"
 	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/alopt/go-admin-core/storage"
 )
 
 // NewRedis redis模式
@@ -76,7 +80,161 @@ func (r *Redis) Expire(key string, dur time.Duration) error {
 	return r.client.Expire(context.TODO(), key, dur).Err()
 }
 
+// GetCtx from key, storage.ErrNotFound if key not exist
+func (r *Redis) GetCtx(ctx context.Context, key string) (string, error) {
+	val, err := r.client.Get(ctx, key).Result()
+	return val, notFound(err)
+}
+
+// SetCtx value with key and expire time, expire <= 0 never expire
+func (r *Redis) SetCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	return r.client.Set(ctx, key, val, ttl(expire)).Err()
+}
+
+// DelCtx delete keys in redis
+func (r *Redis) DelCtx(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// ExpireCtx set ttl, storage.ErrNotFound if key not exist
+func (r *Redis) ExpireCtx(ctx context.Context, key string, dur time.Duration) error {
+	ok, err := r.client.Expire(ctx, key, dur).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// MGet values of keys, missing keys are omitted
+func (r *Redis) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	list, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if v, ok := list[i].(string); ok {
+			values[keys[i]] = v
+		}
+	}
+	return values, nil
+}
+
+// MSet values with the same expire time in one transaction
+func (r *Redis) MSet(ctx context.Context, values map[string]interface{}, expire time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for k, v := range values {
+			pipe.Set(ctx, k, v, ttl(expire))
+		}
+		return nil
+	})
+	return err
+}
+
+// SetNX value if key not exist
+func (r *Redis) SetNX(ctx context.Context, key string, val interface{}, expire time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, val, ttl(expire)).Result()
+}
+
+// GetSet value and return the old one
+func (r *Redis) GetSet(ctx context.Context, key string, val interface{}) (string, error) {
+	old, err := r.client.GetSet(ctx, key, val).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return old, err
+}
+
+// TTL remaining time to live, -1 if key never expire
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	dur, err := r.client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	switch dur {
+	case -2:
+		return 0, storage.ErrNotFound
+	case -1:
+		return -1, nil
+	}
+	return dur, nil
+}
+
+// Exists count existing keys
+func (r *Redis) Exists(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return r.client.Exists(ctx, keys...).Result()
+}
+
+// IncrBy increase value, missing key starts at 0
+func (r *Redis) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return r.client.IncrBy(ctx, key, n).Result()
+}
+
+// DecrBy decrease value, missing key starts at 0
+func (r *Redis) DecrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return r.client.DecrBy(ctx, key, n).Result()
+}
+
+// HashSet set fields in specify redis's hashtable
+func (r *Redis) HashSet(ctx context.Context, hk string, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return r.client.HSet(ctx, hk, values).Err()
+}
+
+// HashGetAll get all fields in specify redis's hashtable
+func (r *Redis) HashGetAll(ctx context.Context, hk string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, hk).Result()
+}
+
+// HashKeys get all field names in specify redis's hashtable
+func (r *Redis) HashKeys(ctx context.Context, hk string) ([]string, error) {
+	return r.client.HKeys(ctx, hk).Result()
+}
+
+// HashLen count fields in specify redis's hashtable
+func (r *Redis) HashLen(ctx context.Context, hk string) (int64, error) {
+	return r.client.HLen(ctx, hk).Result()
+}
+
+// HashIncrBy increase field in specify redis's hashtable
+func (r *Redis) HashIncrBy(ctx context.Context, hk, key string, incr int64) (int64, error) {
+	return r.client.HIncrBy(ctx, hk, key, incr).Result()
+}
+
 // GetClient 暴露原生client
 func (r *Redis) GetClient() *redis.Client {
 	return r.client
 }
+
+// notFound 统一key不存在的错误
+func notFound(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// ttl redis expire 0 means never expire
+func ttl(expire time.Duration) time.Duration {
+	if expire < 0 {
+		return 0
+	}
+	return expire
+}
@@ -0,0 +1,292 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	json "github.com/json-iterator/go"
+	"github.com/redis/go-redis/v9"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// DefaultInvalidateChannel 二级缓存失效广播的默认频道
+const DefaultInvalidateChannel = "/cache"
+
+// invalidation 失效广播内容
+type invalidation struct {
+	Node string   `json:"node"`
+	Keys []string `json:"keys"`
+}
+
+// NewTiered 二级缓存模式, 本地memory在前, redis在后
+// 任意节点写入时通过redis pub/sub广播失效, 本地缓存最长保留expire
+func NewTiered(local *Memory, remote *Redis, channel string, expire time.Duration) (*Tiered, error) {
+	if channel == "" {
+		channel = DefaultInvalidateChannel
+	}
+	if expire <= 0 {
+		expire = time.Minute
+	}
+	e := &Tiered{
+		local:   local,
+		remote:  remote,
+		channel: channel,
+		expire:  expire,
+		node:    uuid.New().String(),
+	}
+	ctx := context.Background()
+	e.pubsub = remote.GetClient().Subscribe(ctx, channel)
+	// 等待订阅确认, 避免启动后首批失效消息丢失
+	if _, err := e.pubsub.Receive(ctx); err != nil {
+		_ = e.pubsub.Close()
+		return nil, err
+	}
+	go e.subscribe()
+	return e, nil
+}
+
+// Tiered 二级缓存
+type Tiered struct {
+	local   *Memory
+	remote  *Redis
+	channel string
+	expire  time.Duration
+	node    string
+	pubsub  *redis.PubSub
+}
+
+func (*Tiered) String() string {
+	return "tiered"
+}
+
+// GetClient 暴露远端redis的原生client
+func (e *Tiered) GetClient() *redis.Client {
+	return e.remote.GetClient()
+}
+
+// Close 停止订阅与本地清理
+func (e *Tiered) Close() error {
+	e.local.Stop()
+	return e.pubsub.Close()
+}
+
+// subscribe 接收其他节点的失效广播, 断线期间的消息会丢失, 本地缓存最多陈旧expire
+func (e *Tiered) subscribe() {
+	for msg := range e.pubsub.Channel() {
+		var data invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &data); err != nil {
+			log.Errorf("tiered cache invalidation decode error: %s", err.Error())
+			continue
+		}
+		if data.Node == e.node {
+			continue
+		}
+		_ = e.local.DelCtx(context.Background(), data.Keys...)
+	}
+}
+
+// invalidate 删除本地缓存并广播给其他节点
+func (e *Tiered) invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_ = e.local.DelCtx(context.Background(), keys...)
+	rb, err := json.Marshal(&invalidation{Node: e.node, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return e.remote.GetClient().Publish(ctx, e.channel, rb).Err()
+}
+
+// after 远端写入成功后再失效
+func (e *Tiered) after(ctx context.Context, err error, keys ...string) error {
+	if err != nil {
+		return err
+	}
+	return e.invalidate(ctx, keys...)
+}
+
+func (e *Tiered) Get(key string) (string, error) {
+	val, err := e.GetCtx(context.Background(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", redis.Nil
+	}
+	return val, err
+}
+
+func (e *Tiered) Set(key string, val interface{}, expire int) error {
+	return e.SetCtx(context.Background(), key, val, time.Duration(expire)*time.Second)
+}
+
+func (e *Tiered) Del(key string) error {
+	return e.DelCtx(context.Background(), key)
+}
+
+func (e *Tiered) HashGet(hk, key string) (string, error) {
+	return e.remote.HashGet(hk, key)
+}
+
+func (e *Tiered) HashDel(hk, key string) error {
+	ctx := context.Background()
+	return e.after(ctx, e.remote.HashDel(hk, key), hk)
+}
+
+func (e *Tiered) Increase(key string) error {
+	ctx := context.Background()
+	return e.after(ctx, e.remote.Increase(key), key)
+}
+
+func (e *Tiered) Decrease(key string) error {
+	ctx := context.Background()
+	return e.after(ctx, e.remote.Decrease(key), key)
+}
+
+func (e *Tiered) Expire(key string, dur time.Duration) error {
+	ctx := context.Background()
+	return e.after(ctx, e.remote.Expire(key, dur), key)
+}
+
+// GetCtx 先读本地, 未命中读redis并回填本地
+func (e *Tiered) GetCtx(ctx context.Context, key string) (string, error) {
+	val, err := e.local.GetCtx(ctx, key)
+	if err == nil {
+		return val, nil
+	}
+	val, err = e.remote.GetCtx(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	e.backfill(ctx, map[string]string{key: val})
+	return val, nil
+}
+
+// backfill 按redis剩余TTL回填本地, 最长expire, 已无剩余TTL的key不回填
+func (e *Tiered) backfill(ctx context.Context, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	cmds := make(map[string]*redis.DurationCmd, len(values))
+	_, err := e.remote.GetClient().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for k := range values {
+			cmds[k] = pipe.PTTL(ctx, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	for k, cmd := range cmds {
+		expire := e.expire
+		switch ttl := cmd.Val(); {
+		case ttl == -1:
+			// 未设置过期时间
+		case ttl <= 0:
+			continue
+		case ttl < expire:
+			expire = ttl
+		}
+		_ = e.local.SetCtx(ctx, k, values[k], expire)
+	}
+}
+
+func (e *Tiered) SetCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	return e.after(ctx, e.remote.SetCtx(ctx, key, val, expire), key)
+}
+
+func (e *Tiered) DelCtx(ctx context.Context, keys ...string) error {
+	return e.after(ctx, e.remote.DelCtx(ctx, keys...), keys...)
+}
+
+func (e *Tiered) ExpireCtx(ctx context.Context, key string, dur time.Duration) error {
+	return e.after(ctx, e.remote.ExpireCtx(ctx, key, dur), key)
+}
+
+// MGet 先读本地, 未命中的key批量读redis并回填本地
+func (e *Tiered) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values, err := e.local.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	missing := make([]string, 0, len(keys)-len(values))
+	for i := range keys {
+		if _, ok := values[keys[i]]; !ok {
+			missing = append(missing, keys[i])
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	remote, err := e.remote.MGet(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range remote {
+		values[k] = v
+	}
+	e.backfill(ctx, remote)
+	return values, nil
+}
+
+func (e *Tiered) MSet(ctx context.Context, values map[string]interface{}, expire time.Duration) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	return e.after(ctx, e.remote.MSet(ctx, values, expire), keys...)
+}
+
+func (e *Tiered) SetNX(ctx context.Context, key string, val interface{}, expire time.Duration) (bool, error) {
+	ok, err := e.remote.SetNX(ctx, key, val, expire)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return ok, e.invalidate(ctx, key)
+}
+
+func (e *Tiered) GetSet(ctx context.Context, key string, val interface{}) (string, error) {
+	old, err := e.remote.GetSet(ctx, key, val)
+	return old, e.after(ctx, err, key)
+}
+
+func (e *Tiered) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return e.remote.TTL(ctx, key)
+}
+
+func (e *Tiered) Exists(ctx context.Context, keys ...string) (int64, error) {
+	return e.remote.Exists(ctx, keys...)
+}
+
+func (e *Tiered) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	v, err := e.remote.IncrBy(ctx, key, n)
+	return v, e.after(ctx, err, key)
+}
+
+func (e *Tiered) DecrBy(ctx context.Context, key string, n int64) (int64, error) {
+	v, err := e.remote.DecrBy(ctx, key, n)
+	return v, e.after(ctx, err, key)
+}
+
+func (e *Tiered) HashSet(ctx context.Context, hk string, values map[string]interface{}) error {
+	return e.after(ctx, e.remote.HashSet(ctx, hk, values), hk)
+}
+
+func (e *Tiered) HashGetAll(ctx context.Context, hk string) (map[string]string, error) {
+	return e.remote.HashGetAll(ctx, hk)
+}
+
+func (e *Tiered) HashKeys(ctx context.Context, hk string) ([]string, error) {
+	return e.remote.HashKeys(ctx, hk)
+}
+
+func (e *Tiered) HashLen(ctx context.Context, hk string) (int64, error) {
+	return e.remote.HashLen(ctx, hk)
+}
+
+func (e *Tiered) HashIncrBy(ctx context.Context, hk, key string, incr int64) (int64, error) {
+	n, err := e.remote.HashIncrBy(ctx, hk, key, incr)
+	return n, e.after(ctx, err, hk)
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestTiered(t *testing.T, addr, name string) *Tiered {
+	r, err := NewRedis(nil, &redis.Options{Addr: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewTiered(NewMemory(WithName(name)), r, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = e.Close()
+	})
+	return e
+}
+
+func TestTiered_Invalidate(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+	a := newTestTiered(t, s.Addr(), "tiered_a")
+	b := newTestTiered(t, s.Addr(), "tiered_b")
+
+	if err := a.SetCtx(ctx, "key", "v1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.GetCtx(ctx, "key"); err != nil || got != "v1" {
+		t.Fatalf("GetCtx() = %v, %v, want v1", got, err)
+	}
+	if got, _ := b.local.GetCtx(ctx, "key"); got != "v1" {
+		t.Fatalf("local cache should be filled, got %v", got)
+	}
+
+	if err := a.SetCtx(ctx, "key", "v2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, err := b.GetCtx(ctx, "key")
+		if err == nil && got == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GetCtx() = %v, %v, want v2 after invalidation", got, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := a.DelCtx(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get("key"); err != redis.Nil {
+		t.Errorf("Get() error = %v, want %v", err, redis.Nil)
+	}
+}
+
+func TestTiered_BackfillTTL(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+	e := newTestTiered(t, s.Addr(), "tiered_ttl")
+
+	if err := e.remote.SetCtx(ctx, "short", "v", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.remote.SetCtx(ctx, "forever", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.GetCtx(ctx, "short"); err != nil || got != "v" {
+		t.Fatalf("GetCtx() = %v, %v, want v", got, err)
+	}
+	if _, err := e.MGet(ctx, "forever"); err != nil {
+		t.Fatal(err)
+	}
+	// redis侧过期不会广播失效, 本地应随剩余TTL一起过期
+	time.Sleep(150 * time.Millisecond)
+	if _, err := e.local.GetCtx(ctx, "short"); err == nil {
+		t.Error("local cache should expire with the remote ttl")
+	}
+	if got, err := e.local.GetCtx(ctx, "forever"); err != nil || got != "v" {
+		t.Errorf("local GetCtx() = %v, %v, want v", got, err)
+	}
+}
@@ -0,0 +1,17 @@
+package storage
+
+// 消息头
+const (
+	// HeaderTenant 租户, 即队列的前缀
+	HeaderTenant = "tenant"
+	// HeaderTraceID 链路追踪id
+	HeaderTraceID = "trace-id"
+	// HeaderContentType 消息体的编码, 见 queue.Codec
+	HeaderContentType = "content-type"
+	// HeaderAttempt 消息已失败的次数
+	HeaderAttempt = "attempt"
+	// HeaderError 消息最后一次失败的原因
+	HeaderError = "error"
+	// HeaderPublishTime 首次发布时间, RFC3339Nano
+	HeaderPublishTime = "publish-time"
+)
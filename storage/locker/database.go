@@ -0,0 +1,135 @@
+package locker
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// DatabaseLock 数据库锁的记录
+type DatabaseLock struct {
+	Name      string    `gorm:"primaryKey;size:191"`
+	Token     string    `gorm:"size:191;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+func (DatabaseLock) TableName() string {
+	return "sys_locker"
+}
+
+// Migrate 创建数据库锁的表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&DatabaseLock{})
+}
+
+// NewDatabase 基于数据表的锁, 支持gorm的所有数据库, 有效期以各实例的本地时间判断
+func NewDatabase(db *gorm.DB) *Database {
+	return &Database{
+		db: db,
+	}
+}
+
+type Database struct {
+	holder
+	db *gorm.DB
+}
+
+func (*Database) String() string {
+	return "database"
+}
+
+func (e *Database) Lock(key string, ttl int64, opts ...storage.LockOption) (storage.Lock, error) {
+	o := storage.NewLockOptions(opts...)
+	o.TTL = time.Duration(ttl) * time.Second
+	return e.holder.lock(context.TODO(), e, key, o, false)
+}
+
+// LockCtx 等待直到获得锁或ctx结束
+func (e *Database) LockCtx(ctx context.Context, key string, opts ...storage.LockOption) (storage.Lock, error) {
+	return e.holder.lock(ctx, e, key, storage.NewLockOptions(opts...), true)
+}
+
+func (e *Database) acquire(ctx context.Context, key, token string, ttl time.Duration) (storage.Lock, error) {
+	db := e.db.WithContext(ctx)
+	now := time.Now()
+	// 先接管过期的或相同token的锁, 不存在时再插入
+	result := db.Model(&DatabaseLock{}).
+		Where("name = ? AND (expires_at <= ? OR token = ?)", key, now, token).
+		Updates(map[string]interface{}{
+			"token":      token,
+			"expires_at": now.Add(ttl),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		result = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&DatabaseLock{
+			Name:      key,
+			Token:     token,
+			ExpiresAt: now.Add(ttl),
+		})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, storage.ErrNotObtained
+		}
+	}
+	return &databaseLock{db: e.db, key: key, token: token}, nil
+}
+
+type databaseLock struct {
+	db    *gorm.DB
+	key   string
+	token string
+}
+
+func (l *databaseLock) Key() string {
+	return l.key
+}
+
+func (l *databaseLock) Token() string {
+	return l.token
+}
+
+// held 仍由本持有者持有且未过期的记录
+func (l *databaseLock) held(ctx context.Context) *gorm.DB {
+	return l.db.WithContext(ctx).Model(&DatabaseLock{}).
+		Where("name = ? AND token = ? AND expires_at > ?", l.key, l.token, time.Now())
+}
+
+func (l *databaseLock) TTL(ctx context.Context) (time.Duration, error) {
+	var list []DatabaseLock
+	if err := l.held(ctx).Limit(1).Find(&list).Error; err != nil || len(list) == 0 {
+		return 0, err
+	}
+	return time.Until(list[0].ExpiresAt), nil
+}
+
+func (l *databaseLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	result := l.held(ctx).Update("expires_at", time.Now().Add(ttl))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrLockNotHeld
+	}
+	return nil
+}
+
+func (l *databaseLock) Release(ctx context.Context) error {
+	result := l.db.WithContext(ctx).
+		Where("name = ? AND token = ? AND expires_at > ?", l.key, l.token, time.Now()).
+		Delete(&DatabaseLock{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrLockNotHeld
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// acquirer 各实现单次获取锁, 被占用时返回storage.ErrNotObtained;
+// 已由相同token持有时视为获取成功并重置有效期
+type acquirer interface {
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (storage.Lock, error)
+}
+
+// holder 在各实现之上提供等待、看门狗和进程内按token的重入
+type holder struct {
+	mux   sync.Mutex
+	locks map[string]*heldLock
+}
+
+// lock 获取锁, block为true时一直重试直到ctx结束, 否则最多重试o.RetryCount次
+func (h *holder) lock(ctx context.Context, a acquirer, key string, o storage.LockOptions, block bool) (storage.Lock, error) {
+	if o.Token != "" {
+		l, err := h.reenter(ctx, key, o.Token, o.TTL)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			return l, nil
+		}
+	}
+	token := o.Token
+	if token == "" {
+		token = uuid.New().String()
+	}
+	var lock storage.Lock
+	for i := 0; ; i++ {
+		var err error
+		lock, err = a.acquire(ctx, key, token, o.TTL)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, storage.ErrNotObtained) || (!block && i >= o.RetryCount) {
+			return nil, err
+		}
+		timer := time.NewTimer(o.RetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if o.Token != "" {
+		// 并发获取时后端已允许相同token重入, 合并到已登记的锁
+		if l := h.held(key, o.Token); l != nil && l.enter() {
+			return l, nil
+		}
+	}
+	l := &heldLock{
+		Lock:   lock,
+		holder: h,
+		ttl:    o.TTL,
+		count:  1,
+		stop:   make(chan struct{}),
+	}
+	if o.Token != "" {
+		h.mux.Lock()
+		if h.locks == nil {
+			h.locks = make(map[string]*heldLock)
+		}
+		h.locks[key] = l
+		l.registered = true
+		h.mux.Unlock()
+	}
+	if o.Watchdog {
+		go l.watch(o.OnLost)
+	}
+	return l, nil
+}
+
+// reenter 相同token已持有时通过后端续期后计数加一;
+// 锁已过期时移除登记并返回nil, 由调用方重新获取
+func (h *holder) reenter(ctx context.Context, key, token string, ttl time.Duration) (storage.Lock, error) {
+	l := h.held(key, token)
+	if l == nil {
+		return nil, nil
+	}
+	if l.ttl > ttl {
+		ttl = l.ttl
+	}
+	if err := l.Lock.Refresh(ctx, ttl); err != nil {
+		if !errors.Is(err, storage.ErrLockNotHeld) {
+			return nil, err
+		}
+		log.Warnf("locker: lock %s expired before reentry", key)
+		h.drop(l)
+		return nil, nil
+	}
+	if !l.enter() {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// held 获取已登记的相同token的锁
+func (h *holder) held(key, token string) *heldLock {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	l, ok := h.locks[key]
+	if !ok || l.Token() != token {
+		return nil
+	}
+	return l
+}
+
+// drop 锁已丢失, 移除登记并停止看门狗, 之前的持有者Release时返回ErrLockNotHeld,
+// 避免释放重新获取的相同token的锁
+func (h *holder) drop(l *heldLock) {
+	l.mux.Lock()
+	if l.count > 0 {
+		l.count = 0
+		close(l.stop)
+	}
+	l.mux.Unlock()
+	h.mux.Lock()
+	if h.locks[l.Key()] == l {
+		delete(h.locks, l.Key())
+	}
+	h.mux.Unlock()
+}
+
+// heldLock 带重入计数和看门狗的锁, 计数归零时释放
+type heldLock struct {
+	storage.Lock
+	holder     *holder
+	ttl        time.Duration
+	count      int
+	registered bool
+	stop       chan struct{}
+	mux        sync.Mutex
+}
+
+// enter 重入计数加一, 已释放时返回false
+func (l *heldLock) enter() bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.count == 0 {
+		return false
+	}
+	l.count++
+	return true
+}
+
+// watch 每隔ttl/3续期, 续期失败后停止并通知onLost
+func (l *heldLock) watch(onLost func(err error)) {
+	interval := l.ttl / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		err := l.Lock.Refresh(context.Background(), l.ttl)
+		if err == nil {
+			continue
+		}
+		select {
+		case <-l.stop:
+			return
+		default:
+		}
+		if errors.Is(err, storage.ErrLockNotHeld) {
+			log.Warnf("locker: lock %s lost", l.Key())
+			if onLost != nil {
+				onLost(err)
+			}
+			return
+		}
+		// 网络错误等继续尝试, 直到锁真正过期
+		log.Errorf("locker: refresh lock %s error, %s", l.Key(), err.Error())
+	}
+}
+
+// Release 重入计数减一, 归零时停止看门狗并释放
+func (l *heldLock) Release(ctx context.Context) error {
+	l.mux.Lock()
+	if l.count == 0 {
+		l.mux.Unlock()
+		return storage.ErrLockNotHeld
+	}
+	l.count--
+	if l.count > 0 {
+		l.mux.Unlock()
+		return nil
+	}
+	close(l.stop)
+	l.mux.Unlock()
+	if l.registered {
+		l.holder.mux.Lock()
+		if l.holder.locks[l.Key()] == l {
+			delete(l.holder.locks, l.Key())
+		}
+		l.holder.mux.Unlock()
+	}
+	return l.Lock.Release(ctx)
+}
+
+// WithLock 获取锁后执行fn, 持有期间自动续期, 锁丢失时取消fn的ctx
+func WithLock(ctx context.Context, locker storage.AdapterLocker, key string,
+	fn func(ctx context.Context) error, opts ...storage.LockOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	opts = append(opts, storage.WithLockWatchdog(func(error) {
+		cancel()
+	}))
+	lock, err := locker.LockCtx(ctx, key, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil && !errors.Is(err, storage.ErrLockNotHeld) {
+			log.Errorf("locker: release lock %s error, %s", key, err.Error())
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,270 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+func newTestLockers(t *testing.T) map[string]storage.AdapterLocker {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	s := miniredis.RunT(t)
+	return map[string]storage.AdapterLocker{
+		"memory":   NewMemory(),
+		"database": NewDatabase(db),
+		"redis":    NewRedis(redis.NewClient(&redis.Options{Addr: s.Addr()})),
+	}
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.TODO()
+	for name, locker := range newTestLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			lock, err := locker.Lock("test", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lock.Key() != "test" || lock.Token() == "" {
+				t.Fatalf("lock = %s %s", lock.Key(), lock.Token())
+			}
+			if _, err = locker.Lock("test", 10); !errors.Is(err, storage.ErrNotObtained) {
+				t.Fatalf("Lock() = %v, want ErrNotObtained", err)
+			}
+			if ttl, err := lock.TTL(ctx); err != nil || ttl <= 0 || ttl > 10*time.Second {
+				t.Fatalf("TTL() = %s, %v", ttl, err)
+			}
+			if err = lock.Refresh(ctx, 20*time.Second); err != nil {
+				t.Fatal(err)
+			}
+			if ttl, _ := lock.TTL(ctx); ttl <= 10*time.Second {
+				t.Fatalf("TTL() = %s after refresh", ttl)
+			}
+			if err = lock.Release(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err = lock.Release(ctx); !errors.Is(err, storage.ErrLockNotHeld) {
+				t.Fatalf("Release() = %v, want ErrLockNotHeld", err)
+			}
+			if err = lock.Refresh(ctx, time.Second); !errors.Is(err, storage.ErrLockNotHeld) {
+				t.Fatalf("Refresh() = %v, want ErrLockNotHeld", err)
+			}
+			// 释放后可以再次获取, 指定token
+			lock, err = locker.Lock("test", 10, storage.WithLockToken("owner"))
+			if err != nil || lock.Token() != "owner" {
+				t.Fatalf("Lock() = %v, %v", lock, err)
+			}
+			_ = lock.Release(ctx)
+		})
+	}
+}
+
+func TestLocker_Retry(t *testing.T) {
+	for _, name := range []string{"memory", "database"} {
+		locker := newTestLockers(t)[name]
+		t.Run(name, func(t *testing.T) {
+			lock, err := locker.Lock("retry", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 重试直到锁过期
+			other, err := locker.Lock("retry", 1, storage.WithLockRetry(30, 100*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = lock.Release(context.TODO()); !errors.Is(err, storage.ErrLockNotHeld) {
+				t.Fatalf("Release() of expired lock = %v", err)
+			}
+			if err = other.Release(context.TODO()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestLocker_LockCtx(t *testing.T) {
+	for name, locker := range newTestLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			lock, err := locker.LockCtx(context.TODO(), "ctx", storage.WithLockTTL(time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 等待直到ctx结束
+			ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+			defer cancel()
+			if _, err = locker.LockCtx(ctx, "ctx"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("LockCtx() = %v, want DeadlineExceeded", err)
+			}
+			// 释放后等待者获得锁
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				_ = lock.Release(context.TODO())
+			}()
+			other, err := locker.LockCtx(context.TODO(), "ctx")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = other.Release(context.TODO())
+		})
+	}
+}
+
+func TestLocker_Reentrant(t *testing.T) {
+	ctx := context.TODO()
+	for name, locker := range newTestLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			owner := storage.WithLockToken("owner")
+			outer, err := locker.LockCtx(ctx, "reentrant", owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inner, err := locker.Lock("reentrant", 10, owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = locker.Lock("reentrant", 10); !errors.Is(err, storage.ErrNotObtained) {
+				t.Fatalf("Lock() by other = %v", err)
+			}
+			// 内层释放后仍然持有
+			if err = inner.Release(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = locker.Lock("reentrant", 10); !errors.Is(err, storage.ErrNotObtained) {
+				t.Fatalf("Lock() after inner release = %v", err)
+			}
+			if err = outer.Release(ctx); err != nil {
+				t.Fatal(err)
+			}
+			other, err := locker.Lock("reentrant", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = other.Release(ctx)
+		})
+	}
+}
+
+func TestLocker_ReentrantExpired(t *testing.T) {
+	ctx := context.TODO()
+	for name, locker := range newTestLockers(t) {
+		if name == "redis" {
+			// miniredis的key不会随时间过期
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			owner := storage.WithLockToken("owner")
+			ttl := storage.WithLockTTL(100 * time.Millisecond)
+			outer, err := locker.LockCtx(ctx, "expired", owner, ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(200 * time.Millisecond)
+			other, err := locker.LockCtx(ctx, "expired", ttl)
+			if err != nil {
+				t.Fatalf("LockCtx() after expiry = %v", err)
+			}
+			if _, err = locker.Lock("expired", 10, owner); !errors.Is(err, storage.ErrNotObtained) {
+				t.Fatalf("reentrant Lock() after expiry = %v, want ErrNotObtained", err)
+			}
+			time.Sleep(200 * time.Millisecond)
+			// 重新获取的锁不会被之前的持有者释放
+			inner, err := locker.Lock("expired", 10, owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = outer.Release(ctx); !errors.Is(err, storage.ErrLockNotHeld) {
+				t.Errorf("outer Release() = %v, want ErrLockNotHeld", err)
+			}
+			if _, err = locker.Lock("expired", 10); !errors.Is(err, storage.ErrNotObtained) {
+				t.Errorf("Lock() by other = %v, want ErrNotObtained", err)
+			}
+			_ = other.Release(ctx)
+			if err = inner.Release(ctx); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLocker_Watchdog(t *testing.T) {
+	ctx := context.TODO()
+	for name, locker := range newTestLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			lock, err := locker.LockCtx(ctx, "watchdog",
+				storage.WithLockTTL(300*time.Millisecond), storage.WithLockWatchdog(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Second)
+			if _, err = locker.Lock("watchdog", 10); !errors.Is(err, storage.ErrNotObtained) {
+				t.Fatalf("Lock() = %v, watchdog should keep the lock", err)
+			}
+			if err = lock.Release(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	locker := NewMemory()
+	var running, max int32
+	var wait sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			err := WithLock(context.TODO(), locker, "with", func(ctx context.Context) error {
+				if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&max) {
+					atomic.StoreInt32(&max, n)
+				}
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wait.Wait()
+	if max != 1 {
+		t.Fatalf("max concurrent = %d, want 1", max)
+	}
+
+	// 锁丢失时取消fn的ctx
+	err := WithLock(context.TODO(), locker, "lost", func(ctx context.Context) error {
+		locker.mux.Lock()
+		delete(locker.locks, "lost")
+		locker.mux.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+			return errors.New("ctx not canceled")
+		}
+	}, storage.WithLockTTL(300*time.Millisecond))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WithLock() = %v, want Canceled", err)
+	}
+}
@@ -0,0 +1,116 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// NewMemory 进程内的锁, 用于单实例部署和开发环境
+func NewMemory() *Memory {
+	return &Memory{
+		locks: make(map[string]*memoryEntry),
+	}
+}
+
+type memoryEntry struct {
+	token   string
+	expires time.Time
+}
+
+type Memory struct {
+	holder
+	locks   map[string]*memoryEntry
+	inserts int
+	mux     sync.Mutex
+}
+
+func (*Memory) String() string {
+	return "memory"
+}
+
+func (m *Memory) Lock(key string, ttl int64, opts ...storage.LockOption) (storage.Lock, error) {
+	o := storage.NewLockOptions(opts...)
+	o.TTL = time.Duration(ttl) * time.Second
+	return m.holder.lock(context.TODO(), m, key, o, false)
+}
+
+// LockCtx 等待直到获得锁或ctx结束
+func (m *Memory) LockCtx(ctx context.Context, key string, opts ...storage.LockOption) (storage.Lock, error) {
+	return m.holder.lock(ctx, m, key, storage.NewLockOptions(opts...), true)
+}
+
+func (m *Memory) acquire(_ context.Context, key, token string, ttl time.Duration) (storage.Lock, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	now := time.Now()
+	if entry, ok := m.locks[key]; ok && now.Before(entry.expires) && entry.token != token {
+		return nil, storage.ErrNotObtained
+	}
+	m.locks[key] = &memoryEntry{token: token, expires: now.Add(ttl)}
+	// 定期清理过期的锁, 避免一次性的key一直占用内存
+	if m.inserts++; m.inserts%1024 == 0 {
+		for k, entry := range m.locks {
+			if !now.Before(entry.expires) {
+				delete(m.locks, k)
+			}
+		}
+	}
+	return &memoryLock{memory: m, key: key, token: token}, nil
+}
+
+// held 返回仍由token持有的锁, 需要持有m.mux
+func (m *Memory) held(key, token string) (*memoryEntry, bool) {
+	entry, ok := m.locks[key]
+	if !ok || entry.token != token || !time.Now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry, true
+}
+
+type memoryLock struct {
+	memory *Memory
+	key    string
+	token  string
+}
+
+func (l *memoryLock) Key() string {
+	return l.key
+}
+
+func (l *memoryLock) Token() string {
+	return l.token
+}
+
+func (l *memoryLock) TTL(context.Context) (time.Duration, error) {
+	l.memory.mux.Lock()
+	defer l.memory.mux.Unlock()
+	entry, ok := l.memory.held(l.key, l.token)
+	if !ok {
+		return 0, nil
+	}
+	return time.Until(entry.expires), nil
+}
+
+func (l *memoryLock) Refresh(_ context.Context, ttl time.Duration) error {
+	l.memory.mux.Lock()
+	defer l.memory.mux.Unlock()
+	entry, ok := l.memory.held(l.key, l.token)
+	if !ok {
+		return storage.ErrLockNotHeld
+	}
+	entry.expires = time.Now().Add(ttl)
+	return nil
+}
+
+func (l *memoryLock) Release(context.Context) error {
+	l.memory.mux.Lock()
+	defer l.memory.mux.Unlock()
+	if _, ok := l.memory.held(l.key, l.token); !ok {
+		return storage.ErrLockNotHeld
+	}
+	delete(l.memory.locks, l.key)
+	return nil
+}
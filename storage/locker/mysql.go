@@ -0,0 +1,143 @@
+package locker
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// mysqlLockNameLen GET_LOCK的名称最长64个字符
+const mysqlLockNameLen = 64
+
+// NewMySQL 基于MySQL GET_LOCK的锁, 每个锁占用一个连接, 连接断开时MySQL自动释放;
+// 到期后在本实例释放, 实例异常退出时随连接一起释放
+func NewMySQL(db *gorm.DB) *MySQL {
+	return &MySQL{
+		db: db,
+	}
+}
+
+type MySQL struct {
+	holder
+	db *gorm.DB
+}
+
+func (*MySQL) String() string {
+	return "mysql"
+}
+
+func mysqlLockName(key string) string {
+	if len(key) <= mysqlLockNameLen {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *MySQL) Lock(key string, ttl int64, opts ...storage.LockOption) (storage.Lock, error) {
+	o := storage.NewLockOptions(opts...)
+	o.TTL = time.Duration(ttl) * time.Second
+	return e.holder.lock(context.TODO(), e, key, o, false)
+}
+
+// LockCtx 等待直到获得锁或ctx结束
+func (e *MySQL) LockCtx(ctx context.Context, key string, opts ...storage.LockOption) (storage.Lock, error) {
+	return e.holder.lock(ctx, e, key, storage.NewLockOptions(opts...), true)
+}
+
+// acquire GET_LOCK按连接持有, 不同进程相同token不能重入
+func (e *MySQL) acquire(ctx context.Context, key, token string, ttl time.Duration) (storage.Lock, error) {
+	sqlDB, err := e.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := mysqlLockName(key)
+	var got sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&got); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		_ = conn.Close()
+		return nil, storage.ErrNotObtained
+	}
+	l := &mysqlLock{
+		conn:    conn,
+		key:     key,
+		name:    name,
+		token:   token,
+		expires: time.Now().Add(ttl),
+	}
+	l.mux.Lock()
+	l.timer = time.AfterFunc(ttl, func() {
+		_ = l.Release(context.Background())
+	})
+	l.mux.Unlock()
+	return l, nil
+}
+
+type mysqlLock struct {
+	conn     *sql.Conn
+	key      string
+	name     string
+	token    string
+	expires  time.Time
+	timer    *time.Timer
+	released bool
+	mux      sync.Mutex
+}
+
+func (l *mysqlLock) Key() string {
+	return l.key
+}
+
+func (l *mysqlLock) Token() string {
+	return l.token
+}
+
+func (l *mysqlLock) TTL(context.Context) (time.Duration, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.released {
+		return 0, nil
+	}
+	return time.Until(l.expires), nil
+}
+
+func (l *mysqlLock) Refresh(_ context.Context, ttl time.Duration) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.released || !l.timer.Stop() {
+		return storage.ErrLockNotHeld
+	}
+	l.expires = time.Now().Add(ttl)
+	l.timer.Reset(ttl)
+	return nil
+}
+
+func (l *mysqlLock) Release(ctx context.Context) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.released {
+		return storage.ErrLockNotHeld
+	}
+	l.released = true
+	l.timer.Stop()
+	_, err := l.conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", l.name)
+	// 连接关闭后MySQL也会释放锁
+	if cerr := l.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
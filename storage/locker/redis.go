@@ -2,10 +2,14 @@ package locker
 
 import (
 	"context"
-	"github.com/redis/go-redis/v9"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/bsm/redislock"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/alopt/go-admin-core/storage"
 )
 
 // NewRedis 初始化locker
@@ -16,17 +20,71 @@ func NewRedis(c *redis.Client) *Redis {
 }
 
 type Redis struct {
+	holder
 	client *redis.Client
 	mutex  *redislock.Client
+	once   sync.Once
 }
 
-func (Redis) String() string {
+func (*Redis) String() string {
 	return "redis"
 }
 
-func (r *Redis) Lock(key string, ttl int64, options *redislock.Options) (*redislock.Lock, error) {
-	if r.mutex == nil {
+func (r *Redis) Lock(key string, ttl int64, opts ...storage.LockOption) (storage.Lock, error) {
+	o := storage.NewLockOptions(opts...)
+	o.TTL = time.Duration(ttl) * time.Second
+	return r.holder.lock(context.TODO(), r, key, o, false)
+}
+
+// LockCtx 等待直到获得锁或ctx结束
+func (r *Redis) LockCtx(ctx context.Context, key string, opts ...storage.LockOption) (storage.Lock, error) {
+	return r.holder.lock(ctx, r, key, storage.NewLockOptions(opts...), true)
+}
+
+func (r *Redis) acquire(ctx context.Context, key, token string, ttl time.Duration) (storage.Lock, error) {
+	r.once.Do(func() {
 		r.mutex = redislock.New(r.client)
+	})
+	// redislock允许相同token重新获取
+	lock, err := r.mutex.Obtain(ctx, key, ttl, &redislock.Options{Token: token})
+	if errors.Is(err, redislock.ErrNotObtained) {
+		return nil, storage.ErrNotObtained
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &redisLock{lock: lock}, nil
+}
+
+// redisLock 将redislock的错误转换为storage中的错误
+type redisLock struct {
+	lock *redislock.Lock
+}
+
+func (l *redisLock) Key() string {
+	return l.lock.Key()
+}
+
+func (l *redisLock) Token() string {
+	return l.lock.Token()
+}
+
+func (l *redisLock) TTL(ctx context.Context) (time.Duration, error) {
+	return l.lock.TTL(ctx)
+}
+
+func (l *redisLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	err := l.lock.Refresh(ctx, ttl, nil)
+	if errors.Is(err, redislock.ErrNotObtained) {
+		return storage.ErrLockNotHeld
+	}
+	return err
+}
+
+func (l *redisLock) Release(ctx context.Context) error {
+	err := l.lock.Release(ctx)
+	if errors.Is(err, redislock.ErrLockNotHeld) {
+		return storage.ErrLockNotHeld
 	}
-	return r.mutex.Obtain(context.TODO(), key, time.Duration(ttl)*time.Second, options)
+	return err
 }
@@ -0,0 +1,101 @@
+package storage
+
+import "time"
+
+// RegisterOptions 消费者注册参数
+type RegisterOptions struct {
+	Retry RetryPolicy
+	// Concurrency 该stream同时处理的消息数, 0使用队列的默认值
+	Concurrency int
+}
+
+// RegisterOption 消费者注册参数设置类型
+type RegisterOption func(*RegisterOptions)
+
+// NewRegisterOptions 返回默认参数并依次应用opts
+func NewRegisterOptions(opts ...RegisterOption) RegisterOptions {
+	o := RegisterOptions{
+		Retry: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRetry 设置该stream的重试策略
+func WithRetry(policy RetryPolicy) RegisterOption {
+	return func(o *RegisterOptions) {
+		o.Retry = policy
+	}
+}
+
+// WithConcurrency 设置该stream同时处理的消息数
+func WithConcurrency(n int) RegisterOption {
+	return func(o *RegisterOptions) {
+		o.Concurrency = n
+	}
+}
+
+// LockOptions 获取锁的参数
+type LockOptions struct {
+	// TTL 有效期, LockCtx使用, 默认30s
+	TTL time.Duration
+	// RetryCount Lock在锁被占用时的重试次数, 0不重试; LockCtx一直重试直到ctx结束
+	RetryCount int
+	// RetryInterval 重试间隔, 默认100ms
+	RetryInterval time.Duration
+	// Token 指定持有者标识, 为空时随机生成; 指定时同一进程内相同token可重入
+	Token string
+	// Watchdog 持有期间每隔TTL/3自动续期, 直到Release
+	Watchdog bool
+	// OnLost 看门狗续期失败(锁已过期或被他人获得)时调用
+	OnLost func(err error)
+}
+
+// LockOption 获取锁的参数设置类型
+type LockOption func(*LockOptions)
+
+// NewLockOptions 返回默认参数并依次应用opts, 忽略nil
+func NewLockOptions(opts ...LockOption) LockOptions {
+	o := LockOptions{
+		TTL:           30 * time.Second,
+		RetryInterval: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// WithLockRetry 锁被占用时每隔interval重试, 最多count次
+func WithLockRetry(count int, interval time.Duration) LockOption {
+	return func(o *LockOptions) {
+		o.RetryCount = count
+		o.RetryInterval = interval
+	}
+}
+
+// WithLockToken 指定持有者标识
+func WithLockToken(token string) LockOption {
+	return func(o *LockOptions) {
+		o.Token = token
+	}
+}
+
+// WithLockTTL 设置有效期
+func WithLockTTL(ttl time.Duration) LockOption {
+	return func(o *LockOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithLockWatchdog 持有期间自动续期, onLost在续期失败时调用, 可为nil
+func WithLockWatchdog(onLost func(err error)) LockOption {
+	return func(o *LockOptions) {
+		o.Watchdog = true
+		o.OnLost = onLost
+	}
+}
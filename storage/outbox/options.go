@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// Option relay参数设置类型
+type Option func(*options)
+
+type options struct {
+	interval        time.Duration
+	batch           int
+	lease           time.Duration
+	retention       time.Duration
+	cleanupInterval time.Duration
+	retry           storage.RetryPolicy
+}
+
+func setDefaultOptions() options {
+	return options{
+		interval:        time.Second,
+		batch:           100,
+		lease:           30 * time.Second,
+		retention:       24 * time.Hour,
+		cleanupInterval: time.Minute,
+		retry:           storage.DefaultRetryPolicy,
+	}
+}
+
+// WithInterval 设置扫描待发布消息的间隔
+func WithInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.interval = d
+	}
+}
+
+// WithBatch 设置每次最多发布的消息数
+func WithBatch(n int) Option {
+	return func(o *options) {
+		o.batch = n
+	}
+}
+
+// WithLease 设置消息被认领后的租期, 租期内未确认的消息由其他实例重新发布
+func WithLease(d time.Duration) Option {
+	return func(o *options) {
+		o.lease = d
+	}
+}
+
+// WithRetention 设置已发布消息的保留时间, <=0发布后立即删除
+func WithRetention(d time.Duration) Option {
+	return func(o *options) {
+		o.retention = d
+	}
+}
+
+// WithCleanupInterval 设置清理已发布消息的间隔
+func WithCleanupInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.cleanupInterval = d
+	}
+}
+
+// WithRetry 设置发布失败后的重试策略, 失败MaxAttempts次的消息标记dead_at后不再发布,
+// 并尝试发布到stream的死信队列; MaxAttempts<=0时一直重试
+func WithRetry(policy storage.RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
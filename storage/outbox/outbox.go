@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"errors"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"gorm.io/gorm"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// HeaderOutboxID 发布的消息带上outbox记录的id, 消费者可以据此去重
+const HeaderOutboxID = "outbox-id"
+
+// Message outbox中的消息, 与业务数据在同一事务中写入, 由Relay发布到队列
+type Message struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement"`
+	Stream      string     `gorm:"size:255;not null"`
+	Values      string     `gorm:"type:text"`
+	Headers     string     `gorm:"type:text"`
+	Attempts    int        `gorm:"not null;default:0"`
+	LastError   string     `gorm:"size:1024"`
+	Owner       string     `gorm:"size:64"`
+	AvailableAt time.Time  `gorm:"index:idx_outbox_pending,priority:2;not null"`
+	PublishedAt *time.Time `gorm:"index:idx_outbox_pending,priority:1"`
+	// DeadAt 超过重试次数的时间, 不为空的消息不再发布
+	DeadAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (Message) TableName() string {
+	return "sys_outbox"
+}
+
+// Migrate 创建outbox表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Message{})
+}
+
+// Append 在调用方的事务tx中写入消息, 事务提交后由Relay发布
+func Append(tx *gorm.DB, message storage.Messager) error {
+	return AppendAt(tx, message, time.Now())
+}
+
+// AppendAt 在调用方的事务tx中写入消息, 不早于at发布
+func AppendAt(tx *gorm.DB, message storage.Messager, at time.Time) error {
+	if message.GetStream() == "" {
+		return errors.New("outbox: message stream is empty")
+	}
+	values, err := json.MarshalToString(message.GetValues())
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]string, len(message.GetHeaders())+1)
+	for k, v := range message.GetHeaders() {
+		headers[k] = v
+	}
+	if headers[storage.HeaderPublishTime] == "" {
+		headers[storage.HeaderPublishTime] = time.Now().Format(time.RFC3339Nano)
+	}
+	rb, err := json.MarshalToString(headers)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&Message{
+		Stream:      message.GetStream(),
+		Values:      values,
+		Headers:     rb,
+		AvailableAt: at,
+	}).Error
+}
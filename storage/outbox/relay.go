@@ -0,0 +1,224 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	json "github.com/json-iterator/go"
+	"gorm.io/gorm"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/server"
+	"github.com/alopt/go-admin-core/storage"
+	"github.com/alopt/go-admin-core/storage/queue"
+)
+
+var _ server.Runnable = (*Relay)(nil)
+
+// NewRelay 将outbox中的消息发布到queue, 随server.Manager启动
+func NewRelay(db *gorm.DB, queue storage.AdapterQueue, opts ...Option) *Relay {
+	r := &Relay{
+		db:    db,
+		queue: queue,
+		opts:  setDefaultOptions(),
+	}
+	for _, o := range opts {
+		o(&r.opts)
+	}
+	return r
+}
+
+// Relay outbox发布服务, 消息至少发布一次; 多个实例通过租期认领消息, 互不重复发布
+type Relay struct {
+	db      *gorm.DB
+	queue   storage.AdapterQueue
+	opts    options
+	started bool
+	mux     sync.Mutex
+}
+
+// String 服务名称
+func (e *Relay) String() string {
+	return "outbox-relay"
+}
+
+// Start 定时发布和清理消息直到ctx结束
+func (e *Relay) Start(ctx context.Context) error {
+	e.mux.Lock()
+	if e.started {
+		e.mux.Unlock()
+		return errors.New("outbox relay was started more than once. " +
+			"This is likely to be caused by being added to a manager multiple times")
+	}
+	e.started = true
+	e.mux.Unlock()
+
+	ticker := time.NewTicker(e.opts.interval)
+	defer ticker.Stop()
+	var cleaned time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		for {
+			n, err := e.Relay(ctx)
+			if err != nil {
+				log.Errorf("outbox: relay error, %s", err.Error())
+			}
+			if err != nil || n < e.opts.batch || ctx.Err() != nil {
+				break
+			}
+		}
+		if e.opts.retention > 0 && time.Since(cleaned) >= e.opts.cleanupInterval {
+			cleaned = time.Now()
+			if _, err := e.Cleanup(ctx); err != nil {
+				log.Errorf("outbox: cleanup error, %s", err.Error())
+			}
+		}
+	}
+}
+
+// Attempt 判断是否可以启动
+func (e *Relay) Attempt() bool {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return !e.started
+}
+
+// Relay 认领并发布一批到期的消息, 返回认领的条数; 单条失败不影响同批其他消息
+func (e *Relay) Relay(ctx context.Context) (int, error) {
+	list, err := e.claim(ctx)
+	if err != nil || len(list) == 0 {
+		return 0, err
+	}
+	var errs []error
+	for i := range list {
+		if err = e.publish(ctx, &list[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(list), errors.Join(errs...)
+}
+
+// claim 以租期认领消息, 实例在发布前退出时消息在租期结束后被重新认领
+func (e *Relay) claim(ctx context.Context) ([]Message, error) {
+	db := e.db.WithContext(ctx)
+	now := time.Now()
+	var ids []uint64
+	err := db.Model(&Message{}).
+		Where("published_at IS NULL AND dead_at IS NULL AND available_at <= ?", now).
+		Order("id").
+		Limit(e.opts.batch).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	owner := uuid.New().String()
+	err = db.Model(&Message{}).
+		Where("id IN ? AND published_at IS NULL AND dead_at IS NULL AND available_at <= ?", ids, now).
+		Updates(map[string]interface{}{
+			"owner":        owner,
+			"available_at": now.Add(e.opts.lease),
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	var list []Message
+	err = db.Where("id IN ? AND owner = ?", ids, owner).Order("id").Find(&list).Error
+	return list, err
+}
+
+// publish 发布一条消息, 发布失败时按退避策略推迟下次认领, 超过重试次数时转为死信
+func (e *Relay) publish(ctx context.Context, record *Message) error {
+	// 租期已过被其他实例认领的消息不再更新
+	db := e.db.WithContext(ctx).Model(&Message{}).Where("id = ? AND owner = ?", record.ID, record.Owner)
+	message, err := record.message()
+	if err == nil {
+		err = e.queue.Append(message)
+	}
+	if err != nil {
+		log.Errorf("outbox: publish message %d to %s error, %s", record.ID, record.Stream, err.Error())
+		cause := err.Error()
+		if len(cause) > 1024 {
+			cause = cause[:1024]
+		}
+		attempts := record.Attempts + 1
+		values := map[string]interface{}{
+			"attempts":   attempts,
+			"last_error": cause,
+			"owner":      "",
+		}
+		if !e.opts.retry.Exhausted(attempts) {
+			values["available_at"] = time.Now().Add(e.opts.retry.Backoff(attempts))
+			return db.Updates(values).Error
+		}
+		log.Warnf("outbox: message %d of %s failed %d times, move to dead letter", record.ID, record.Stream, attempts)
+		values["dead_at"] = time.Now()
+		if message != nil {
+			message.SetStream(storage.DeadLetterStream(record.Stream))
+			message.SetHeader(storage.HeaderAttempt, strconv.Itoa(attempts))
+			message.SetHeader(storage.HeaderError, cause)
+			message.SetErrorCount(attempts)
+			// 死信队列也不可用时仍保留在outbox表中
+			if err = e.queue.Append(message); err != nil {
+				log.Errorf("outbox: publish message %d to dead letter error, %s", record.ID, err.Error())
+			}
+		}
+		return db.Updates(values).Error
+	}
+	if e.opts.retention <= 0 {
+		return db.Delete(&Message{}).Error
+	}
+	return db.Update("published_at", time.Now()).Error
+}
+
+// Cleanup 删除超过保留时间的已发布消息, 返回删除的条数
+func (e *Relay) Cleanup(ctx context.Context) (int64, error) {
+	db := e.db.WithContext(ctx)
+	before := time.Now().Add(-e.opts.retention)
+	var total int64
+	for {
+		var ids []uint64
+		err := db.Model(&Message{}).
+			Where("published_at IS NOT NULL AND published_at < ?", before).
+			Limit(e.opts.batch).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return total, err
+		}
+		result := db.Where("id IN ?", ids).Delete(&Message{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if len(ids) < e.opts.batch {
+			return total, nil
+		}
+	}
+}
+
+// message 还原为队列消息
+func (e *Message) message() (storage.Messager, error) {
+	values := make(map[string]interface{})
+	if err := json.UnmarshalFromString(e.Values, &values); err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string)
+	if e.Headers != "" {
+		if err := json.UnmarshalFromString(e.Headers, &headers); err != nil {
+			return nil, err
+		}
+	}
+	headers[HeaderOutboxID] = strconv.FormatUint(e.ID, 10)
+	m := new(queue.Message)
+	m.SetStream(e.Stream)
+	m.SetValues(values)
+	m.SetHeaders(headers)
+	return m, nil
+}
@@ -0,0 +1,230 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/alopt/go-admin-core/storage"
+	"github.com/alopt/go-admin-core/storage/queue"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// failQueue 前fail次Append失败
+type failQueue struct {
+	*queue.Memory
+	mux  sync.Mutex
+	fail int
+	list []storage.Messager
+}
+
+func (q *failQueue) Append(message storage.Messager) error {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.fail > 0 {
+		q.fail--
+		return errors.New("queue unavailable")
+	}
+	q.list = append(q.list, message)
+	return nil
+}
+
+func newMessage(stream string, i int) storage.Messager {
+	m := new(queue.Message)
+	m.SetStream(stream)
+	m.SetValues(map[string]interface{}{"i": i})
+	m.SetPrefix("tenant")
+	return m
+}
+
+func TestAppend(t *testing.T) {
+	db := newTestDB(t)
+	// 回滚的事务不会发布消息
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		if err := Append(tx, newMessage("test", 1)); err != nil {
+			t.Fatal(err)
+		}
+		return errors.New("rollback")
+	})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return Append(tx, newMessage("test", 2))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Message
+	db.Find(&list)
+	if len(list) != 1 || list[0].Stream != "test" {
+		t.Fatalf("outbox = %+v", list)
+	}
+	if err = Append(db, newMessage("", 3)); err == nil {
+		t.Fatal("Append() with empty stream should fail")
+	}
+}
+
+func TestRelay_Relay(t *testing.T) {
+	db := newTestDB(t)
+	q := &failQueue{fail: 1}
+	r := NewRelay(db, q, WithRetry(storage.RetryPolicy{InitialBackoff: time.Millisecond}))
+	for i := 0; i < 3; i++ {
+		if err := Append(db, newMessage("test", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AppendAt(db, newMessage("test", 3), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Relay(context.TODO()); err != nil || n != 3 {
+		t.Fatalf("Relay() = %d, %v", n, err)
+	}
+	var failed Message
+	db.First(&failed)
+	if failed.Attempts != 1 || failed.LastError == "" || failed.PublishedAt != nil {
+		t.Fatalf("failed message = %+v", failed)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n, err := r.Relay(context.TODO()); err != nil || n != 1 {
+		t.Fatalf("Relay() = %d, %v", n, err)
+	}
+	if len(q.list) != 3 {
+		t.Fatalf("published = %d, want 3", len(q.list))
+	}
+	m := q.list[2]
+	if m.GetPrefix() != "tenant" || m.GetHeader(HeaderOutboxID) != "1" ||
+		m.GetHeader(storage.HeaderPublishTime) == "" {
+		t.Fatalf("headers = %v", m.GetHeaders())
+	}
+	if n, _ := r.Relay(context.TODO()); n != 0 {
+		t.Fatalf("Relay() = %d after all published", n)
+	}
+}
+
+func TestRelay_DeadLetter(t *testing.T) {
+	db := newTestDB(t)
+	q := &failQueue{fail: 2}
+	r := NewRelay(db, q, WithRetry(storage.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	if err := Append(db, newMessage("test", 1)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := r.Relay(context.TODO()); err != nil || n != 1 {
+			t.Fatalf("Relay() = %d, %v", n, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var dead Message
+	db.First(&dead)
+	if dead.Attempts != 2 || dead.DeadAt == nil || dead.PublishedAt != nil {
+		t.Fatalf("dead message = %+v", dead)
+	}
+	if len(q.list) != 1 || q.list[0].GetStream() != storage.DeadLetterStream("test") ||
+		q.list[0].GetHeader(storage.HeaderAttempt) != "2" || q.list[0].GetHeader(HeaderOutboxID) != "1" {
+		t.Fatalf("dead letters = %v", q.list)
+	}
+	if n, _ := r.Relay(context.TODO()); n != 0 {
+		t.Fatalf("Relay() = %d, dead message should not be claimed", n)
+	}
+}
+
+func TestRelay_Lease(t *testing.T) {
+	db := newTestDB(t)
+	q := &failQueue{}
+	r := NewRelay(db, q, WithLease(time.Hour))
+	if err := Append(db, newMessage("test", 1)); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟其他实例认领后退出
+	list, err := r.claim(context.TODO())
+	if err != nil || len(list) != 1 {
+		t.Fatalf("claim() = %d, %v", len(list), err)
+	}
+	if n, _ := r.Relay(context.TODO()); n != 0 {
+		t.Fatalf("Relay() = %d, claimed message should be skipped", n)
+	}
+	db.Model(&Message{}).Where("id = ?", list[0].ID).Update("available_at", time.Now())
+	if n, _ := r.Relay(context.TODO()); n != 1 || len(q.list) != 1 {
+		t.Fatalf("Relay() = %d after lease expired", n)
+	}
+}
+
+func TestRelay_Cleanup(t *testing.T) {
+	db := newTestDB(t)
+	r := NewRelay(db, &failQueue{}, WithRetention(time.Hour), WithBatch(2))
+	for i := 0; i < 5; i++ {
+		if err := Append(db, newMessage("test", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for n, _ := r.Relay(context.TODO()); n > 0; n, _ = r.Relay(context.TODO()) {
+	}
+	db.Model(&Message{}).Where("id < ?", 5).Update("published_at", time.Now().Add(-2*time.Hour))
+	if n, err := r.Cleanup(context.TODO()); err != nil || n != 4 {
+		t.Fatalf("Cleanup() = %d, %v", n, err)
+	}
+	var count int64
+	db.Model(&Message{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("remain = %d, want 1", count)
+	}
+
+	// 不保留时发布后立即删除
+	r = NewRelay(db, &failQueue{}, WithRetention(0))
+	if err := Append(db, newMessage("test", 6)); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = r.Relay(context.TODO())
+	db.Model(&Message{}).Where("published_at IS NULL").Count(&count)
+	if count != 0 {
+		t.Fatalf("pending = %d, want 0", count)
+	}
+}
+
+func TestRelay_Start(t *testing.T) {
+	db := newTestDB(t)
+	q := &failQueue{}
+	r := NewRelay(db, q, WithInterval(10*time.Millisecond))
+	if err := Append(db, newMessage("test", 1)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Start(ctx)
+	}()
+	for i := 0; i < 100; i++ {
+		q.mux.Lock()
+		n := len(q.list)
+		q.mux.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if r.Attempt() || len(q.list) != 1 {
+		t.Fatalf("Attempt() = %v, published = %d", r.Attempt(), len(q.list))
+	}
+}
@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	json "github.com/json-iterator/go"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeMsgpack  = "application/msgpack"
+	ContentTypeProtobuf = "application/protobuf"
+)
+
+var (
+	// JSONCodec 默认编码
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec msgpack编码
+	MsgpackCodec Codec = msgpackCodec{}
+	// ProtobufCodec protobuf编码, 只支持proto.Message
+	ProtobufCodec Codec = protobufCodec{}
+
+	codecs sync.Map
+)
+
+func init() {
+	RegisterCodec(JSONCodec)
+	RegisterCodec(MsgpackCodec)
+	RegisterCodec(ProtobufCodec)
+}
+
+// Codec 消息体编解码, ContentType写入消息头 storage.HeaderContentType
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// RegisterCodec 注册编码, 消费时按消息头的content-type查找, 相同content-type后注册的生效
+func RegisterCodec(codec Codec) {
+	codecs.Store(codec.ContentType(), codec)
+}
+
+// GetCodec 按content-type获取编码, 为空时使用JSONCodec
+func GetCodec(contentType string) (Codec, error) {
+	if contentType == "" {
+		return JSONCodec, nil
+	}
+	v, ok := codecs.Load(contentType)
+	if !ok {
+		return nil, fmt.Errorf("queue: codec %s not registered", contentType)
+	}
+	return v.(Codec), nil
+}
+
+// CodecOf 消息使用的编码
+func CodecOf(message storage.Messager) (Codec, error) {
+	return GetCodec(message.GetHeader(storage.HeaderContentType))
+}
+
+// textual 文本编码的消息体原样保存, 其余的以base64保存, 避免经过json的传输破坏二进制数据
+func textual(contentType string) bool {
+	return strings.HasSuffix(contentType, "json") || strings.HasPrefix(contentType, "text/")
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return ContentTypeMsgpack
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("queue: %T is not proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("queue: %T is not proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+type delayed struct {
+	at      time.Time
+	message storage.Messager
+}
+
+// delayHeap 按投递时间排序的最小堆
+type delayHeap []*delayed
+
+func (h delayHeap) Len() int           { return len(h) }
+func (h delayHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h delayHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *delayHeap) Push(x interface{}) {
+	*h = append(*h, x.(*delayed))
+}
+
+func (h *delayHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+// scheduler 基于定时器堆的延迟投递, 只用一个goroutine和一个timer
+type scheduler struct {
+	mux     sync.Mutex
+	items   delayHeap
+	deliver func(storage.Messager) error
+	wake    chan struct{}
+	done    chan struct{}
+	start   sync.Once
+	stop    sync.Once
+}
+
+func newScheduler(deliver func(storage.Messager) error) *scheduler {
+	return &scheduler{
+		deliver: deliver,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// schedule 加入延迟队列, 首次调用时启动调度goroutine
+func (s *scheduler) schedule(at time.Time, message storage.Messager) {
+	s.start.Do(func() {
+		go s.run()
+	})
+	s.mux.Lock()
+	heap.Push(&s.items, &delayed{at: at, message: message})
+	s.mux.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len 等待投递的消息数
+func (s *scheduler) Len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.items.Len()
+}
+
+func (s *scheduler) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		for _, message := range s.due() {
+			if err := s.deliver(message); err != nil {
+				log.Errorf("queue: deliver delayed message to %s error, %s", message.GetStream(), err.Error())
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next, ok := s.next(); ok {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// due 取出所有已到期的消息
+func (s *scheduler) due() []storage.Messager {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	var list []storage.Messager
+	for s.items.Len() > 0 && !s.items[0].at.After(now) {
+		list = append(list, heap.Pop(&s.items).(*delayed).message)
+	}
+	return list
+}
+
+func (s *scheduler) next() (time.Time, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.items.Len() == 0 {
+		return time.Time{}, false
+	}
+	return s.items[0].at, true
+}
+
+func (s *scheduler) shutdown() {
+	s.stop.Do(func() {
+		close(s.done)
+	})
+}
@@ -0,0 +1,532 @@
+package queue
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// FileSync 文件队列落盘策略
+type FileSync string
+
+const (
+	// FileSyncAlways 每次写入都落盘, 最安全也最慢
+	FileSyncAlways FileSync = "always"
+	// FileSyncInterval 定时落盘, 宕机时可能丢失最后一个间隔内的消息
+	FileSyncInterval FileSync = "interval"
+	// FileSyncNever 由操作系统决定落盘时机
+	FileSyncNever FileSync = "never"
+)
+
+// FileOption 文件队列参数设置类型
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	segmentSize  int64
+	sync         FileSync
+	syncInterval time.Duration
+}
+
+func setDefaultFileOptions() fileOptions {
+	return fileOptions{
+		segmentSize:  64 << 20,
+		sync:         FileSyncInterval,
+		syncInterval: time.Second,
+	}
+}
+
+// WithSegmentSize 设置单个日志段的最大字节数, 超过后新建一段
+func WithSegmentSize(n int64) FileOption {
+	return func(o *fileOptions) {
+		o.segmentSize = n
+	}
+}
+
+// WithFileSync 设置落盘策略
+func WithFileSync(sync FileSync) FileOption {
+	return func(o *fileOptions) {
+		o.sync = sync
+	}
+}
+
+// WithSyncInterval 设置FileSyncInterval的落盘间隔
+func WithSyncInterval(d time.Duration) FileOption {
+	return func(o *fileOptions) {
+		o.syncInterval = d
+	}
+}
+
+// fileRecord stream日志中的记录
+type fileRecord struct {
+	Headers map[string]string      `json:"headers,omitempty"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+// fileDelayed 延迟日志中的记录
+type fileDelayed struct {
+	Stream  string                 `json:"stream"`
+	At      int64                  `json:"at"`
+	Headers map[string]string      `json:"headers,omitempty"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+// delayedEntry 延迟消息及其在延迟日志中的offset
+type delayedEntry struct {
+	*Message
+	offset uint64
+}
+
+type fileJob struct {
+	payload []byte
+	offset  uint64
+}
+
+// appendRetryPolicy 失败消息重新投递出错时的退避策略
+var appendRetryPolicy = storage.RetryPolicy{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// NewFile 文件模式, 每个stream写入path下独立的分段日志,
+// 消费位置持久化保存, 重启后从未确认的消息继续投递(至少一次)
+func NewFile(path string, opts ...FileOption) (*File, error) {
+	e := &File{
+		path:      path,
+		opts:      setDefaultFileOptions(),
+		logs:      make(map[string]*segmentLog),
+		consumers: make(map[string]struct{}),
+		stop:      make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(&e.opts)
+	}
+	if e.opts.segmentSize <= 0 {
+		e.opts.segmentSize = setDefaultFileOptions().segmentSize
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.delay = newScheduler(e.deliver)
+	if err := e.recoverDelayed(); err != nil {
+		return nil, err
+	}
+	if e.opts.sync == FileSyncInterval && e.opts.syncInterval > 0 {
+		go e.syncLoop()
+	}
+	return e, nil
+}
+
+// File 基于本地磁盘的队列, 适用于单节点部署
+type File struct {
+	path           string
+	opts           fileOptions
+	logs           map[string]*segmentLog
+	consumers      map[string]struct{}
+	delayed        *segmentLog
+	delayedTracker *tracker
+	delay          *scheduler
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wait           sync.WaitGroup
+	mux            sync.Mutex
+	stop           chan struct{}
+	once           sync.Once
+}
+
+func (*File) String() string {
+	return "file"
+}
+
+// getLog 获取stream的日志, 不存在时创建; stream名称转义后作为目录名
+func (e *File) getLog(stream string) (*segmentLog, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if l, ok := e.logs[stream]; ok {
+		return l, nil
+	}
+	l, err := openLog(filepath.Join(e.path, "streams", url.PathEscape(stream)), e.opts)
+	if err != nil {
+		return nil, err
+	}
+	e.logs[stream] = l
+	return l, nil
+}
+
+// Append 写入stream的日志
+func (e *File) Append(message storage.Messager) error {
+	l, err := e.getLog(message.GetStream())
+	if err != nil {
+		return err
+	}
+	rb, err := json.Marshal(&fileRecord{
+		Headers: published(message),
+		Values:  message.GetValues(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = l.append(rb)
+	return err
+}
+
+// AppendDelay 延迟投递
+func (e *File) AppendDelay(message storage.Messager, delay time.Duration) error {
+	return e.AppendAt(message, time.Now().Add(delay))
+}
+
+// AppendAt 定时投递, 延迟消息先写入延迟日志, 重启后重新加载
+func (e *File) AppendAt(message storage.Messager, at time.Time) error {
+	if !at.After(time.Now()) {
+		return e.Append(message)
+	}
+	m := copyMessage(message)
+	rb, err := json.Marshal(&fileDelayed{
+		Stream:  m.Stream,
+		At:      at.UnixMilli(),
+		Headers: m.Headers,
+		Values:  m.Values,
+	})
+	if err != nil {
+		return err
+	}
+	offset, err := e.delayed.append(rb)
+	if err != nil {
+		return err
+	}
+	e.delay.schedule(at, &delayedEntry{Message: m, offset: offset})
+	return nil
+}
+
+// recoverDelayed 加载未投递的延迟消息
+func (e *File) recoverDelayed() (err error) {
+	e.delayed, err = openLog(filepath.Join(e.path, "delayed"), e.opts)
+	if err != nil {
+		return err
+	}
+	offset, done, err := e.delayed.readOffset()
+	if err != nil {
+		return err
+	}
+	e.delayedTracker = newTracker(offset, done)
+	reader := newLogReader(e.delayed, offset)
+	defer reader.close()
+	for {
+		payload, offset, err := reader.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err == errCorrupt {
+			log.Errorf("queue: skip corrupt delayed message %d", offset)
+			if err = e.delayedTracker.complete(offset, e.delayed.commit); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if e.delayedTracker.isDone(offset) {
+			continue
+		}
+		record := new(fileDelayed)
+		if err = json.Unmarshal(payload, record); err != nil {
+			log.Errorf("queue: decode delayed message %d error, %s", offset, err.Error())
+			continue
+		}
+		m := new(Message)
+		m.SetStream(record.Stream)
+		m.SetValues(record.Values)
+		m.SetHeaders(record.Headers)
+		m.SetErrorCount(attempts(record.Headers))
+		e.delay.schedule(time.UnixMilli(record.At), &delayedEntry{Message: m, offset: offset})
+	}
+}
+
+// deliver 到期的延迟消息写入对应stream后才确认
+func (e *File) deliver(message storage.Messager) error {
+	if err := e.Append(message); err != nil {
+		return err
+	}
+	entry, ok := message.(*delayedEntry)
+	if !ok {
+		return nil
+	}
+	return e.delayedTracker.complete(entry.offset, e.delayed.commit)
+}
+
+func (e *File) Register(name string, f storage.ConsumerFunc, opts ...storage.RegisterOption) {
+	e.RegisterCtx(name, func(_ context.Context, message storage.Messager) error {
+		return f(message)
+	}, opts...)
+}
+
+// RegisterCtx 注册消费者, 从已确认的位置开始投递, 默认每个stream一个协程
+func (e *File) RegisterCtx(name string, f storage.ConsumerCtxFunc, opts ...storage.RegisterOption) {
+	o := storage.NewRegisterOptions(opts...)
+	l, err := e.getLog(name)
+	if err != nil {
+		log.Errorf("queue: register %s error, %s", name, err.Error())
+		return
+	}
+	offset, done, err := l.readOffset()
+	if err != nil {
+		log.Errorf("queue: register %s error, %s", name, err.Error())
+		return
+	}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	select {
+	case <-e.stop:
+		log.Errorf("queue: register %s after shutdown", name)
+		return
+	default:
+	}
+	if _, ok := e.consumers[name]; ok {
+		log.Errorf("queue: stream %s already registered", name)
+		return
+	}
+	e.consumers[name] = struct{}{}
+	concurrency := o.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	work := make(chan fileJob)
+	t := newTracker(offset, done)
+	e.wait.Add(concurrency + 1)
+	go e.dispatch(l, t, newLogReader(l, offset), work)
+	for i := 0; i < concurrency; i++ {
+		go e.work(name, l, t, work, f, o.Retry)
+	}
+}
+
+// dispatch 顺序读取日志分发给消费协程, 关闭时未分发的消息留在日志中
+func (e *File) dispatch(l *segmentLog, t *tracker, reader *logReader, work chan<- fileJob) {
+	defer e.wait.Done()
+	defer close(work)
+	defer reader.close()
+	for {
+		payload, offset, err := reader.read()
+		if err == io.EOF {
+			select {
+			case <-e.stop:
+				return
+			case <-l.notify:
+			}
+			continue
+		}
+		if err == errCorrupt {
+			// 损坏的记录无法投递, 确认后继续读取, 避免阻塞后续消息
+			log.Errorf("queue: skip corrupt message %d of %s", offset, l.dir)
+			if err = t.complete(offset, l.commit); err != nil {
+				log.Errorf("queue: commit offset of %s error, %s", l.dir, err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			log.Errorf("queue: read %s error, %s", l.dir, err.Error())
+			reader.close()
+			select {
+			case <-e.stop:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if t.isDone(offset) {
+			continue
+		}
+		select {
+		case <-e.stop:
+			return
+		case work <- fileJob{payload: payload, offset: offset}:
+		}
+	}
+}
+
+func (e *File) work(stream string, l *segmentLog, t *tracker, work <-chan fileJob,
+	f storage.ConsumerCtxFunc, policy storage.RetryPolicy) {
+	defer e.wait.Done()
+	for job := range work {
+		record := new(fileRecord)
+		if err := json.Unmarshal(job.payload, record); err != nil {
+			// 无法解析的消息不会成功, 直接跳过
+			log.Errorf("queue: decode message %d of %s error, %s", job.offset, stream, err.Error())
+		} else if err = e.handle(stream, job.offset, record, f, policy); err != nil {
+			// 仅在关闭时未能重新投递, 未确认的消息在重启后重新投递
+			continue
+		}
+		if err := t.complete(job.offset, l.commit); err != nil {
+			log.Errorf("queue: commit offset of %s error, %s", stream, err.Error())
+		}
+	}
+}
+
+func (e *File) handle(stream string, offset uint64, record *fileRecord,
+	f storage.ConsumerCtxFunc, policy storage.RetryPolicy) error {
+	m := new(Message)
+	m.SetID(strconv.FormatUint(offset, 10))
+	m.SetStream(stream)
+	m.SetValues(record.Values)
+	m.SetHeaders(record.Headers)
+	m.SetErrorCount(attempts(record.Headers))
+	err := f(e.ctx, m)
+	if err == nil {
+		return nil
+	}
+	// 重新投递失败时按退避重试, 直到成功或队列关闭
+	for attempt := 1; ; attempt++ {
+		rerr := retry(e, m, policy, err)
+		if rerr == nil {
+			return nil
+		}
+		log.Errorf("queue: retry message %d of %s error, %s", offset, stream, rerr.Error())
+		select {
+		case <-e.stop:
+			return rerr
+		case <-time.After(appendRetryPolicy.Backoff(attempt)):
+		}
+	}
+}
+
+func (e *File) deadLetter(message storage.Messager) error {
+	return e.Append(message)
+}
+
+// DeadLetters 查看最多limit条未重放的死信, limit<=0返回全部
+func (e *File) DeadLetters(stream string, limit int) ([]storage.Messager, error) {
+	dlq := storage.DeadLetterStream(stream)
+	list, _, err := e.readDeadLetters(dlq, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]storage.Messager, 0, len(list))
+	for i := range list {
+		list[i].SetStream(dlq)
+		result = append(result, list[i])
+	}
+	return result, nil
+}
+
+// Replay 将最多limit条死信重新投递到原stream, limit<=0重放全部
+func (e *File) Replay(stream string, limit int) (int, error) {
+	dlq := storage.DeadLetterStream(stream)
+	list, l, err := e.readDeadLetters(dlq, limit)
+	if err != nil {
+		return 0, err
+	}
+	for i := range list {
+		if err = e.Append(revive(list[i], stream)); err != nil {
+			return i, err
+		}
+		offset, _ := strconv.ParseUint(list[i].GetID(), 10, 64)
+		if err = l.commit(offset+1, nil); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(list), nil
+}
+
+func (e *File) readDeadLetters(dlq string, limit int) ([]*Message, *segmentLog, error) {
+	l, err := e.getLog(dlq)
+	if err != nil {
+		return nil, nil, err
+	}
+	offset, _, err := l.readOffset()
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := newLogReader(l, offset)
+	defer reader.close()
+	var list []*Message
+	for limit <= 0 || len(list) < limit {
+		payload, offset, err := reader.read()
+		if err == io.EOF {
+			break
+		}
+		if err == errCorrupt {
+			log.Errorf("queue: skip corrupt message %d of %s", offset, dlq)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		record := new(fileRecord)
+		if err = json.Unmarshal(payload, record); err != nil {
+			return nil, nil, err
+		}
+		m := new(Message)
+		m.SetID(strconv.FormatUint(offset, 10))
+		m.SetValues(record.Values)
+		m.SetHeaders(record.Headers)
+		m.SetErrorCount(attempts(record.Headers))
+		list = append(list, m)
+	}
+	return list, l, nil
+}
+
+// syncLoop 定时落盘
+func (e *File) syncLoop() {
+	ticker := time.NewTicker(e.opts.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			for _, l := range e.allLogs() {
+				if err := l.sync(); err != nil {
+					log.Errorf("queue: sync %s error, %s", l.dir, err.Error())
+				}
+			}
+		}
+	}
+}
+
+func (e *File) allLogs() []*segmentLog {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	list := make([]*segmentLog, 0, len(e.logs)+1)
+	list = append(list, e.delayed)
+	for _, l := range e.logs {
+		list = append(list, l)
+	}
+	return list
+}
+
+// Run 阻塞直到队列关闭, 消费者在注册时已经开始运行
+func (e *File) Run() {
+	<-e.stop
+}
+
+func (e *File) Shutdown() {
+	_ = e.ShutdownCtx(context.Background())
+}
+
+// ShutdownCtx 停止投递并等待处理中的消息完成后关闭日志, 超时时日志保持打开
+func (e *File) ShutdownCtx(ctx context.Context) error {
+	e.once.Do(func() {
+		e.mux.Lock()
+		close(e.stop)
+		e.mux.Unlock()
+		e.delay.shutdown()
+	})
+	if err := drain(ctx, &e.wait, e.cancel); err != nil {
+		return err
+	}
+	var err error
+	for _, l := range e.allLogs() {
+		if cerr := l.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,392 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alopt/redisqueue/v2"
+	"github.com/spf13/cast"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+func newFileMessage(stream string, i int) *Message {
+	return &Message{Message: redisqueue.Message{
+		Stream: stream,
+		Values: map[string]interface{}{"i": i},
+	}}
+}
+
+// collect 注册消费者并返回收到的i值
+func collect(q *File, stream string) func(n int) []int {
+	var mux sync.Mutex
+	var got []int
+	q.Register(stream, func(message storage.Messager) error {
+		i := cast.ToInt(message.GetValues()["i"])
+		mux.Lock()
+		got = append(got, i)
+		mux.Unlock()
+		return nil
+	})
+	return func(n int) []int {
+		for j := 0; j < 200; j++ {
+			mux.Lock()
+			l := len(got)
+			mux.Unlock()
+			if l >= n {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		return append([]int(nil), got...)
+	}
+}
+
+func TestFile_AppendRegister(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewFile(dir, WithFileSync(FileSyncAlways))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err = q.Append(newFileMessage("test", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait := collect(q, "test")
+	if got := wait(5); len(got) != 5 || got[0] != 0 || got[4] != 4 {
+		t.Fatalf("received %v", got)
+	}
+	// 注册之后写入的消息
+	if err = q.Append(newFileMessage("test", 5)); err != nil {
+		t.Fatal(err)
+	}
+	if got := wait(6); len(got) != 6 || got[5] != 5 {
+		t.Fatalf("received %v", got)
+	}
+	if err = q.ShutdownCtx(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后不会重复投递已确认的消息
+	q, err = NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	if err = q.Append(newFileMessage("test", 6)); err != nil {
+		t.Fatal(err)
+	}
+	wait = collect(q, "test")
+	if got := wait(1); len(got) != 1 || got[0] != 6 {
+		t.Fatalf("received %v after restart", got)
+	}
+}
+
+func TestFile_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewFile(dir, WithFileSync(FileSyncNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = q.Append(newFileMessage("test", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = q.ShutdownCtx(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟写入一半时宕机
+	segment := filepath.Join(dir, "streams", "test", "00000000000000000000.log")
+	file, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write([]byte{0, 0, 0, 100, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	q, err = NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	if err = q.Append(newFileMessage("test", 3)); err != nil {
+		t.Fatal(err)
+	}
+	wait := collect(q, "test")
+	if got := wait(4); len(got) != 4 || got[3] != 3 {
+		t.Fatalf("received %v after recovery", got)
+	}
+}
+
+func TestFile_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewFile(dir, WithSegmentSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	for i := 0; i < 20; i++ {
+		if err = q.Append(newFileMessage("test", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "streams", "test", "*.log"))
+	if len(segments) < 5 {
+		t.Fatalf("segments = %d, want rotation", len(segments))
+	}
+	wait := collect(q, "test")
+	got := wait(20)
+	if len(got) != 20 {
+		t.Fatalf("received %d, want 20", len(got))
+	}
+	for i := range got {
+		if got[i] != i {
+			t.Fatalf("received %v out of order", got)
+		}
+	}
+	// 已消费完的段被删除
+	for i := 0; i < 100; i++ {
+		remain, _ := filepath.Glob(filepath.Join(dir, "streams", "test", "*.log"))
+		if len(remain) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("consumed segments not removed")
+}
+
+func TestFile_CorruptSegment(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(record []byte)
+	}{
+		{
+			name:    "crc",
+			corrupt: func(record []byte) { record[recordHeader] ^= 0xff },
+		},
+		{
+			name:    "length",
+			corrupt: func(record []byte) { record[0] = 0xff },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q, err := NewFile(dir, WithSegmentSize(64))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 20; i++ {
+				if err = q.Append(newFileMessage("test", i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = q.ShutdownCtx(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			// 损坏第二段(非最后一段)的第一条记录
+			segments, _ := filepath.Glob(filepath.Join(dir, "streams", "test", "*.log"))
+			rb, err := os.ReadFile(segments[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(rb)
+			if err = os.WriteFile(segments[1], rb, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			q, err = NewFile(dir, WithSegmentSize(64))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer q.Shutdown()
+			var mux sync.Mutex
+			var got []int
+			q.Register("test", func(message storage.Messager) error {
+				mux.Lock()
+				got = append(got, cast.ToInt(message.GetValues()["i"]))
+				mux.Unlock()
+				return nil
+			})
+			// 后续段的消息仍然投递, 已消费完的段被删除
+			for i := 0; i < 200; i++ {
+				remain, _ := filepath.Glob(filepath.Join(dir, "streams", "test", "*.log"))
+				mux.Lock()
+				last := len(got) > 0 && got[len(got)-1] == 19
+				mux.Unlock()
+				if last && len(remain) == 1 {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			t.Fatalf("stream stalled, received %v", got)
+		})
+	}
+}
+
+func TestFile_RetryAppend(t *testing.T) {
+	q, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	// 关闭延迟日志, 使失败消息重新投递出错
+	q.delayed.mux.Lock()
+	active := q.delayed.active
+	q.delayed.active = nil
+	q.delayed.mux.Unlock()
+
+	var mux sync.Mutex
+	calls := 0
+	done := make(chan struct{})
+	q.Register("test", func(message storage.Messager) error {
+		mux.Lock()
+		defer mux.Unlock()
+		calls++
+		if calls == 1 {
+			return errors.New("fail")
+		}
+		close(done)
+		return nil
+	}, storage.WithRetry(storage.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}))
+	if err = q.Append(newFileMessage("test", 1)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	q.delayed.mux.Lock()
+	q.delayed.active = active
+	q.delayed.mux.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not retried after append recovered")
+	}
+	// 原消息在重新投递成功后确认
+	l, _ := q.getLog("test")
+	for i := 0; i < 100; i++ {
+		if offset, _, _ := l.readOffset(); offset == 2 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	offset, done2, _ := l.readOffset()
+	t.Fatalf("offset = %d, done = %v, want 2", offset, done2)
+}
+
+func TestFile_Delayed(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = q.AppendDelay(newFileMessage("test", 1), 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.AppendDelay(newFileMessage("test", 2), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.ShutdownCtx(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后延迟消息仍然投递
+	q, err = NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	wait := collect(q, "test")
+	if got := wait(1); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("received %v", got)
+	}
+	if n := q.delay.Len(); n != 1 {
+		t.Fatalf("scheduled = %d, want 1", n)
+	}
+}
+
+func TestFile_DeadLetter(t *testing.T) {
+	q, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	var mux sync.Mutex
+	fail := true
+	received := make(chan int, 1)
+	q.Register("test", func(message storage.Messager) error {
+		mux.Lock()
+		defer mux.Unlock()
+		if fail {
+			return errors.New("fail")
+		}
+		received <- cast.ToInt(message.GetValues()["i"])
+		return nil
+	}, storage.WithRetry(storage.RetryPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond}))
+	m := newFileMessage("test", 7)
+	m.SetPrefix("tenant")
+	if err = q.Append(m); err != nil {
+		t.Fatal(err)
+	}
+	var list []storage.Messager
+	for i := 0; i < 100 && len(list) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		list, _ = q.DeadLetters("test", 0)
+	}
+	if len(list) != 1 || list[0].GetErrorCount() != 2 || list[0].GetStream() != "test.dlq" ||
+		list[0].GetPrefix() != "tenant" {
+		t.Fatalf("dead letters = %v", list)
+	}
+	mux.Lock()
+	fail = false
+	mux.Unlock()
+	if n, err := q.Replay("test", 0); err != nil || n != 1 {
+		t.Fatalf("Replay() = %d, %v", n, err)
+	}
+	select {
+	case i := <-received:
+		if i != 7 {
+			t.Fatalf("received %d", i)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replayed message not delivered")
+	}
+	if list, _ = q.DeadLetters("test", 0); len(list) != 0 {
+		t.Fatalf("dead letters = %d after replay", len(list))
+	}
+}
+
+func TestTracker(t *testing.T) {
+	var committed uint64
+	var done []uint64
+	commit := func(offset uint64, list []uint64) error {
+		committed, done = offset, list
+		return nil
+	}
+	tr := newTracker(0, nil)
+	_ = tr.complete(1, commit)
+	_ = tr.complete(3, commit)
+	if committed != 0 || len(done) != 2 {
+		t.Fatalf("committed = %d, done = %v", committed, done)
+	}
+	_ = tr.complete(0, commit)
+	if committed != 2 || len(done) != 1 || done[0] != 3 {
+		t.Fatalf("committed = %d, done = %v", committed, done)
+	}
+	if !tr.isDone(1) || !tr.isDone(3) || tr.isDone(2) {
+		t.Fatal("isDone() wrong")
+	}
+}
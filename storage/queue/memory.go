@@ -1,29 +1,47 @@
 package queue
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
+	log "github.com/alopt/go-admin-core/logger"
 	"github.com/alopt/go-admin-core/storage"
 )
 
+// ErrQueueClosed 队列已关闭
+var ErrQueueClosed = errors.New("queue: queue is closed")
+
 type queue chan storage.Messager
 
 // NewMemory 内存模式
 func NewMemory(poolNum uint) *Memory {
-	return &Memory{
+	m := &Memory{
 		queue:   new(sync.Map),
+		dead:    make(map[string][]storage.Messager),
+		stop:    make(chan struct{}),
 		PoolNum: poolNum,
 	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.delay = newScheduler(m.Append)
+	return m
 }
 
 type Memory struct {
-	queue   *sync.Map
-	wait    sync.WaitGroup
-	mutex   sync.RWMutex
-	PoolNum uint
+	queue     *sync.Map
+	wait      sync.WaitGroup
+	mutex     sync.RWMutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stop      chan struct{}
+	once      sync.Once
+	delay     *scheduler
+	dead      map[string][]storage.Messager
+	deadMutex sync.Mutex
+	PoolNum   uint
 }
 
 func (*Memory) String() string {
@@ -40,10 +58,12 @@ func (m *Memory) makeQueue() queue {
 func (m *Memory) Append(message storage.Messager) error {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
-	memoryMessage := new(Message)
-	memoryMessage.SetID(message.GetID())
-	memoryMessage.SetStream(message.GetStream())
-	memoryMessage.SetValues(message.GetValues())
+	select {
+	case <-m.stop:
+		return ErrQueueClosed
+	default:
+	}
+	memoryMessage := copyMessage(message)
 
 	v, ok := m.queue.Load(message.GetStream())
 
@@ -62,14 +82,50 @@ func (m *Memory) Append(message storage.Messager) error {
 	}
 	go func(gm storage.Messager, gq queue) {
 		gm.SetID(uuid.New().String())
-		gq <- gm
+		select {
+		case gq <- gm:
+		case <-m.stop:
+		}
 	}(memoryMessage, q)
 	return nil
 }
 
-func (m *Memory) Register(name string, f storage.ConsumerFunc) {
+// AppendDelay 延迟投递
+func (m *Memory) AppendDelay(message storage.Messager, delay time.Duration) error {
+	return m.AppendAt(message, time.Now().Add(delay))
+}
+
+// AppendAt 定时投递, 未到期的消息保存在定时器堆中, 进程退出后丢失
+func (m *Memory) AppendAt(message storage.Messager, at time.Time) error {
+	if !at.After(time.Now()) {
+		return m.Append(message)
+	}
+	select {
+	case <-m.stop:
+		return ErrQueueClosed
+	default:
+	}
+	m.delay.schedule(at, copyMessage(message))
+	return nil
+}
+
+func (m *Memory) Register(name string, f storage.ConsumerFunc, opts ...storage.RegisterOption) {
+	m.RegisterCtx(name, func(_ context.Context, message storage.Messager) error {
+		return f(message)
+	}, opts...)
+}
+
+// RegisterCtx 注册消费者, 默认每个stream一个协程, 可通过storage.WithConcurrency调整
+func (m *Memory) RegisterCtx(name string, f storage.ConsumerCtxFunc, opts ...storage.RegisterOption) {
+	o := storage.NewRegisterOptions(opts...)
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
+	select {
+	case <-m.stop:
+		log.Errorf("queue: register %s after shutdown", name)
+		return
+	default:
+	}
 	v, ok := m.queue.Load(name)
 	if !ok {
 		v = m.makeQueue()
@@ -83,29 +139,85 @@ func (m *Memory) Register(name string, f storage.ConsumerFunc) {
 		q = m.makeQueue()
 		m.queue.Store(name, q)
 	}
-	go func(out queue, gf storage.ConsumerFunc) {
-		var err error
-		for message := range q {
-			err = gf(message)
-			if err != nil {
-				if message.GetErrorCount() < 3 {
-					message.SetErrorCount(message.GetErrorCount() + 1)
-					// 每次间隔时长放大
-					i := time.Second * time.Duration(message.GetErrorCount())
-					time.Sleep(i)
-					out <- message
+	concurrency := o.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	m.wait.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go m.work(q, f, o.Retry)
+	}
+}
+
+func (m *Memory) work(q queue, f storage.ConsumerCtxFunc, policy storage.RetryPolicy) {
+	defer m.wait.Done()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case message := <-q:
+			if err := f(m.ctx, message); err != nil {
+				if err = retry(m, message, policy, err); err != nil {
+					log.Errorf("queue: retry message of %s error, %s", message.GetStream(), err.Error())
 				}
-				err = nil
 			}
 		}
-	}(q, f)
+	}
 }
 
+// deadLetter 死信保存在内存中, 可通过DeadLetters查看, Replay重放
+func (m *Memory) deadLetter(message storage.Messager) error {
+	m.deadMutex.Lock()
+	defer m.deadMutex.Unlock()
+	m.dead[message.GetStream()] = append(m.dead[message.GetStream()], message)
+	return nil
+}
+
+// DeadLetters 查看最多limit条死信, limit<=0返回全部
+func (m *Memory) DeadLetters(stream string, limit int) ([]storage.Messager, error) {
+	m.deadMutex.Lock()
+	defer m.deadMutex.Unlock()
+	list := m.dead[storage.DeadLetterStream(stream)]
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return append([]storage.Messager(nil), list...), nil
+}
+
+// Replay 将最多limit条死信重新投递到原stream, limit<=0重放全部
+func (m *Memory) Replay(stream string, limit int) (int, error) {
+	dlq := storage.DeadLetterStream(stream)
+	m.deadMutex.Lock()
+	list := m.dead[dlq]
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	m.dead[dlq] = m.dead[dlq][len(list):]
+	m.deadMutex.Unlock()
+	for i := range list {
+		if err := m.Append(revive(list[i], stream)); err != nil {
+			return i, err
+		}
+	}
+	return len(list), nil
+}
+
+// Run 阻塞直到队列关闭, 消费者在注册时已经开始运行
 func (m *Memory) Run() {
-	m.wait.Add(1)
-	m.wait.Wait()
+	<-m.stop
 }
 
 func (m *Memory) Shutdown() {
-	m.wait.Done()
+	_ = m.ShutdownCtx(context.Background())
+}
+
+// ShutdownCtx 停止消费并等待处理中的消息完成, 未处理的消息随进程退出丢失
+func (m *Memory) ShutdownCtx(ctx context.Context) error {
+	m.once.Do(func() {
+		m.mutex.Lock()
+		close(m.stop)
+		m.mutex.Unlock()
+		m.delay.shutdown()
+	})
+	return drain(ctx, &m.wait, m.cancel)
 }
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
@@ -25,22 +26,22 @@ func TestMemory_Append(t *testing.T) {
 	}
 	tests := []struct {
 		name    string
-		fields  fields
+		fields  *fields
 		args    args
 		wantErr bool
 	}{
 		{
 			"test01",
-			fields{},
+			&fields{},
 			args{
 				name: "test",
-				message: &Message{redisqueue.Message{
+				message: &Message{Message: redisqueue.Message{
 					ID:     "",
 					Stream: "test",
 					Values: map[string]interface{}{
 						"key": "value",
 					},
-				}, 3, sync.RWMutex{},
+				}, ErrorCount: 3,
 				},
 			},
 			false,
@@ -70,12 +71,12 @@ func TestMemory_Register(t *testing.T) {
 	}
 	tests := []struct {
 		name   string
-		fields fields
+		fields *fields
 		args   args
 	}{
 		{
 			"test01",
-			fields{},
+			&fields{},
 			args{
 				name: "test",
 				f: func(message storage.Messager) error {
@@ -89,12 +90,12 @@ func TestMemory_Register(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			m := NewMemory(100)
 			m.Register(tt.name, tt.args.f)
-			if err := m.Append(&Message{redisqueue.Message{
+			if err := m.Append(&Message{Message: redisqueue.Message{
 				Stream: "test",
 				Values: map[string]interface{}{
 					"key": "value",
 				},
-			}, 3, sync.RWMutex{}}); err != nil {
+			}, ErrorCount: 3}); err != nil {
 				t.Error(err)
 				return
 			}
@@ -106,3 +107,227 @@ func TestMemory_Register(t *testing.T) {
 		})
 	}
 }
+
+func TestMemory_AppendDelay(t *testing.T) {
+	m := NewMemory(100)
+	received := make(chan string, 2)
+	m.Register("delay", func(message storage.Messager) error {
+		received <- message.GetValues()["key"].(string)
+		return nil
+	})
+	go m.Run()
+	defer m.Shutdown()
+
+	start := time.Now()
+	if err := m.AppendDelay(&Message{Message: redisqueue.Message{
+		Stream: "delay",
+		Values: map[string]interface{}{"key": "later"},
+	}}, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AppendAt(&Message{Message: redisqueue.Message{
+		Stream: "delay",
+		Values: map[string]interface{}{"key": "sooner"},
+	}}, start.Add(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"sooner", "later"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("received %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s not delivered", want)
+		}
+	}
+	if time.Since(start) < 300*time.Millisecond {
+		t.Fatalf("delivered too early, %s", time.Since(start))
+	}
+	if n := m.delay.Len(); n != 0 {
+		t.Fatalf("%d messages still scheduled", n)
+	}
+}
+
+func TestMemory_DeadLetter(t *testing.T) {
+	m := NewMemory(100)
+	var calls int32
+	var mux sync.Mutex
+	fail := true
+	replayed := make(chan storage.Messager, 1)
+	m.Register("retry", func(message storage.Messager) error {
+		mux.Lock()
+		defer mux.Unlock()
+		calls++
+		if fail {
+			return fmt.Errorf("fail %d", message.GetErrorCount())
+		}
+		replayed <- message
+		return nil
+	}, storage.WithRetry(storage.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+	}))
+	go m.Run()
+	defer m.Shutdown()
+
+	if err := m.Append(&Message{Message: redisqueue.Message{
+		Stream: "retry",
+		Values: map[string]interface{}{"key": "value"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	var list []storage.Messager
+	for i := 0; i < 100 && len(list) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		list, _ = m.DeadLetters("retry", 0)
+	}
+	if len(list) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(list))
+	}
+	mux.Lock()
+	if calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+	fail = false
+	mux.Unlock()
+	dead := list[0]
+	if dead.GetStream() != "retry.dlq" || dead.GetErrorCount() != 3 ||
+		dead.GetHeader(storage.HeaderError) != "fail 2" {
+		t.Fatalf("dead letter = %s %d %v", dead.GetStream(), dead.GetErrorCount(), dead.GetValues())
+	}
+
+	if n, err := m.Replay("retry", 0); err != nil || n != 1 {
+		t.Fatalf("Replay() = %d, %v", n, err)
+	}
+	select {
+	case message := <-replayed:
+		if message.GetErrorCount() != 0 || message.GetValues()["key"] != "value" {
+			t.Fatalf("replayed = %d %v", message.GetErrorCount(), message.GetValues())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("replayed message not delivered")
+	}
+	if list, _ = m.DeadLetters("retry", 0); len(list) != 0 {
+		t.Fatalf("dead letters = %d after replay", len(list))
+	}
+}
+
+func TestMemory_ShutdownCtx(t *testing.T) {
+	m := NewMemory(100)
+	release := make(chan struct{})
+	var mux sync.Mutex
+	running, finished := 0, 0
+	m.RegisterCtx("drain", func(ctx context.Context, message storage.Messager) error {
+		mux.Lock()
+		running++
+		mux.Unlock()
+		<-release
+		mux.Lock()
+		finished++
+		mux.Unlock()
+		return nil
+	}, storage.WithConcurrency(3))
+	for i := 0; i < 3; i++ {
+		if err := m.Append(&Message{Message: redisqueue.Message{
+			Stream: "drain",
+			Values: map[string]interface{}{"i": i},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		n := running
+		mux.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mux.Lock()
+	if running != 3 {
+		t.Fatalf("running = %d, want 3", running)
+	}
+	mux.Unlock()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.ShutdownCtx(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if finished != 3 {
+		t.Fatalf("finished = %d, want 3", finished)
+	}
+	message := &Message{Message: redisqueue.Message{Stream: "drain", Values: map[string]interface{}{"i": 3}}}
+	if err := m.Append(message); err != ErrQueueClosed {
+		t.Errorf("Append() after shutdown error = %v, want %v", err, ErrQueueClosed)
+	}
+	if err := m.AppendDelay(message, time.Minute); err != ErrQueueClosed {
+		t.Errorf("AppendDelay() after shutdown error = %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestMemory_ShutdownCtxTimeout(t *testing.T) {
+	m := NewMemory(100)
+	canceled := make(chan struct{})
+	m.RegisterCtx("timeout", func(ctx context.Context, message storage.Messager) error {
+		<-ctx.Done()
+		close(canceled)
+		return nil
+	})
+	if err := m.Append(&Message{Message: redisqueue.Message{
+		Stream: "timeout",
+		Values: map[string]interface{}{"key": "value"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.ShutdownCtx(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("ShutdownCtx() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("consumer context not canceled")
+	}
+}
+
+func TestRunnable_Start(t *testing.T) {
+	m := NewMemory(100)
+	r := NewRunnable(m, time.Second)
+	if !r.Attempt() {
+		t.Fatal("Attempt() = false before start")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Start(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if r.Attempt() {
+		t.Fatal("Attempt() = true after start")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start() not returned after context canceled")
+	}
+	select {
+	case <-m.stop:
+	default:
+		t.Fatal("queue not shut down")
+	}
+}
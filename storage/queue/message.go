@@ -2,18 +2,95 @@ package queue
 
 import (
 	"sync"
+	"time"
 
 	"github.com/alopt/redisqueue/v2"
+	json "github.com/json-iterator/go"
 
 	"github.com/alopt/go-admin-core/storage"
 )
 
+// headersKey 不支持消息头的传输(redis stream、nsq)中保存消息头的字段
+const headersKey = "__headers"
+
 type Message struct {
 	redisqueue.Message
 	ErrorCount int
+	Headers    map[string]string
 	mux        sync.RWMutex
 }
 
+// copyMessage 转换为本包的Message, 用于投递, 消息头带上发布时间
+func copyMessage(message storage.Messager) *Message {
+	m := new(Message)
+	m.SetID(message.GetID())
+	m.SetStream(message.GetStream())
+	m.SetValues(message.GetValues())
+	m.SetHeaders(published(message))
+	m.SetErrorCount(attempts(m.Headers))
+	return m
+}
+
+func cloneHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(headers))
+	for k, v := range headers {
+		clone[k] = v
+	}
+	return clone
+}
+
+// published 返回带发布时间的消息头, 重试和延迟投递时保留首次发布时间
+func published(message storage.Messager) map[string]string {
+	headers := cloneHeaders(message.GetHeaders())
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	if headers[storage.HeaderPublishTime] == "" {
+		headers[storage.HeaderPublishTime] = time.Now().Format(time.RFC3339Nano)
+	}
+	return headers
+}
+
+// encodeValues 将消息头编码为values中的一个字段
+func encodeValues(values map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
+	if len(headers) == 0 {
+		return values, nil
+	}
+	rb, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		encoded[k] = v
+	}
+	encoded[headersKey] = string(rb)
+	return encoded, nil
+}
+
+// decodeValues 从values中取出消息头, 解析失败时消息头为空
+func decodeValues(values map[string]interface{}) (map[string]interface{}, map[string]string) {
+	raw, ok := values[headersKey]
+	if !ok {
+		return values, nil
+	}
+	decoded := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if k != headersKey {
+			decoded[k] = v
+		}
+	}
+	s, _ := raw.(string)
+	headers := make(map[string]string)
+	if err := json.UnmarshalFromString(s, &headers); err != nil {
+		return decoded, nil
+	}
+	return decoded, headers
+}
+
 func (m *Message) GetID() string {
 	return m.ID
 }
@@ -46,7 +123,11 @@ func (m *Message) SetValues(values map[string]interface{}) {
 	m.Values = values
 }
 
+// GetPrefix 租户前缀, 兼容旧版本保存在values中的前缀
 func (m *Message) GetPrefix() (prefix string) {
+	if prefix = m.GetHeader(storage.HeaderTenant); prefix != "" {
+		return
+	}
 	m.mux.Lock()
 	defer m.mux.Unlock()
 	if m.Values == nil {
@@ -57,13 +138,36 @@ func (m *Message) GetPrefix() (prefix string) {
 	return
 }
 
+// SetPrefix 设置租户前缀, 保存在消息头中
 func (m *Message) SetPrefix(prefix string) {
+	m.SetHeader(storage.HeaderTenant, prefix)
+}
+
+func (m *Message) GetHeaders() map[string]string {
 	m.mux.Lock()
 	defer m.mux.Unlock()
-	if m.Values == nil {
-		m.Values = make(map[string]interface{})
+	return m.Headers
+}
+
+func (m *Message) SetHeaders(headers map[string]string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.Headers = headers
+}
+
+func (m *Message) GetHeader(key string) string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.Headers[key]
+}
+
+func (m *Message) SetHeader(key, value string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
 	}
-	m.Values[storage.PrefixKey] = prefix
+	m.Headers[key] = value
 }
 
 func (m *Message) SetErrorCount(count int) {
@@ -8,101 +8,276 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/alopt/go-admin-core/logger"
 	"github.com/alopt/go-admin-core/storage"
 	json "github.com/json-iterator/go"
 	"github.com/nsqio/go-nsq"
 )
 
-// NewNSQ nsq模式 只能监听一个channel
-func NewNSQ(addresses []string, cfg *nsq.Config, channelPrefix string) (*NSQ, error) {
+// NSQOption nsq队列参数设置类型
+type NSQOption func(*NSQ)
+
+// WithLookupdAddresses 设置nsqlookupd地址, 设置后消费者通过lookupd发现nsqd
+func WithLookupdAddresses(addresses ...string) NSQOption {
+	return func(e *NSQ) {
+		e.lookupdAddresses = addresses
+	}
+}
+
+// NewNSQ nsq模式, 每个topic一个消费者, 生产者在所有nsqd之间故障转移;
+// cfg.MaxAttempts固定为0, 重试次数与死信由RetryPolicy决定
+func NewNSQ(addresses []string, cfg *nsq.Config, channelPrefix string, opts ...NSQOption) (*NSQ, error) {
 	n := &NSQ{
 		addresses:     addresses,
 		cfg:           cfg,
 		channelPrefix: channelPrefix,
+		consumers:     make(map[string]*nsq.Consumer),
+		stop:          make(chan struct{}),
 	}
-	var err error
-	n.producer, err = n.newProducer()
-	return n, err
+	for _, o := range opts {
+		o(n)
+	}
+	if n.cfg == nil {
+		n.cfg = nsq.NewConfig()
+	}
+	// nsq默认超过5次投递直接丢弃消息, 不经过handler也不进入死信队列
+	n.cfg.MaxAttempts = 0
+	if len(n.addresses) == 0 && len(n.lookupdAddresses) == 0 {
+		return nil, errors.New("queue: nsqd or nsqlookupd addresses required")
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	for _, address := range n.addresses {
+		producer, err := nsq.NewProducer(address, n.cfg)
+		if err != nil {
+			return nil, err
+		}
+		n.producers = append(n.producers, producer)
+	}
+	return n, nil
 }
 
 type NSQ struct {
-	addresses     []string
-	cfg           *nsq.Config
-	producer      *nsq.Producer
-	consumer      *nsq.Consumer
-	channelPrefix string
+	addresses        []string
+	lookupdAddresses []string
+	cfg              *nsq.Config
+	producers        []*nsq.Producer
+	current          int32
+	consumers        map[string]*nsq.Consumer
+	channelPrefix    string
+	ctx              context.Context
+	cancel           context.CancelFunc
+	mux              sync.Mutex
+	stop             chan struct{}
+	once             sync.Once
 }
 
 // String 字符串类型
-func (NSQ) String() string {
+func (*NSQ) String() string {
 	return "nsq"
 }
 
-// switchAddress ⚠️生产环境至少配置三个节点
-func (e *NSQ) switchAddress() {
-	if len(e.addresses) > 1 {
-		e.addresses[0], e.addresses[len(e.addresses)-1] =
-			e.addresses[1],
-			e.addresses[0]
+// publish 从上次成功的nsqd开始依次尝试, 全部失败时返回最后一个错误
+// ⚠️生产环境至少配置三个节点
+func (e *NSQ) publish(f func(producer *nsq.Producer) error) error {
+	n := len(e.producers)
+	if n == 0 {
+		return errors.New("queue: no nsqd address for producer")
 	}
-}
-
-func (e *NSQ) newProducer() (*nsq.Producer, error) {
-	if e.cfg == nil {
-		e.cfg = nsq.NewConfig()
+	start := int(atomic.LoadInt32(&e.current))
+	var err error
+	for i := 0; i < n; i++ {
+		index := (start + i) % n
+		if err = f(e.producers[index]); err == nil {
+			if i > 0 {
+				atomic.StoreInt32(&e.current, int32(index))
+			}
+			return nil
+		}
+		log.Warnf("queue: publish to nsqd %s error, %s", e.producers[index].String(), err.Error())
 	}
-	return nsq.NewProducer(e.addresses[0], e.cfg)
+	return err
 }
 
-func (e *NSQ) newConsumer(topic string, h nsq.Handler) (err error) {
-	if e.cfg == nil {
-		e.cfg = nsq.NewConfig()
+// newConsumer 创建topic的消费者, channel为channelPrefix+topic
+func (e *NSQ) newConsumer(topic string, h nsq.Handler, concurrency int) (*nsq.Consumer, error) {
+	consumer, err := nsq.NewConsumer(topic, e.channelPrefix+topic, e.cfg)
+	if err != nil {
+		return nil, err
 	}
-	if e.consumer == nil {
-		e.consumer, err = nsq.NewConsumer(topic, e.channelPrefix+topic, e.cfg)
-		if err != nil {
-			return err
+	if concurrency > 1 {
+		if concurrency > e.cfg.MaxInFlight {
+			consumer.ChangeMaxInFlight(concurrency)
 		}
+		consumer.AddConcurrentHandlers(h, concurrency)
+	} else {
+		consumer.AddHandler(h)
 	}
-	e.consumer.AddHandler(h)
-	err = e.consumer.ConnectToNSQDs(e.addresses)
+	return consumer, nil
+}
 
-	return err
+// connect 连接lookupd或nsqd, 连接失败的nsqd在后台重试直到成功或关闭
+func (e *NSQ) connect(topic string, consumer *nsq.Consumer) {
+	if len(e.lookupdAddresses) > 0 {
+		if err := consumer.ConnectToNSQLookupds(e.lookupdAddresses); err != nil {
+			log.Errorf("queue: consumer of %s connect to nsqlookupd error, %s", topic, err.Error())
+		}
+		return
+	}
+	for _, address := range e.addresses {
+		go func(address string) {
+			interval := e.cfg.LookupdPollInterval
+			if interval <= 0 {
+				interval = time.Minute
+			}
+			for {
+				err := consumer.ConnectToNSQD(address)
+				if err == nil || err == nsq.ErrAlreadyConnected {
+					return
+				}
+				log.Errorf("queue: consumer of %s connect to nsqd %s error, %s", topic, address, err.Error())
+				select {
+				case <-e.stop:
+					return
+				case <-time.After(interval):
+				}
+			}
+		}(address)
+	}
 }
 
 // Append 消息入生产者
 func (e *NSQ) Append(message storage.Messager) error {
-	rb, err := json.Marshal(message.GetValues())
+	rb, err := encodeBody(message)
+	if err != nil {
+		return err
+	}
+	return e.publish(func(producer *nsq.Producer) error {
+		return producer.Publish(message.GetStream(), rb)
+	})
+}
+
+// AppendDelay 延迟投递, 由nsqd保存延迟消息, delay不能超过nsqd的--max-req-timeout(默认1h)
+func (e *NSQ) AppendDelay(message storage.Messager, delay time.Duration) error {
+	if delay <= 0 {
+		return e.Append(message)
+	}
+	rb, err := encodeBody(message)
 	if err != nil {
 		return err
 	}
-	return e.producer.Publish(message.GetStream(), rb)
+	return e.publish(func(producer *nsq.Producer) error {
+		return producer.DeferredPublish(message.GetStream(), delay, rb)
+	})
+}
+
+// encodeBody 消息体为values的json, 消息头编码为其中一个字段
+func encodeBody(message storage.Messager) ([]byte, error) {
+	values, err := encodeValues(message.GetValues(), published(message))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(values)
+}
+
+// AppendAt 定时投递
+func (e *NSQ) AppendAt(message storage.Messager, at time.Time) error {
+	return e.AppendDelay(message, time.Until(at))
 }
 
 // Register 监听消费者
-func (e *NSQ) Register(name string, f storage.ConsumerFunc) {
-	h := &nsqConsumerHandler{f}
-	err := e.newConsumer(name, h)
+func (e *NSQ) Register(name string, f storage.ConsumerFunc, opts ...storage.RegisterOption) {
+	e.RegisterCtx(name, func(_ context.Context, message storage.Messager) error {
+		return f(message)
+	}, opts...)
+}
+
+// RegisterCtx 监听消费者, 可在运行中注册, 每个topic使用独立的消费者;
+// 失败的消息由nsqd按策略延迟重新投递, 重试次数用完后发布到 <stream>.dlq topic,
+// 可注册该topic的消费者查看或重放
+func (e *NSQ) RegisterCtx(name string, f storage.ConsumerCtxFunc, opts ...storage.RegisterOption) {
+	o := storage.NewRegisterOptions(opts...)
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	select {
+	case <-e.stop:
+		log.Errorf("queue: register %s after shutdown", name)
+		return
+	default:
+	}
+	if _, ok := e.consumers[name]; ok {
+		log.Errorf("queue: topic %s already registered", name)
+		return
+	}
+	h := &nsqConsumerHandler{
+		ctx:   e.ctx,
+		topic: name,
+		f:     f,
+		retry: o.Retry,
+		dead:  e.Append,
+	}
+	consumer, err := e.newConsumer(name, h, o.Concurrency)
 	if err != nil {
-		//目前不支持动态注册
-		panic(err)
+		log.Errorf("queue: register %s error, %s", name, err.Error())
+		return
 	}
+	e.consumers[name] = consumer
+	e.connect(name, consumer)
 }
 
+// Run 阻塞直到队列关闭, 消费者在注册时已经开始运行
 func (e *NSQ) Run() {
+	<-e.stop
 }
 
 func (e *NSQ) Shutdown() {
-	if e.producer != nil {
-		e.producer.Stop()
-	}
-	if e.consumer != nil {
-		e.consumer.Stop()
-	}
+	_ = e.ShutdownCtx(context.Background())
+}
+
+// ShutdownCtx 停止所有消费者并等待处理中的消息完成后关闭生产者
+func (e *NSQ) ShutdownCtx(ctx context.Context) error {
+	var err error
+	e.once.Do(func() {
+		e.mux.Lock()
+		close(e.stop)
+		consumers := make([]*nsq.Consumer, 0, len(e.consumers))
+		for _, consumer := range e.consumers {
+			consumers = append(consumers, consumer)
+		}
+		e.mux.Unlock()
+		for _, consumer := range consumers {
+			consumer.Stop()
+		}
+		for _, consumer := range consumers {
+			select {
+			case <-consumer.StopChan:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			if err != nil {
+				break
+			}
+		}
+		e.cancel()
+		for _, producer := range e.producers {
+			producer.Stop()
+		}
+	})
+	return err
 }
 
 type nsqConsumerHandler struct {
-	f storage.ConsumerFunc
+	ctx   context.Context
+	topic string
+	f     storage.ConsumerCtxFunc
+	retry storage.RetryPolicy
+	dead  func(storage.Messager) error
 }
 
 func (e nsqConsumerHandler) HandleMessage(message *nsq.Message) error {
@@ -112,6 +287,28 @@ func (e nsqConsumerHandler) HandleMessage(message *nsq.Message) error {
 	if err != nil {
 		return err
 	}
-	m.SetValues(data)
-	return e.f(m)
+	values, headers := decodeValues(data)
+	m.SetID(string(message.ID[:]))
+	m.SetStream(e.topic)
+	m.SetValues(values)
+	m.SetHeaders(headers)
+	// 失败次数以nsq记录的投递次数为准
+	if attempt := int(message.Attempts) - 1; attempt > 0 {
+		m.SetHeader(storage.HeaderAttempt, strconv.Itoa(attempt))
+	}
+	m.SetErrorCount(int(message.Attempts) - 1)
+	err = e.f(e.ctx, m)
+	if err == nil {
+		return nil
+	}
+	attempt := int(message.Attempts)
+	if !e.retry.Exhausted(attempt) {
+		message.RequeueWithoutBackoff(e.retry.Backoff(attempt))
+		return nil
+	}
+	log.Warnf("queue: message of %s failed %d times, move to dead letter, %s", e.topic, attempt, err.Error())
+	dead := failed(m, err)
+	dead.SetStream(storage.DeadLetterStream(e.topic))
+	// 发布失败时返回错误由nsq重新投递
+	return e.dead(dead)
 }
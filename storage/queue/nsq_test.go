@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/nsqio/go-nsq"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+func TestNewNSQ(t *testing.T) {
+	if _, err := NewNSQ(nil, nil, ""); err == nil {
+		t.Fatal("NewNSQ() without addresses should return error")
+	}
+	e, err := NewNSQ(nil, nil, "", WithLookupdAddresses("127.0.0.1:4161"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Append(&Message{}); err == nil {
+		t.Fatal("Append() without nsqd should return error")
+	}
+	for _, cfg := range []*nsq.Config{nil, nsq.NewConfig()} {
+		e, err = NewNSQ([]string{"127.0.0.1:4150"}, cfg, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.cfg.MaxAttempts != 0 {
+			t.Errorf("MaxAttempts = %d, want 0 so RetryPolicy decides", e.cfg.MaxAttempts)
+		}
+	}
+}
+
+func TestNSQ_publish(t *testing.T) {
+	e, err := NewNSQ([]string{"127.0.0.1:4150", "127.0.0.1:4250", "127.0.0.1:4350"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tried []string
+	err = e.publish(func(producer *nsq.Producer) error {
+		tried = append(tried, producer.String())
+		if producer.String() != "127.0.0.1:4350" {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if err != nil || len(tried) != 3 {
+		t.Fatalf("publish() = %v, tried %v", err, tried)
+	}
+	// 下一次直接从可用节点开始
+	tried = tried[:0]
+	_ = e.publish(func(producer *nsq.Producer) error {
+		tried = append(tried, producer.String())
+		return nil
+	})
+	if len(tried) != 1 || tried[0] != "127.0.0.1:4350" {
+		t.Fatalf("tried %v, want 127.0.0.1:4350 first", tried)
+	}
+}
+
+func TestNSQ_Register(t *testing.T) {
+	cfg := nsq.NewConfig()
+	cfg.LookupdPollInterval = 50 * time.Millisecond
+	e, err := NewNSQ([]string{"127.0.0.1:1"}, cfg, "test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := func(message storage.Messager) error {
+		return nil
+	}
+	// nsqd不可用时不会panic, 在后台重试连接
+	e.Register("topic1", f)
+	e.Register("topic2", f, storage.WithConcurrency(4))
+	e.Register("topic1", f)
+	if len(e.consumers) != 2 {
+		t.Fatalf("consumers = %d, want 2", len(e.consumers))
+	}
+	go e.Run()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = e.ShutdownCtx(ctx); err != nil {
+		t.Fatal(err)
+	}
+	e.Register("topic3", f)
+	if len(e.consumers) != 2 {
+		t.Fatal("register after shutdown should be ignored")
+	}
+}
+
+func TestNSQ_encodeBody(t *testing.T) {
+	m := new(Message)
+	m.SetValues(map[string]interface{}{"key": "value"})
+	m.SetPrefix("tenant")
+	rb, err := encodeBody(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make(map[string]interface{})
+	if err = json.Unmarshal(rb, &data); err != nil {
+		t.Fatal(err)
+	}
+	values, headers := decodeValues(data)
+	if len(values) != 1 || values["key"] != "value" ||
+		headers[storage.HeaderTenant] != "tenant" || headers[storage.HeaderPublishTime] == "" {
+		t.Fatalf("values = %v, headers = %v", values, headers)
+	}
+}
@@ -0,0 +1,46 @@
+package queue
+
+import "time"
+
+const (
+	// DefaultDelayedKey redis延迟消息sorted set的key
+	DefaultDelayedKey = "go-admin:queue:delayed"
+)
+
+// Option redis队列参数设置类型
+type Option func(*options)
+
+type options struct {
+	delayedKey   string
+	pollInterval time.Duration
+	pollBatch    int64
+}
+
+func setDefaultOptions() options {
+	return options{
+		delayedKey:   DefaultDelayedKey,
+		pollInterval: time.Second,
+		pollBatch:    100,
+	}
+}
+
+// WithDelayedKey 设置延迟消息sorted set的key, 共用同一redis的多个应用需要区分
+func WithDelayedKey(key string) Option {
+	return func(o *options) {
+		o.delayedKey = key
+	}
+}
+
+// WithPollInterval 设置延迟消息的扫描间隔, 决定投递精度
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = d
+	}
+}
+
+// WithPollBatch 设置每次扫描最多投递的延迟消息数
+func WithPollBatch(n int64) Option {
+	return func(o *options) {
+		o.pollBatch = n
+	}
+}
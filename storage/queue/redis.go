@@ -1,45 +1,133 @@
 package queue
 
 import (
-	"github.com/alopt/go-admin-core/storage"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
 	"github.com/alopt/redisqueue/v2"
+	"github.com/google/uuid"
+	json "github.com/json-iterator/go"
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cast"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/storage"
 )
 
+// moveDelayed 将到期的延迟消息原子地从sorted set移入对应stream
+var moveDelayed = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, raw in ipairs(items) do
+	local m = cjson.decode(raw)
+	local args = {'XADD', m.stream}
+	if tonumber(ARGV[3]) > 0 then
+		table.insert(args, 'MAXLEN')
+		if ARGV[4] == '1' then
+			table.insert(args, '~')
+		end
+		table.insert(args, ARGV[3])
+	end
+	table.insert(args, '*')
+	for k, v in pairs(m.values) do
+		table.insert(args, k)
+		table.insert(args, v)
+	end
+	redis.call(unpack(args))
+	redis.call('ZREM', KEYS[1], raw)
+end
+return #items
+`)
+
+// delayedMessage 保存在sorted set中的延迟消息, id保证相同内容的消息不会被合并
+type delayedMessage struct {
+	ID     string            `json:"id"`
+	Stream string            `json:"stream"`
+	Values map[string]string `json:"values"`
+}
+
 // NewRedis redis模式
 func NewRedis(
 	producerOptions *redisqueue.ProducerOptions,
 	consumerOptions *redisqueue.ConsumerOptions,
+	opts ...Option,
 ) (*Redis, error) {
 	var err error
-	r := &Redis{}
-	r.producer, err = r.newProducer(producerOptions)
-	if err != nil {
-		return nil, err
+	r := &Redis{
+		opts:      setDefaultOptions(),
+		consumers: make(map[string]*redisqueue.Consumer),
+		stop:      make(chan struct{}),
 	}
-	r.consumer, err = r.newConsumer(consumerOptions)
+	for _, o := range opts {
+		o(&r.opts)
+	}
+	if producerOptions == nil {
+		producerOptions = &redisqueue.ProducerOptions{}
+	}
+	if producerOptions.RedisClient == nil {
+		producerOptions.RedisClient = newClient(producerOptions.RedisOptions)
+	}
+	if consumerOptions == nil {
+		consumerOptions = &redisqueue.ConsumerOptions{}
+	}
+	if consumerOptions.RedisClient == nil {
+		consumerOptions.RedisClient = newClient(consumerOptions.RedisOptions)
+	}
+	r.client = producerOptions.RedisClient
+	r.maxLen = producerOptions.StreamMaxLength
+	r.approximate = producerOptions.ApproximateMaxLength
+	r.consumerOptions = *consumerOptions
+	r.producer, err = r.newProducer(producerOptions)
 	if err != nil {
 		return nil, err
 	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	go r.poll()
 	return r, nil
 }
 
-// Redis cache implement
+// Redis cache implement, 每个stream使用独立的redisqueue.Consumer, 互不阻塞
 type Redis struct {
-	client   *redis.Client
-	consumer *redisqueue.Consumer
-	producer *redisqueue.Producer
+	client          redis.UniversalClient
+	consumerOptions redisqueue.ConsumerOptions
+	consumers       map[string]*redisqueue.Consumer
+	producer        *redisqueue.Producer
+	opts            options
+	maxLen          int64
+	approximate     bool
+	ctx             context.Context
+	cancel          context.CancelFunc
+	running         bool
+	wait            sync.WaitGroup
+	mux             sync.Mutex
+	stop            chan struct{}
+	once            sync.Once
+}
+
+func newClient(options *redisqueue.RedisOptions) redis.UniversalClient {
+	if options == nil {
+		options = &redisqueue.RedisOptions{}
+	}
+	return redis.NewClient(options)
 }
 
-func (Redis) String() string {
+func (*Redis) String() string {
 	return "redis"
 }
 
-func (r *Redis) newConsumer(options *redisqueue.ConsumerOptions) (*redisqueue.Consumer, error) {
-	if options == nil {
-		options = &redisqueue.ConsumerOptions{}
+func (r *Redis) newConsumer(concurrency int) (*redisqueue.Consumer, error) {
+	options := r.consumerOptions
+	if concurrency > 0 {
+		options.Concurrency = concurrency
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = options.Concurrency
 	}
-	return redisqueue.NewConsumerWithOptions(options)
+	return redisqueue.NewConsumerWithOptions(&options)
 }
 
 func (r *Redis) newProducer(options *redisqueue.ProducerOptions) (*redisqueue.Producer, error) {
@@ -49,29 +137,240 @@ func (r *Redis) newProducer(options *redisqueue.ProducerOptions) (*redisqueue.Pr
 	return redisqueue.NewProducerWithOptions(options)
 }
 
+// Append 消息头编码为一个字段和values一起写入stream
 func (r *Redis) Append(message storage.Messager) error {
-	err := r.producer.Enqueue(&redisqueue.Message{
+	values, err := encodeValues(message.GetValues(), published(message))
+	if err != nil {
+		return err
+	}
+	err = r.producer.Enqueue(&redisqueue.Message{
 		ID:     message.GetID(),
 		Stream: message.GetStream(),
-		Values: message.GetValues(),
+		Values: values,
 	})
 	return err
 }
 
-func (r *Redis) Register(name string, f storage.ConsumerFunc) {
-	r.consumer.Register(name, func(message *redisqueue.Message) error {
+// AppendDelay 延迟投递
+func (r *Redis) AppendDelay(message storage.Messager, delay time.Duration) error {
+	return r.AppendAt(message, time.Now().Add(delay))
+}
+
+// AppendAt 定时投递, 消息先保存在sorted set中, 到期后由扫描协程写入stream
+func (r *Redis) AppendAt(message storage.Messager, at time.Time) error {
+	if !at.After(time.Now()) {
+		return r.Append(message)
+	}
+	if len(message.GetValues()) == 0 {
+		return errors.New("queue: message values is empty")
+	}
+	encoded, err := encodeValues(message.GetValues(), published(message))
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string, len(encoded))
+	for k, v := range encoded {
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return err
+		}
+		values[k] = s
+	}
+	rb, err := json.Marshal(&delayedMessage{
+		ID:     uuid.New().String(),
+		Stream: message.GetStream(),
+		Values: values,
+	})
+	if err != nil {
+		return err
+	}
+	return r.client.ZAdd(context.TODO(), r.opts.delayedKey, redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: string(rb),
+	}).Err()
+}
+
+// poll 定时扫描到期的延迟消息, 多个实例同时扫描也不会重复投递
+func (r *Redis) poll() {
+	ticker := time.NewTicker(r.opts.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		for {
+			n, err := r.moveDelayed(context.TODO())
+			if err != nil {
+				log.Errorf("queue: move delayed messages error, %s", err.Error())
+			}
+			if err != nil || n < r.opts.pollBatch {
+				break
+			}
+		}
+	}
+}
+
+func (r *Redis) moveDelayed(ctx context.Context) (int64, error) {
+	approximate := "0"
+	if r.approximate {
+		approximate = "1"
+	}
+	return moveDelayed.Run(ctx, r.client,
+		[]string{r.opts.delayedKey},
+		time.Now().UnixMilli(),
+		r.opts.pollBatch,
+		r.maxLen,
+		approximate,
+	).Int64()
+}
+
+func (r *Redis) Register(name string, f storage.ConsumerFunc, opts ...storage.RegisterOption) {
+	r.RegisterCtx(name, func(_ context.Context, message storage.Messager) error {
+		return f(message)
+	}, opts...)
+}
+
+// RegisterCtx 注册消费者, 运行中注册的stream立即开始消费;
+// 失败的消息确认后按策略重新投递, 重新投递失败时保持pending由redisqueue回收
+func (r *Redis) RegisterCtx(name string, f storage.ConsumerCtxFunc, opts ...storage.RegisterOption) {
+	o := storage.NewRegisterOptions(opts...)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	select {
+	case <-r.stop:
+		log.Errorf("queue: register %s after shutdown", name)
+		return
+	default:
+	}
+	if _, ok := r.consumers[name]; ok {
+		log.Errorf("queue: stream %s already registered", name)
+		return
+	}
+	consumer, err := r.newConsumer(o.Concurrency)
+	if err != nil {
+		log.Errorf("queue: register %s error, %s", name, err.Error())
+		return
+	}
+	consumer.Register(name, func(message *redisqueue.Message) error {
+		values, headers := decodeValues(message.Values)
 		m := new(Message)
-		m.SetValues(message.Values)
+		m.SetValues(values)
+		m.SetHeaders(headers)
 		m.SetStream(message.Stream)
 		m.SetID(message.ID)
-		return f(m)
+		m.SetErrorCount(attempts(headers))
+		err := f(r.ctx, m)
+		if err == nil {
+			return nil
+		}
+		return retry(r, m, o.Retry, err)
 	})
+	go logErrors(consumer)
+	r.consumers[name] = consumer
+	if r.running {
+		r.run(consumer)
+	}
 }
 
+func (r *Redis) deadLetter(message storage.Messager) error {
+	return r.Append(message)
+}
+
+// DeadLetters 查看最多limit条死信, limit<=0返回全部
+func (r *Redis) DeadLetters(stream string, limit int) ([]storage.Messager, error) {
+	list, err := r.rangeDeadLetters(stream, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]storage.Messager, 0, len(list))
+	for i := range list {
+		values, headers := decodeValues(list[i].Values)
+		m := new(Message)
+		m.SetID(list[i].ID)
+		m.SetStream(storage.DeadLetterStream(stream))
+		m.SetValues(values)
+		m.SetHeaders(headers)
+		m.SetErrorCount(attempts(headers))
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// Replay 将最多limit条死信重新投递到原stream并从死信stream删除, limit<=0重放全部
+func (r *Redis) Replay(stream string, limit int) (int, error) {
+	list, err := r.rangeDeadLetters(stream, limit)
+	if err != nil {
+		return 0, err
+	}
+	dlq := storage.DeadLetterStream(stream)
+	for i := range list {
+		values, headers := decodeValues(list[i].Values)
+		m := new(Message)
+		m.SetValues(values)
+		m.SetHeaders(headers)
+		if err = r.Append(revive(m, stream)); err != nil {
+			return i, err
+		}
+		if err = r.client.XDel(context.TODO(), dlq, list[i].ID).Err(); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(list), nil
+}
+
+func (r *Redis) rangeDeadLetters(stream string, limit int) ([]redis.XMessage, error) {
+	dlq := storage.DeadLetterStream(stream)
+	if limit > 0 {
+		return r.client.XRangeN(context.TODO(), dlq, "-", "+", int64(limit)).Result()
+	}
+	return r.client.XRange(context.TODO(), dlq, "-", "+").Result()
+}
+
+// logErrors redisqueue的Errors是无缓冲channel, 必须持续读取否则消费协程会阻塞
+func logErrors(consumer *redisqueue.Consumer) {
+	for err := range consumer.Errors {
+		log.Errorf("queue: redis consumer error, %s", err.Error())
+	}
+}
+
+func (r *Redis) run(consumer *redisqueue.Consumer) {
+	r.wait.Add(1)
+	go func() {
+		defer r.wait.Done()
+		consumer.Run()
+	}()
+}
+
+// Run 启动所有已注册的消费者, 阻塞直到队列关闭
 func (r *Redis) Run() {
-	r.consumer.Run()
+	r.mux.Lock()
+	if !r.running {
+		r.running = true
+		for _, consumer := range r.consumers {
+			r.run(consumer)
+		}
+	}
+	r.mux.Unlock()
+	<-r.stop
+	r.wait.Wait()
 }
 
 func (r *Redis) Shutdown() {
-	r.consumer.Shutdown()
+	_ = r.ShutdownCtx(context.Background())
+}
+
+// ShutdownCtx 停止拉取消息并等待处理中的消息完成, 已拉取未处理的消息保持pending, 超时后由其他实例回收
+func (r *Redis) ShutdownCtx(ctx context.Context) error {
+	r.once.Do(func() {
+		r.mux.Lock()
+		close(r.stop)
+		for _, consumer := range r.consumers {
+			// redisqueue收到SIGTERM时会自行Shutdown, 重复调用可能阻塞
+			go consumer.Shutdown()
+		}
+		r.mux.Unlock()
+	})
+	return drain(ctx, &r.wait, r.cancel)
 }
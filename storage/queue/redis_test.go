@@ -1,13 +1,15 @@
 package queue
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/alopt/redisqueue/v2"
-	"github.com/go-redis/redis/v9"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/alopt/go-admin-core/storage"
 )
@@ -25,7 +27,7 @@ func TestRedis_Append(t *testing.T) {
 		name    string
 		message storage.Messager
 	}
-	client := redis.NewClient(&redis.Options{})
+	_, client := newTestClient(t)
 	tests := []struct {
 		name    string
 		fields  fields
@@ -52,13 +54,13 @@ func TestRedis_Append(t *testing.T) {
 			},
 			args{
 				name: "test",
-				message: &Message{redisqueue.Message{
+				message: &Message{Message: redisqueue.Message{
 					ID:     "",
 					Stream: "test",
 					Values: map[string]interface{}{
 						"key": "value",
 					},
-				}, 3, sync.RWMutex{}},
+				}, ErrorCount: 3},
 			},
 			false,
 		},
@@ -91,7 +93,7 @@ func TestRedis_Register(t *testing.T) {
 		name string
 		f    storage.ConsumerFunc
 	}
-	client := redis.NewClient(&redis.Options{})
+	_, client := newTestClient(t)
 	tests := []struct {
 		name   string
 		fields fields
@@ -131,9 +133,217 @@ func TestRedis_Register(t *testing.T) {
 				t.Errorf("SetQueue() error = %v", err)
 			} else {
 				r.Register(tt.args.name, tt.args.f)
-				r.Run()
+				go r.Run()
+				time.Sleep(time.Second)
+				r.Shutdown()
 			}
 		})
 	}
 	t.Log("ok")
 }
+
+// serverInfo miniredis不支持INFO server, redisqueue启动时需要检查版本
+type serverInfo struct{}
+
+func (serverInfo) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (serverInfo) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "info" {
+			cmd.(*redis.StringCmd).SetVal("# Server\r\nredis_version:7.0.0\r\n")
+			return nil
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (serverInfo) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	client.AddHook(serverInfo{})
+	return s, client
+}
+
+func TestRedis_AppendDelay(t *testing.T) {
+	s, client := newTestClient(t)
+	r, err := NewRedis(
+		&redisqueue.ProducerOptions{RedisClient: client},
+		&redisqueue.ConsumerOptions{RedisClient: client},
+		WithPollInterval(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Shutdown()
+
+	message := &Message{Message: redisqueue.Message{
+		Stream: "delay",
+		Values: map[string]interface{}{"key": "value", "n": 1},
+	}}
+	if err = r.AppendDelay(message, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// 相同内容的消息不能被sorted set合并
+	if err = r.AppendDelay(message, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if members, _ := s.ZMembers(DefaultDelayedKey); len(members) != 2 {
+		t.Fatalf("delayed = %d, want 2", len(members))
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n, _ := client.XLen(context.TODO(), "delay").Result(); n != 0 {
+		t.Fatalf("delivered too early")
+	}
+	time.Sleep(500 * time.Millisecond)
+	list, err := client.XRange(context.TODO(), "delay", "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("stream length = %d, want 2", len(list))
+	}
+	if list[0].Values["key"] != "value" || list[0].Values["n"] != "1" {
+		t.Fatalf("values = %v", list[0].Values)
+	}
+	if s.Exists(DefaultDelayedKey) {
+		t.Fatalf("delayed messages not removed")
+	}
+}
+
+func TestRedis_DeadLetter(t *testing.T) {
+	_, client := newTestClient(t)
+	r, err := NewRedis(
+		&redisqueue.ProducerOptions{RedisClient: client},
+		&redisqueue.ConsumerOptions{
+			RedisClient:       client,
+			BlockingTimeout:   100 * time.Millisecond,
+			VisibilityTimeout: time.Minute,
+			ReclaimInterval:   time.Second,
+			BufferSize:        10,
+			Concurrency:       1,
+		},
+		WithPollInterval(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	var mux sync.Mutex
+	r.Register("retry", func(message storage.Messager) error {
+		mux.Lock()
+		defer mux.Unlock()
+		calls++
+		return fmt.Errorf("fail %d", message.GetErrorCount())
+	}, storage.WithRetry(storage.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+	}))
+	go r.Run()
+	defer r.Shutdown()
+
+	if err = r.Append(&Message{Message: redisqueue.Message{
+		Stream: "retry",
+		Values: map[string]interface{}{"key": "value"},
+	}, Headers: map[string]string{storage.HeaderTenant: "tenant"}}); err != nil {
+		t.Fatal(err)
+	}
+	var list []storage.Messager
+	for i := 0; i < 100 && len(list) == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		list, _ = r.DeadLetters("retry", 10)
+	}
+	if len(list) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(list))
+	}
+	mux.Lock()
+	if calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+	mux.Unlock()
+	if list[0].GetErrorCount() != 3 || list[0].GetHeader(storage.HeaderError) != "fail 2" {
+		t.Fatalf("dead letter = %d %v", list[0].GetErrorCount(), list[0].GetValues())
+	}
+	// 消息头经过延迟投递和死信后保留, 不会混入values
+	if list[0].GetPrefix() != "tenant" || list[0].GetHeader(storage.HeaderPublishTime) == "" ||
+		len(list[0].GetValues()) != 1 {
+		t.Fatalf("dead letter headers = %v, values = %v", list[0].GetHeaders(), list[0].GetValues())
+	}
+
+	if n, err := r.Replay("retry", 10); err != nil || n != 1 {
+		t.Fatalf("Replay() = %d, %v", n, err)
+	}
+	if n, _ := client.XLen(context.TODO(), "retry.dlq").Result(); n != 0 {
+		t.Fatalf("dead letter stream length = %d after replay", n)
+	}
+}
+
+func TestRedis_ShutdownCtx(t *testing.T) {
+	_, client := newTestClient(t)
+	r, err := NewRedis(
+		&redisqueue.ProducerOptions{RedisClient: client},
+		&redisqueue.ConsumerOptions{
+			RedisClient:     client,
+			BlockingTimeout: 100 * time.Millisecond,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	var mux sync.Mutex
+	running, finished := 0, 0
+	r.RegisterCtx("drain", func(ctx context.Context, message storage.Messager) error {
+		mux.Lock()
+		running++
+		mux.Unlock()
+		<-release
+		mux.Lock()
+		finished++
+		mux.Unlock()
+		return nil
+	}, storage.WithConcurrency(2))
+	go r.Run()
+	for i := 0; i < 2; i++ {
+		if err = r.Append(&Message{Message: redisqueue.Message{
+			Stream: "drain",
+			Values: map[string]interface{}{"i": i},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		n := running
+		mux.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mux.Lock()
+	if running != 2 {
+		t.Fatalf("running = %d, want 2", running)
+	}
+	mux.Unlock()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = r.ShutdownCtx(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if finished != 2 {
+		t.Fatalf("finished = %d, want 2", finished)
+	}
+}
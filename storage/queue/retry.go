@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"strconv"
+	"time"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// retrier 通过重新投递实现重试的队列
+type retrier interface {
+	AppendDelay(message storage.Messager, delay time.Duration) error
+	deadLetter(message storage.Messager) error
+}
+
+// retry 按策略延迟重新投递失败的消息, 不阻塞消费者; 重试次数用完后转入死信队列
+func retry(q retrier, message storage.Messager, policy storage.RetryPolicy, cause error) error {
+	next := failed(message, cause)
+	if policy.Exhausted(next.GetErrorCount()) {
+		log.Warnf("queue: message of %s failed %d times, move to dead letter, %s",
+			message.GetStream(), next.GetErrorCount(), cause.Error())
+		next.SetStream(storage.DeadLetterStream(message.GetStream()))
+		return q.deadLetter(next)
+	}
+	return q.AppendDelay(next, policy.Backoff(next.GetErrorCount()))
+}
+
+// failed 复制失败的消息, 失败次数和原因保存在消息头中以便跨进程传递
+func failed(message storage.Messager, cause error) *Message {
+	headers := cloneHeaders(message.GetHeaders())
+	if headers == nil {
+		headers = make(map[string]string, 2)
+	}
+	attempt := message.GetErrorCount() + 1
+	headers[storage.HeaderAttempt] = strconv.Itoa(attempt)
+	headers[storage.HeaderError] = cause.Error()
+	m := new(Message)
+	m.SetStream(message.GetStream())
+	m.SetValues(message.GetValues())
+	m.SetHeaders(headers)
+	m.SetErrorCount(attempt)
+	return m
+}
+
+// revive 将死信还原为原stream的消息, 失败次数清零, 其余消息头保留
+func revive(message storage.Messager, stream string) *Message {
+	headers := cloneHeaders(message.GetHeaders())
+	delete(headers, storage.HeaderAttempt)
+	delete(headers, storage.HeaderError)
+	m := new(Message)
+	m.SetStream(stream)
+	m.SetValues(message.GetValues())
+	m.SetHeaders(headers)
+	return m
+}
+
+// attempts 从消息头中读取已失败的次数
+func attempts(headers map[string]string) int {
+	n, _ := strconv.Atoi(headers[storage.HeaderAttempt])
+	return n
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/alopt/go-admin-core/logger"
+	"github.com/alopt/go-admin-core/server"
+	"github.com/alopt/go-admin-core/storage"
+)
+
+var _ server.Runnable = (*Runnable)(nil)
+
+// NewRunnable 将队列包装为server.Runnable, 随server.Manager启动,
+// 停止时最多等待timeout让处理中的消息完成, timeout<=0一直等待
+func NewRunnable(queue storage.AdapterQueue, timeout time.Duration) *Runnable {
+	return &Runnable{
+		queue:   queue,
+		timeout: timeout,
+	}
+}
+
+// Runnable 队列服务
+type Runnable struct {
+	queue   storage.AdapterQueue
+	timeout time.Duration
+	started bool
+	mux     sync.Mutex
+}
+
+// String 服务名称
+func (e *Runnable) String() string {
+	return "queue-" + e.queue.String()
+}
+
+// Start 运行队列直到ctx结束, 然后优雅关闭
+func (e *Runnable) Start(ctx context.Context) error {
+	e.mux.Lock()
+	if e.started {
+		e.mux.Unlock()
+		return errors.New("queue was started more than once. " +
+			"This is likely to be caused by being added to a manager multiple times")
+	}
+	e.started = true
+	e.mux.Unlock()
+
+	go e.queue.Run()
+	<-ctx.Done()
+
+	shutdownCtx := context.Background()
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, e.timeout)
+		defer cancel()
+	}
+	if err := e.queue.ShutdownCtx(shutdownCtx); err != nil {
+		log.Errorf("queue %s shutdown error, %s", e.queue.String(), err.Error())
+	}
+	return nil
+}
+
+// Attempt 判断是否可以启动
+func (e *Runnable) Attempt() bool {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return !e.started
+}
+
+// drain 等待处理中的消息完成, ctx到期后取消消费者的context并返回ctx.Err()
+func drain(ctx context.Context, wait *sync.WaitGroup, cancel context.CancelFunc) error {
+	done := make(chan struct{})
+	go func() {
+		wait.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		cancel()
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,463 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentExt = ".log"
+	offsetFile = "consumer.offset"
+	// recordHeader 每条记录的头: 4字节长度 + 4字节crc32
+	recordHeader = 8
+)
+
+var errCorrupt = errors.New("queue: corrupt record")
+
+// segmentLog 单个stream的分段日志, 文件名为该段第一条记录的offset
+type segmentLog struct {
+	dir        string
+	opts       fileOptions
+	mux        sync.Mutex
+	bases      []uint64
+	active     *os.File
+	activeSize int64
+	next       uint64
+	dirty      bool
+	notify     chan struct{}
+	commitMux  sync.Mutex
+}
+
+// openLog 打开日志目录, 最后一段中不完整或校验失败的记录会被截断
+func openLog(dir string, opts fileOptions) (*segmentLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	l := &segmentLog{
+		dir:    dir,
+		opts:   opts,
+		notify: make(chan struct{}, 1),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		l.bases = append(l.bases, base)
+	}
+	sort.Slice(l.bases, func(i, j int) bool { return l.bases[i] < l.bases[j] })
+	if len(l.bases) == 0 {
+		l.bases = append(l.bases, 0)
+	}
+	if err = l.recover(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *segmentLog) segmentPath(base uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// recover 扫描最后一段得到下一个offset, 并截断尾部损坏的记录
+func (l *segmentLog) recover() error {
+	base := l.bases[len(l.bases)-1]
+	file, err := os.OpenFile(l.segmentPath(base), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	var count uint64
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		n, err := skipRecord(reader)
+		if err != nil {
+			break
+		}
+		size += n
+		count++
+	}
+	if err = file.Truncate(size); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if _, err = file.Seek(size, io.SeekStart); err != nil {
+		_ = file.Close()
+		return err
+	}
+	l.active = file
+	l.activeSize = size
+	l.next = base + count
+	return nil
+}
+
+// append 写入一条记录并返回其offset
+func (l *segmentLog) append(payload []byte) (uint64, error) {
+	record := make([]byte, recordHeader+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeader:], payload)
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.active == nil {
+		return 0, errors.New("queue: log is closed")
+	}
+	if l.activeSize > 0 && l.activeSize+int64(len(record)) > l.opts.segmentSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := l.active.Write(record); err != nil {
+		return 0, err
+	}
+	if l.opts.sync == FileSyncAlways {
+		if err := l.active.Sync(); err != nil {
+			return 0, err
+		}
+	} else {
+		l.dirty = true
+	}
+	l.activeSize += int64(len(record))
+	offset := l.next
+	l.next++
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+	return offset, nil
+}
+
+// rotate 关闭当前段并新建一段, 旧段在关闭前总是落盘
+func (l *segmentLog) rotate() error {
+	if err := l.active.Sync(); err != nil {
+		return err
+	}
+	if err := l.active.Close(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.segmentPath(l.next), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	l.bases = append(l.bases, l.next)
+	l.active = file
+	l.activeSize = 0
+	l.dirty = false
+	return nil
+}
+
+// nextOffset 下一条写入记录的offset
+func (l *segmentLog) nextOffset() uint64 {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.next
+}
+
+// segmentOf 返回offset所在段的base
+func (l *segmentLog) segmentOf(offset uint64) uint64 {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	i := sort.Search(len(l.bases), func(i int) bool { return l.bases[i] > offset })
+	if i == 0 {
+		return l.bases[0]
+	}
+	return l.bases[i-1]
+}
+
+// sync 落盘未同步的写入, 用于FileSyncInterval
+func (l *segmentLog) sync() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if !l.dirty || l.active == nil {
+		return nil
+	}
+	l.dirty = false
+	return l.active.Sync()
+}
+
+// cleanup 删除所有记录都已提交的段, 当前写入段保留
+func (l *segmentLog) cleanup(committed uint64) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for len(l.bases) > 1 && l.bases[1] <= committed {
+		if err := os.Remove(l.segmentPath(l.bases[0])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		l.bases = l.bases[1:]
+	}
+	return nil
+}
+
+// readOffset 读取已提交的offset以及之后已完成的offset, 不存在时从最早的记录开始
+func (l *segmentLog) readOffset() (uint64, []uint64, error) {
+	rb, err := os.ReadFile(filepath.Join(l.dir, offsetFile))
+	if os.IsNotExist(err) {
+		return l.segmentOf(0), nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(rb)), "\n", 2)
+	offset, err := strconv.ParseUint(strings.TrimSpace(lines[0]), 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	if next := l.nextOffset(); offset > next {
+		offset = next
+	}
+	if first := l.segmentOf(0); offset < first {
+		offset = first
+	}
+	var done []uint64
+	if len(lines) > 1 {
+		for _, v := range strings.Split(strings.TrimSpace(lines[1]), ",") {
+			if n, err := strconv.ParseUint(v, 10, 64); err == nil && n > offset {
+				done = append(done, n)
+			}
+		}
+	}
+	return offset, done, nil
+}
+
+// commit 原子地保存已提交的offset与之后已完成的offset, 并清理已消费完的段
+func (l *segmentLog) commit(offset uint64, done []uint64) error {
+	content := strconv.FormatUint(offset, 10)
+	if len(done) > 0 {
+		list := make([]string, len(done))
+		for i := range done {
+			list[i] = strconv.FormatUint(done[i], 10)
+		}
+		content += "\n" + strings.Join(list, ",")
+	}
+	l.commitMux.Lock()
+	defer l.commitMux.Unlock()
+	path := filepath.Join(l.dir, offsetFile)
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.WriteString(content); err == nil && l.opts.sync == FileSyncAlways {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return l.cleanup(offset)
+}
+
+func (l *segmentLog) close() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.active == nil {
+		return nil
+	}
+	err := l.active.Sync()
+	if cerr := l.active.Close(); err == nil {
+		err = cerr
+	}
+	l.active = nil
+	return err
+}
+
+// logReader 从指定offset开始顺序读取记录
+type logReader struct {
+	log    *segmentLog
+	offset uint64
+	file   *os.File
+	reader *bufio.Reader
+}
+
+func newLogReader(l *segmentLog, offset uint64) *logReader {
+	return &logReader{log: l, offset: offset}
+}
+
+// open 打开offset所在的段并跳过之前的记录
+func (r *logReader) open() error {
+	r.close()
+	base := r.log.segmentOf(r.offset)
+	if r.offset < base {
+		// 已被清理的记录直接跳过
+		r.offset = base
+	}
+	file, err := os.Open(r.log.segmentPath(base))
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	for i := base; i < r.offset; i++ {
+		if err = discardRecord(reader); err != nil {
+			// 之前的记录长度已损坏, 无法定位到offset
+			_ = file.Close()
+			return errCorrupt
+		}
+	}
+	r.file = file
+	r.reader = reader
+	return nil
+}
+
+// read 读取下一条记录, 没有新记录时返回io.EOF;
+// 记录损坏时返回其offset与errCorrupt, 并跳到下一条记录
+func (r *logReader) read() ([]byte, uint64, error) {
+	if r.offset >= r.log.nextOffset() {
+		return nil, 0, io.EOF
+	}
+	payload, err := r.next()
+	offset := r.offset
+	if err == errCorrupt {
+		// 下次读取时重新打开并定位, 不依赖损坏记录的长度
+		r.close()
+		r.offset++
+		return nil, offset, errCorrupt
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	r.offset++
+	return payload, offset, nil
+}
+
+func (r *logReader) next() ([]byte, error) {
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return nil, err
+		}
+	}
+	payload, err := readRecord(r.reader)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// 当前段已写完, 记录在下一段中
+		if err = r.open(); err != nil {
+			return nil, err
+		}
+		payload, err = readRecord(r.reader)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// offset小于next时记录已完整写入, 读不完整说明记录已损坏
+			err = errCorrupt
+		}
+	}
+	return payload, err
+}
+
+func (r *logReader) close() {
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+		r.reader = nil
+	}
+}
+
+func readRecord(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, recordHeader)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errCorrupt
+	}
+	return payload, nil
+}
+
+// discardRecord 按记录头中的长度跳过一条记录, 不校验crc
+func discardRecord(reader *bufio.Reader) error {
+	header := make([]byte, recordHeader)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return err
+	}
+	n := int(binary.BigEndian.Uint32(header[0:4]))
+	discarded, err := reader.Discard(n)
+	if err == nil && discarded != n {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func skipRecord(reader *bufio.Reader) (int64, error) {
+	payload, err := readRecord(reader)
+	if err != nil {
+		return 0, err
+	}
+	return int64(recordHeader + len(payload)), nil
+}
+
+// tracker 记录乱序完成的offset, 连续完成的部分推进提交位置,
+// 其余已完成的offset一并保存, 重启后不会重复投递
+type tracker struct {
+	mux       sync.Mutex
+	committed uint64
+	done      map[uint64]struct{}
+}
+
+func newTracker(committed uint64, done []uint64) *tracker {
+	t := &tracker{
+		committed: committed,
+		done:      make(map[uint64]struct{}, len(done)),
+	}
+	for _, offset := range done {
+		t.done[offset] = struct{}{}
+	}
+	return t
+}
+
+// isDone offset是否已完成
+func (t *tracker) isDone(offset uint64) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if offset < t.committed {
+		return true
+	}
+	_, ok := t.done[offset]
+	return ok
+}
+
+// complete 标记offset已完成并通过commit保存
+func (t *tracker) complete(offset uint64, commit func(uint64, []uint64) error) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if offset < t.committed {
+		return nil
+	}
+	t.done[offset] = struct{}{}
+	for {
+		if _, ok := t.done[t.committed]; !ok {
+			break
+		}
+		delete(t.done, t.committed)
+		t.committed++
+	}
+	done := make([]uint64, 0, len(t.done))
+	for offset := range t.done {
+		done = append(done, offset)
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i] < done[j] })
+	return commit(t.committed, done)
+}
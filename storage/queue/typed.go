@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/spf13/cast"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// PayloadKey Publish编码后的消息体在values中的字段
+const PayloadKey = "payload"
+
+type publishOptions struct {
+	codec   Codec
+	headers map[string]string
+	delay   time.Duration
+}
+
+// PublishOption Publish参数设置类型
+type PublishOption func(*publishOptions)
+
+// WithCodec 设置消息体编码, 默认JSONCodec
+func WithCodec(codec Codec) PublishOption {
+	return func(o *publishOptions) {
+		o.codec = codec
+	}
+}
+
+// WithHeader 设置消息头
+func WithHeader(key, value string) PublishOption {
+	return func(o *publishOptions) {
+		o.headers[key] = value
+	}
+}
+
+// WithTraceID 设置链路追踪id
+func WithTraceID(traceID string) PublishOption {
+	return WithHeader(storage.HeaderTraceID, traceID)
+}
+
+// WithPublishDelay 延迟delay后投递
+func WithPublishDelay(delay time.Duration) PublishOption {
+	return func(o *publishOptions) {
+		o.delay = delay
+	}
+}
+
+// Publish 编码v后发布到stream, 编码的content-type写入消息头
+func Publish[T any](q storage.AdapterQueue, stream string, v T, opts ...PublishOption) error {
+	o := publishOptions{
+		codec:   JSONCodec,
+		headers: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	rb, err := o.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	contentType := o.codec.ContentType()
+	payload := string(rb)
+	if !textual(contentType) {
+		payload = base64.StdEncoding.EncodeToString(rb)
+	}
+	o.headers[storage.HeaderContentType] = contentType
+	m := new(Message)
+	m.SetStream(stream)
+	m.SetValues(map[string]interface{}{PayloadKey: payload})
+	m.SetHeaders(o.headers)
+	if o.delay > 0 {
+		return q.AppendDelay(m, o.delay)
+	}
+	return q.Append(m)
+}
+
+// Subscribe 注册stream的消费者, 按消息头的content-type将消息体解码为T,
+// 解码失败与处理失败一样按重试策略重试
+func Subscribe[T any](q storage.AdapterQueue, stream string,
+	f func(ctx context.Context, v T, message storage.Messager) error, opts ...storage.RegisterOption) {
+	q.RegisterCtx(stream, func(ctx context.Context, message storage.Messager) error {
+		v, err := Decode[T](message)
+		if err != nil {
+			return err
+		}
+		return f(ctx, v, message)
+	}, opts...)
+}
+
+// Decode 解码Publish发布的消息体, T为指针时自动分配
+func Decode[T any](message storage.Messager) (T, error) {
+	var v T
+	codec, err := CodecOf(message)
+	if err != nil {
+		return v, err
+	}
+	raw, ok := message.GetValues()[PayloadKey]
+	if !ok {
+		return v, errors.New("queue: message has no payload")
+	}
+	payload, err := cast.ToStringE(raw)
+	if err != nil {
+		return v, err
+	}
+	rb := []byte(payload)
+	if !textual(codec.ContentType()) {
+		if rb, err = base64.StdEncoding.DecodeString(payload); err != nil {
+			return v, err
+		}
+	}
+	var target interface{} = &v
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Ptr {
+		v = reflect.New(t.Elem()).Interface().(T)
+		target = v
+	}
+	err = codec.Unmarshal(rb, target)
+	return v, err
+}
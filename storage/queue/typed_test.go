@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+type order struct {
+	ID    int    `json:"id" msgpack:"id"`
+	Title string `json:"title" msgpack:"title"`
+}
+
+func TestPublish_Codec(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"json", JSONCodec},
+		{"msgpack", MsgpackCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewMemory(10)
+			defer q.Shutdown()
+			received := make(chan order, 1)
+			Subscribe(q, "order", func(ctx context.Context, v order, message storage.Messager) error {
+				if message.GetHeader(storage.HeaderContentType) != tt.codec.ContentType() ||
+					message.GetHeader(storage.HeaderTraceID) != "trace" ||
+					message.GetHeader(storage.HeaderPublishTime) == "" {
+					t.Errorf("headers = %v", message.GetHeaders())
+				}
+				received <- v
+				return nil
+			})
+			err := Publish(q, "order", order{ID: 1, Title: "go-admin"},
+				WithCodec(tt.codec), WithTraceID("trace"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case v := <-received:
+				if v.ID != 1 || v.Title != "go-admin" {
+					t.Fatalf("received %+v", v)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("message not received")
+			}
+		})
+	}
+}
+
+func TestPublish_Protobuf(t *testing.T) {
+	q := NewMemory(10)
+	defer q.Shutdown()
+	received := make(chan string, 1)
+	Subscribe(q, "proto", func(ctx context.Context, v *wrapperspb.StringValue, _ storage.Messager) error {
+		received <- v.GetValue()
+		return nil
+	})
+	if err := Publish(q, "proto", wrapperspb.String("go-admin"), WithCodec(ProtobufCodec)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-received:
+		if v != "go-admin" {
+			t.Fatalf("received %s", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+	if err := Publish(q, "proto", order{}, WithCodec(ProtobufCodec)); err == nil {
+		t.Fatal("Publish() of non proto.Message should fail")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	m := new(Message)
+	m.SetValues(map[string]interface{}{PayloadKey: `{"id":2}`})
+	if v, err := Decode[order](m); err != nil || v.ID != 2 {
+		t.Fatalf("Decode() = %+v, %v", v, err)
+	}
+	if v, err := Decode[*order](m); err != nil || v.ID != 2 {
+		t.Fatalf("Decode() = %+v, %v", v, err)
+	}
+	m.SetHeader(storage.HeaderContentType, "application/unknown")
+	if _, err := Decode[order](m); err == nil {
+		t.Fatal("Decode() with unknown content type should fail")
+	}
+}
+
+func TestMemory_Headers(t *testing.T) {
+	q := NewMemory(10)
+	defer q.Shutdown()
+	received := make(chan storage.Messager, 2)
+	q.Register("test", func(message storage.Messager) error {
+		received <- message
+		if message.GetErrorCount() == 0 {
+			return errors.New("fail")
+		}
+		return nil
+	}, storage.WithRetry(storage.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}))
+	m := new(Message)
+	m.SetStream("test")
+	m.SetValues(map[string]interface{}{"key": "value"})
+	m.SetPrefix("tenant")
+	if err := q.Append(m); err != nil {
+		t.Fatal(err)
+	}
+	var first, second storage.Messager
+	for _, p := range []*storage.Messager{&first, &second} {
+		select {
+		case *p = <-received:
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+	if second.GetPrefix() != "tenant" || second.GetHeader(storage.HeaderAttempt) != "1" ||
+		second.GetHeader(storage.HeaderPublishTime) != first.GetHeader(storage.HeaderPublishTime) {
+		t.Fatalf("headers = %v", second.GetHeaders())
+	}
+	if _, ok := second.GetValues()[storage.PrefixKey]; ok {
+		t.Fatal("prefix should not be in values")
+	}
+}
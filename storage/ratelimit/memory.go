@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// NewMemory 进程内的令牌桶, 每个实例单独计数
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Duration
+}
+
+type Memory struct {
+	buckets map[string]*bucket
+	calls   int
+	mux     sync.Mutex
+}
+
+func (*Memory) String() string {
+	return "memory"
+}
+
+// Allow 对key请求n次
+func (m *Memory) Allow(_ context.Context, key string, limit Limit, n int) (*Result, error) {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return nil, errors.New("ratelimit: invalid limit")
+	}
+	interval := limit.interval()
+	burst := float64(limit.Capacity())
+	now := time.Now()
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		m.buckets[key] = b
+	}
+	b.full = time.Duration(burst) * interval
+	// 按经过的时间补充令牌
+	b.tokens += float64(now.Sub(b.last)) / float64(interval)
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	result := &Result{Limit: limit}
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((float64(n) - b.tokens) * float64(interval))
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration((burst - b.tokens) * float64(interval))
+	return result, nil
+}
+
+// sweep 定期清理已经补满的桶, 需要持有m.mux
+func (m *Memory) sweep(now time.Time) {
+	if m.calls++; m.calls%1024 != 0 {
+		return
+	}
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= b.full {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/alopt/go-admin-core/storage"
+)
+
+// DefaultPrefix 限流key的前缀
+const DefaultPrefix = "go-admin:ratelimit:"
+
+// Limit 每Period最多Rate次, 允许突发Burst次
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// PerSecond 每秒rate次, 突发rate次
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second, Burst: rate}
+}
+
+// PerMinute 每分钟rate次, 突发rate次
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute, Burst: rate}
+}
+
+// PerHour 每小时rate次, 突发rate次
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour, Burst: rate}
+}
+
+// interval 每个令牌的间隔
+func (e Limit) interval() time.Duration {
+	return e.Period / time.Duration(e.Rate)
+}
+
+// Capacity 桶容量, 未设置Burst时为1
+func (e Limit) Capacity() int {
+	if e.Burst <= 0 {
+		return 1
+	}
+	return e.Burst
+}
+
+// Result 限流结果
+type Result struct {
+	Limit Limit
+	// Allowed 本次请求是否允许
+	Allowed bool
+	// Remaining 剩余可用次数
+	Remaining int
+	// RetryAfter 被拒绝时多久之后可以重试
+	RetryAfter time.Duration
+	// ResetAfter 多久之后恢复到满额
+	ResetAfter time.Duration
+}
+
+// Limiter 限流器
+type Limiter interface {
+	String() string
+	// Allow 对key请求n次
+	Allow(ctx context.Context, key string, limit Limit, n int) (*Result, error)
+}
+
+// redisClient 可以取得redis client的缓存, 如cache.Redis
+type redisClient interface {
+	GetClient() *redis.Client
+}
+
+// NewLimiter 根据缓存选择实现: redis缓存使用GCRA, 多实例共享; 其他使用进程内令牌桶
+func NewLimiter(cache storage.AdapterCache) Limiter {
+	if c, ok := cache.(redisClient); ok && c.GetClient() != nil {
+		return NewRedis(c.GetClient(), DefaultPrefix)
+	}
+	return NewMemory()
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/alopt/go-admin-core/storage/cache"
+)
+
+func limiters(t *testing.T) map[string]Limiter {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return map[string]Limiter{
+		"memory": NewMemory(),
+		"redis":  NewRedis(client, DefaultPrefix),
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     Limit
+		n         int
+		calls     int
+		allowed   int
+		remaining int
+	}{
+		{"burst", PerMinute(3), 1, 5, 3, 0},
+		{"cost", PerMinute(10), 4, 3, 2, 2},
+		{"larger-than-burst", Limit{Rate: 1, Period: time.Minute, Burst: 2}, 3, 1, 0, 2},
+	}
+	for name, limiter := range limiters(t) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				var allowed int
+				var result *Result
+				for i := 0; i < tt.calls; i++ {
+					var err error
+					result, err = limiter.Allow(context.TODO(), tt.name, tt.limit, tt.n)
+					if err != nil {
+						t.Fatalf("Allow() error = %v", err)
+					}
+					if result.Allowed {
+						allowed++
+					}
+				}
+				if allowed != tt.allowed {
+					t.Errorf("allowed = %d, want %d", allowed, tt.allowed)
+				}
+				if !result.Allowed && result.RetryAfter <= 0 {
+					t.Errorf("RetryAfter = %v, want > 0", result.RetryAfter)
+				}
+				if result.Allowed && result.Remaining != tt.remaining {
+					t.Errorf("Remaining = %d, want %d", result.Remaining, tt.remaining)
+				}
+			})
+		}
+	}
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	for name, limiter := range limiters(t) {
+		t.Run(name, func(t *testing.T) {
+			limit := Limit{Rate: 20, Period: time.Second, Burst: 1}
+			for i, want := range []bool{true, false} {
+				result, err := limiter.Allow(context.TODO(), "refill", limit, 1)
+				if err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+				if result.Allowed != want {
+					t.Fatalf("call %d Allowed = %v, want %v", i, result.Allowed, want)
+				}
+			}
+			time.Sleep(60 * time.Millisecond)
+			result, err := limiter.Allow(context.TODO(), "refill", limit, 1)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			if !result.Allowed {
+				t.Errorf("Allowed = false after refill, retry after %v", result.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	s := miniredis.RunT(t)
+	r, err := cache.NewRedis(nil, &redis.Options{Addr: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := NewLimiter(r).String(); got != "redis" {
+		t.Errorf("NewLimiter(redis) = %s, want redis", got)
+	}
+	if got := NewLimiter(cache.NewMemory()).String(); got != "memory" {
+		t.Errorf("NewLimiter(memory) = %s, want memory", got)
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcra 以令牌理论到达时间(tat)实现GCRA, 时间取redis服务器时间, 各实例时钟误差不影响
+var gcra = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call("GET", key) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval * cost
+local allow_at = new_tat - interval * burst
+if allow_at > now then
+	return {0, 0, allow_at - now, tat - now}
+end
+redis.call("SET", key, new_tat, "PX", math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`)
+
+// NewRedis 基于redis的GCRA限流, 多个实例共享
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func (*Redis) String() string {
+	return "redis"
+}
+
+// Allow 对key请求n次
+func (r *Redis) Allow(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return nil, errors.New("ratelimit: invalid limit")
+	}
+	values, err := gcra.Run(ctx, r.client, []string{r.prefix + key},
+		limit.Capacity(),
+		limit.interval().Microseconds(),
+		n,
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 4 {
+		return nil, errors.New("ratelimit: unexpected result " + strconv.Itoa(len(values)))
+	}
+	return &Result{
+		Limit:      limit,
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
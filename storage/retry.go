@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DeadLetterSuffix 死信stream后缀
+	DeadLetterSuffix = ".dlq"
+)
+
+// DefaultRetryPolicy 默认重试策略, 失败后最多重试3次
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// RetryPolicy 消费失败后的重试策略, 重试次数用完的消息转入 <stream>.dlq
+type RetryPolicy struct {
+	// MaxAttempts 最多处理次数(包含第一次), <=0 不限制
+	MaxAttempts int
+	// InitialBackoff 第一次重试前的等待时间, 默认1s
+	InitialBackoff time.Duration
+	// MaxBackoff 等待时间上限, 0不限制
+	MaxBackoff time.Duration
+	// Multiplier 每次重试等待时间的倍数, 默认2
+	Multiplier float64
+	// Jitter 随机抖动比例[0,1], 实际等待时间在 backoff*(1±Jitter) 之间
+	Jitter float64
+}
+
+// Exhausted 失败attempt次后是否不再重试
+func (e RetryPolicy) Exhausted(attempt int) bool {
+	return e.MaxAttempts > 0 && attempt >= e.MaxAttempts
+}
+
+// Backoff 第attempt次失败后的等待时间
+func (e RetryPolicy) Backoff(attempt int) time.Duration {
+	initial := e.InitialBackoff
+	if initial <= 0 {
+		initial = time.Second
+	}
+	multiplier := e.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	backoff := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if e.MaxBackoff > 0 && backoff > float64(e.MaxBackoff) {
+		backoff = float64(e.MaxBackoff)
+	}
+	if e.Jitter > 0 {
+		jitter := math.Min(e.Jitter, 1)
+		backoff = backoff * (1 + jitter*(2*rand.Float64()-1))
+	}
+	return time.Duration(backoff)
+}
+
+// DeadLetterStream 死信stream名称
+func DeadLetterStream(stream string) string {
+	return stream + DeadLetterSuffix
+}
@@ -1,15 +1,25 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"time"
-
-	"github.com/bsm/redislock"
 )
 
 const (
+	// PrefixKey 旧版本保存在values中的租户前缀, 现在使用 HeaderTenant
 	PrefixKey = "__host"
 )
 
+var (
+	// ErrNotFound is returned by the context-aware cache methods when the key does not exist
+	ErrNotFound = errors.New("storage: key not found")
+	// ErrNotObtained 锁已被占用
+	ErrNotObtained = errors.New("storage: lock not obtained")
+	// ErrLockNotHeld 锁已释放或过期
+	ErrLockNotHeld = errors.New("storage: lock not held")
+)
+
 type AdapterCache interface {
 	String() string
 	Get(key string) (string, error)
@@ -20,14 +30,63 @@ type AdapterCache interface {
 	Increase(key string) error
 	Decrease(key string) error
 	Expire(key string, dur time.Duration) error
+
+	// GetCtx returns ErrNotFound when the key does not exist
+	GetCtx(ctx context.Context, key string) (string, error)
+	// SetCtx stores val, expire <= 0 means the key never expires
+	SetCtx(ctx context.Context, key string, val interface{}, expire time.Duration) error
+	DelCtx(ctx context.Context, keys ...string) error
+	ExpireCtx(ctx context.Context, key string, dur time.Duration) error
+	// MGet returns only the keys that exist
+	MGet(ctx context.Context, keys ...string) (map[string]string, error)
+	MSet(ctx context.Context, values map[string]interface{}, expire time.Duration) error
+	// SetNX stores val only if the key does not exist and reports whether it was stored
+	SetNX(ctx context.Context, key string, val interface{}, expire time.Duration) (bool, error)
+	// GetSet stores val without expiration and returns the old value, "" if there was none
+	GetSet(ctx context.Context, key string, val interface{}) (string, error)
+	// TTL returns -1 for a key without expiration and ErrNotFound for a missing key
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Exists returns how many of the keys exist
+	Exists(ctx context.Context, keys ...string) (int64, error)
+	// IncrBy treats a missing key as 0, keeps the ttl and returns the new value
+	IncrBy(ctx context.Context, key string, n int64) (int64, error)
+	// DecrBy treats a missing key as 0, keeps the ttl and returns the new value
+	DecrBy(ctx context.Context, key string, n int64) (int64, error)
+
+	// HashSet creates the hashtable hk if needed and stores the fields in it
+	HashSet(ctx context.Context, hk string, values map[string]interface{}) error
+	HashGetAll(ctx context.Context, hk string) (map[string]string, error)
+	HashKeys(ctx context.Context, hk string) ([]string, error)
+	HashLen(ctx context.Context, hk string) (int64, error)
+	// HashIncrBy treats a missing field as 0 and returns the new value
+	HashIncrBy(ctx context.Context, hk, key string, incr int64) (int64, error)
 }
 
 type AdapterQueue interface {
 	String() string
 	Append(message Messager) error
-	Register(name string, f ConsumerFunc)
+	// AppendDelay 延迟delay后投递
+	AppendDelay(message Messager, delay time.Duration) error
+	// AppendAt 在指定时间投递, 已过期的时间立即投递
+	AppendAt(message Messager, at time.Time) error
+	// Register 注册消费者, 处理失败的消息按RetryPolicy重试, 默认DefaultRetryPolicy
+	Register(name string, f ConsumerFunc, opts ...RegisterOption)
+	// RegisterCtx 注册带context的消费者, 关闭超时后context被取消
+	RegisterCtx(name string, f ConsumerCtxFunc, opts ...RegisterOption)
+	// Run 阻塞直到队列关闭
 	Run()
+	// Shutdown 等同于 ShutdownCtx(context.Background())
 	Shutdown()
+	// ShutdownCtx 停止接收消息并等待处理中的消息完成, ctx到期后返回ctx.Err()
+	ShutdownCtx(ctx context.Context) error
+}
+
+// AdapterDeadLetter 死信查看与重放, stream均为原stream名称
+type AdapterDeadLetter interface {
+	// DeadLetters 查看最多limit条死信
+	DeadLetters(stream string, limit int) ([]Messager, error)
+	// Replay 将最多limit条死信重置失败次数后投递回原stream, 返回重放条数
+	Replay(stream string, limit int) (int, error)
 }
 
 type Messager interface {
@@ -41,11 +100,35 @@ type Messager interface {
 	SetPrefix(string)
 	SetErrorCount(count int)
 	GetErrorCount() int
+	// GetHeaders 消息头, 与values分开保存, 不会混入业务数据
+	GetHeaders() map[string]string
+	SetHeaders(map[string]string)
+	GetHeader(key string) string
+	SetHeader(key, value string)
 }
 
 type ConsumerFunc func(Messager) error
 
+// ConsumerCtxFunc 带context的消费者
+type ConsumerCtxFunc func(ctx context.Context, message Messager) error
+
 type AdapterLocker interface {
 	String() string
-	Lock(key string, ttl int64, options *redislock.Options) (*redislock.Lock, error)
+	// Lock 获取key的锁, 有效期ttl秒, 已被占用时返回 ErrNotObtained
+	Lock(key string, ttl int64, opts ...LockOption) (Lock, error)
+	// LockCtx 获取key的锁, 被占用时等待直到获得或ctx结束
+	LockCtx(ctx context.Context, key string, opts ...LockOption) (Lock, error)
+}
+
+// Lock 已获得的锁
+type Lock interface {
+	Key() string
+	// Token 持有者标识
+	Token() string
+	// TTL 剩余有效期, 锁已失效时返回0
+	TTL(ctx context.Context) (time.Duration, error)
+	// Refresh 将有效期重置为ttl, 锁已失效时返回 ErrLockNotHeld
+	Refresh(ctx context.Context, ttl time.Duration) error
+	// Release 释放锁, 锁已失效时返回 ErrLockNotHeld
+	Release(ctx context.Context) error
 }
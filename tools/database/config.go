@@ -34,7 +34,7 @@ func NewConfigure(
 	}
 }
 
-// Init 获取db，⚠️注意：读写分离只能配置一组
+// Init 获取db, 读写分离可以配置多组: 不指定tables和name的为全局分组(只能有一组), 其他按表或名称(dbresolver.Use)生效
 func (e *DBConfig) Init(config *gorm.Config, open func(string) gorm.Dialector) (*gorm.DB, error) {
 	db, err := gorm.Open(open(e.dsn), config)
 	if err != nil {
@@ -60,9 +60,11 @@ func (e *DBConfig) Init(config *gorm.Config, open func(string) gorm.Dialector) (
 		register = register.SetMaxIdleConns(e.maxIdleConns)
 	}
 	if register != nil {
-		err = db.Use(register)
+		if err = db.Use(register); err != nil {
+			return db, err
+		}
 	}
-	return db, err
+	return db, db.Use(Hints{})
 }
 
 type DBResolverConfig struct {
@@ -70,19 +72,61 @@ type DBResolverConfig struct {
 	replicas []string
 	policy   string
 	tables   []interface{}
+	options  resolverOptions
+}
+
+type resolverOptions struct {
+	name          string
+	weights       []int
+	checkInterval time.Duration
+	checkFailures int
+}
+
+// ResolverOption 读写分离分组的选项
+type ResolverOption func(*resolverOptions)
+
+// WithResolverName 分组名称, 通过db.Clauses(dbresolver.Use(name))使用
+func WithResolverName(name string) ResolverOption {
+	return func(o *resolverOptions) {
+		o.name = name
+	}
+}
+
+// WithWeights weighted策略的权重, 与sources、replicas合并后的顺序对应, 默认为1
+func WithWeights(weights ...int) ResolverOption {
+	return func(o *resolverOptions) {
+		o.weights = weights
+	}
 }
 
-// NewResolverConfigure 初始化 ResolverConfigure
-func NewResolverConfigure(sources, replicas []string, policy string, tables []string) ResolverConfigure {
+// WithHealthCheck 每interval检查一次连接, 连续failures次失败后剔除, 恢复后重新加入; interval<=0不检查
+func WithHealthCheck(interval time.Duration, failures int) ResolverOption {
+	return func(o *resolverOptions) {
+		o.checkInterval = interval
+		o.checkFailures = failures
+	}
+}
+
+// NewResolverConfigure 初始化 ResolverConfigure,
+// policy支持random(默认)、round_robin、weighted、least_latency
+func NewResolverConfigure(sources, replicas []string, policy string, tables []string, opts ...ResolverOption) ResolverConfigure {
 	data := make([]interface{}, len(tables))
 	for i := range tables {
 		data[i] = tables[i]
 	}
+	o := resolverOptions{
+		checkInterval: defaultCheckInterval,
+		checkFailures: defaultCheckFailures,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return &DBResolverConfig{
 		sources:  sources,
 		replicas: replicas,
 		policy:   policy,
 		tables:   data,
+		options:  o,
 	}
 }
 
@@ -92,29 +136,39 @@ func (e *DBResolverConfig) Init(
 	if len(e.tables) == 0 && len(e.sources) == 0 && len(e.replicas) == 0 {
 		return register
 	}
+	datas := e.tables
+	if e.options.name != "" {
+		datas = append(datas[:len(datas):len(datas)], e.options.name)
+	}
 	var config dbresolver.Config
+	var weighted *weightedBalancer
+	if p := newPolicy(e.policy, &e.options); p != nil {
+		config.Policy = p
+		weighted, _ = p.(*policy).balancer.(*weightedBalancer)
+	}
+	// dialector weighted策略需要记录连接的下标, i为sources、replicas合并后的下标
+	dialector := func(dsn string, i int) gorm.Dialector {
+		if weighted == nil {
+			return open(dsn)
+		}
+		return &weightedDialector{Dialector: open(dsn), index: i, balancer: weighted}
+	}
 	if len(e.sources) > 0 {
 		config.Sources = make([]gorm.Dialector, len(e.sources))
 		for i := range e.sources {
-			config.Sources[i] = open(e.sources[i])
+			config.Sources[i] = dialector(e.sources[i], i)
 		}
 	}
 	if len(e.replicas) > 0 {
 		config.Replicas = make([]gorm.Dialector, len(e.replicas))
 		for i := range e.replicas {
-			config.Replicas[i] = open(e.replicas[i])
-		}
-	}
-	if e.policy != "" {
-		policy, ok := policies[e.policy]
-		if ok {
-			config.Policy = policy
+			config.Replicas[i] = dialector(e.replicas[i], len(e.sources)+i)
 		}
 	}
 	if register == nil {
-		register = dbresolver.Register(config, e.tables...)
+		register = dbresolver.Register(config, datas...)
 		return register
 	}
-	register = register.Register(config, e.tables...)
+	register = register.Register(config, datas...)
 	return register
 }
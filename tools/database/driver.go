@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+var (
+	drivers = map[string]func(string) gorm.Dialector{
+		"mysql":     mysql.Open,
+		"postgres":  postgres.Open,
+		"sqlite3":   sqlite.Open,
+		"sqlite":    sqlite.Open,
+		"sqlserver": sqlserver.Open,
+	}
+	driverMux sync.RWMutex
+)
+
+// RegisterDriver 注册driver对应的gorm.Dialector, 可覆盖内置的mysql、postgres、sqlite3、sqlserver
+func RegisterDriver(driver string, open func(string) gorm.Dialector) {
+	driverMux.Lock()
+	defer driverMux.Unlock()
+	drivers[driver] = open
+}
+
+// GetDriver 获取driver对应的gorm.Dialector
+func GetDriver(driver string) (func(string) gorm.Dialector, error) {
+	driverMux.RLock()
+	defer driverMux.RUnlock()
+	open, ok := drivers[driver]
+	if !ok {
+		return nil, fmt.Errorf("database: unsupported driver %s", driver)
+	}
+	return open, nil
+}
+
+// Ping 检查db连接, 用于就绪检查
+func Ping(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return errors.New("database: db is nil")
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGetDriver(t *testing.T) {
+	tests := []struct {
+		driver  string
+		wantErr bool
+	}{
+		{"mysql", false},
+		{"postgres", false},
+		{"sqlite3", false},
+		{"sqlserver", false},
+		{"oracle", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			open, err := GetDriver(tt.driver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && open("dsn").Name() == "" {
+				t.Errorf("GetDriver() dialector has no name")
+			}
+		})
+	}
+}
+
+func TestDBConfig_InitSqlite(t *testing.T) {
+	dir := t.TempDir()
+	open, err := GetDriver("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registers := []ResolverConfigure{
+		NewResolverConfigure(nil, []string{filepath.Join(dir, "replica.db")}, "random", nil),
+	}
+	db, err := NewConfigure(filepath.Join(dir, "source.db"), 2, 4, 60, 60, registers).
+		Init(&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}, open)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err = Ping(db)(context.TODO()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	if err = db.Exec("create table t (id int)").Error; err != nil {
+		t.Fatal(err)
+	}
+	// 读走replica, replica中没有该表
+	var count int64
+	if err = db.Table("t").Count(&count).Error; err == nil {
+		t.Errorf("read did not use replica")
+	}
+	sqlDB, _ := db.DB()
+	_ = sqlDB.Close()
+	if err = Ping(db)(context.TODO()); err == nil {
+		t.Errorf("Ping() after close error = nil")
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	log "github.com/alopt/go-admin-core/logger"
+)
+
+const (
+	defaultCheckInterval = 10 * time.Second
+	defaultCheckFailures = 3
+)
+
+// replicaState 单个连接的健康状态
+type replicaState struct {
+	latency   time.Duration
+	failures  int
+	ejected   bool
+	checking  bool
+	checkedAt time.Time
+}
+
+// health 跟踪连接的健康状态, 在选择连接时按interval异步ping,
+// 连续failures次失败后剔除, ping成功后恢复; 没有请求时不检查, 不需要关闭
+type health struct {
+	interval time.Duration
+	failures int
+	states   map[gorm.ConnPool]*replicaState
+	mux      sync.Mutex
+}
+
+func newHealth(interval time.Duration, failures int) *health {
+	if failures <= 0 {
+		failures = defaultCheckFailures
+	}
+	return &health{
+		interval: interval,
+		failures: failures,
+		states:   make(map[gorm.ConnPool]*replicaState),
+	}
+}
+
+// observe 对到期的连接发起检查
+func (e *health) observe(pools []gorm.ConnPool) {
+	now := time.Now()
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	for _, pool := range pools {
+		state, ok := e.states[pool]
+		if !ok {
+			state = &replicaState{}
+			e.states[pool] = state
+		}
+		if state.checking || now.Sub(state.checkedAt) < e.interval {
+			continue
+		}
+		state.checking = true
+		go e.check(pool)
+	}
+}
+
+func (e *health) check(pool gorm.ConnPool) {
+	var err error
+	start := time.Now()
+	if pinger, ok := pool.(interface{ PingContext(context.Context) error }); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+		err = pinger.PingContext(ctx)
+		cancel()
+	}
+	elapsed := time.Since(start)
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	state := e.states[pool]
+	state.checking = false
+	state.checkedAt = time.Now()
+	if err != nil {
+		state.failures++
+		if !state.ejected && state.failures >= e.failures {
+			state.ejected = true
+			log.Errorf("database replica ejected after %d failures, %s", state.failures, err.Error())
+		}
+		return
+	}
+	if state.ejected {
+		log.Infof("database replica recovered")
+	}
+	state.failures = 0
+	state.ejected = false
+	// 延迟取指数加权平均, 避免单次抖动
+	if state.latency == 0 {
+		state.latency = elapsed
+	} else {
+		state.latency = (state.latency*7 + elapsed) / 8
+	}
+}
+
+func (e *health) healthy(pool gorm.ConnPool) bool {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	state, ok := e.states[pool]
+	return !ok || !state.ejected
+}
+
+func (e *health) latency(pool gorm.ConnPool) time.Duration {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if state, ok := e.states[pool]; ok {
+		return state.latency
+	}
+	return 0
+}
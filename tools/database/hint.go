@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type hintKey struct{}
+
+// hint 请求级的读写分离提示
+type hint struct {
+	primary bool
+	wrote   atomic.Bool
+}
+
+// WithPrimary ctx中的查询都走主库
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hintKey{}, &hint{primary: true})
+}
+
+// WithStickyPrimary ctx中发生写之后的查询都走主库, 保证读到自己的写; ctx中已有提示时原样返回
+func WithStickyPrimary(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(hintKey{}).(*hint); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, hintKey{}, &hint{})
+}
+
+// UsePrimary ctx中的查询是否走主库
+func UsePrimary(ctx context.Context) bool {
+	h, ok := ctx.Value(hintKey{}).(*hint)
+	return ok && (h.primary || h.wrote.Load())
+}
+
+func hintOf(db *gorm.DB) *hint {
+	if db.Statement.Context == nil {
+		return nil
+	}
+	h, _ := db.Statement.Context.Value(hintKey{}).(*hint)
+	return h
+}
+
+// Hints 根据ctx中的提示切换到主库, 需要在dbresolver之后注册
+type Hints struct{}
+
+func (Hints) Name() string {
+	return "go-admin:db_hints"
+}
+
+func (e Hints) Initialize(db *gorm.DB) error {
+	read := func(db *gorm.DB) {
+		if UsePrimary(db.Statement.Context) {
+			dbresolver.Write.ModifyStatement(db.Statement)
+		}
+	}
+	write := func(db *gorm.DB) {
+		if h := hintOf(db); h != nil && db.Error == nil {
+			h.wrote.Store(true)
+		}
+	}
+	raw := func(db *gorm.DB) {
+		sql := strings.TrimSpace(db.Statement.SQL.String())
+		if len(sql) < 6 || !strings.EqualFold(sql[:6], "select") {
+			write(db)
+		}
+	}
+	callbacks := []error{
+		db.Callback().Query().After("gorm:db_resolver").Before("gorm:query").Register("go-admin:db_hints", read),
+		db.Callback().Row().After("gorm:db_resolver").Before("gorm:row").Register("go-admin:db_hints", read),
+		db.Callback().Raw().After("gorm:db_resolver").Before("gorm:raw").Register("go-admin:db_hints", read),
+		db.Callback().Create().After("gorm:create").Register("go-admin:db_hints", write),
+		db.Callback().Update().After("gorm:update").Register("go-admin:db_hints", write),
+		db.Callback().Delete().After("gorm:delete").Register("go-admin:db_hints", write),
+		db.Callback().Raw().After("gorm:raw").Register("go-admin:db_hints_write", raw),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// balancer 在健康的连接中选择一个, candidates为健康连接在pools中的下标
+type balancer interface {
+	pick(candidates []int, pools []gorm.ConnPool) int
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) pick(candidates []int, _ []gorm.ConnPool) int {
+	return candidates[rand.Intn(len(candidates))]
+}
+
+type roundRobinBalancer struct {
+	next uint64
+}
+
+func (e *roundRobinBalancer) pick(candidates []int, _ []gorm.ConnPool) int {
+	n := atomic.AddUint64(&e.next, 1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// weightedBalancer 按权重随机, weights与sources、replicas合并后的顺序对应, 未配置或<=0的权重为1;
+// dbresolver分别用sources和replicas调用Resolve, 因此按连接查找其在合并后的下标
+type weightedBalancer struct {
+	weights []int
+	index   sync.Map
+}
+
+// record 记录连接在sources、replicas合并后的下标
+func (e *weightedBalancer) record(i int, pool gorm.ConnPool) {
+	e.index.Store(pool, i)
+}
+
+func (e *weightedBalancer) weight(pool gorm.ConnPool) int {
+	if v, ok := e.index.Load(pool); ok {
+		if i := v.(int); i < len(e.weights) && e.weights[i] > 0 {
+			return e.weights[i]
+		}
+	}
+	return 1
+}
+
+func (e *weightedBalancer) pick(candidates []int, pools []gorm.ConnPool) int {
+	var total int
+	for _, i := range candidates {
+		total += e.weight(pools[i])
+	}
+	n := rand.Intn(total)
+	for _, i := range candidates {
+		if n -= e.weight(pools[i]); n < 0 {
+			return i
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// weightedDialector 打开连接后记录其下标, 供weighted策略查找权重
+type weightedDialector struct {
+	gorm.Dialector
+	index    int
+	balancer *weightedBalancer
+}
+
+func (e *weightedDialector) Initialize(db *gorm.DB) error {
+	if err := e.Dialector.Initialize(db); err != nil {
+		return err
+	}
+	e.balancer.record(e.index, db.ConnPool)
+	return nil
+}
+
+// leastLatencyBalancer 选择健康检查延迟最低的连接, 尚未检查过的连接优先
+type leastLatencyBalancer struct {
+	health *health
+}
+
+func (e *leastLatencyBalancer) pick(candidates []int, pools []gorm.ConnPool) int {
+	best := candidates[0]
+	for _, i := range candidates[1:] {
+		if e.health.latency(pools[i]) < e.health.latency(pools[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// policy 实现dbresolver.Policy, 剔除健康检查失败的连接后交给balancer选择
+type policy struct {
+	balancer balancer
+	health   *health
+}
+
+func (e *policy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	candidates := make([]int, 0, len(pools))
+	if e.health != nil {
+		e.health.observe(pools)
+		for i := range pools {
+			if e.health.healthy(pools[i]) {
+				candidates = append(candidates, i)
+			}
+		}
+	}
+	// 全部不健康时仍在所有连接中选择
+	if len(candidates) == 0 {
+		for i := range pools {
+			candidates = append(candidates, i)
+		}
+	}
+	return pools[e.balancer.pick(candidates, pools)]
+}
+
+// newPolicy 按名称创建, 每个resolver分组独立计数和健康检查; 未知名称返回nil
+func newPolicy(name string, o *resolverOptions) dbresolver.Policy {
+	var h *health
+	if o.checkInterval > 0 {
+		h = newHealth(o.checkInterval, o.checkFailures)
+	}
+	var b balancer
+	switch name {
+	case "", "random":
+		b = randomBalancer{}
+	case "round_robin":
+		b = &roundRobinBalancer{}
+	case "weighted":
+		b = &weightedBalancer{weights: o.weights}
+	case "least_latency":
+		if h == nil {
+			h = newHealth(defaultCheckInterval, defaultCheckFailures)
+		}
+		b = &leastLatencyBalancer{health: h}
+	default:
+		return nil
+	}
+	return &policy{balancer: b, health: h}
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+)
+
+// fakePool 可以控制ping结果和延迟的连接
+type fakePool struct {
+	gorm.ConnPool
+	name  string
+	delay time.Duration
+	down  atomic.Bool
+}
+
+func (e *fakePool) PingContext(context.Context) error {
+	time.Sleep(e.delay)
+	if e.down.Load() {
+		return errors.New(e.name + " down")
+	}
+	return nil
+}
+
+func resolveN(p dbresolver.Policy, pools []gorm.ConnPool, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[p.Resolve(pools).(*fakePool).name]++
+	}
+	return counts
+}
+
+func TestPolicy(t *testing.T) {
+	pools := []gorm.ConnPool{&fakePool{name: "a"}, &fakePool{name: "b"}, &fakePool{name: "c"}}
+	tests := []struct {
+		name   string
+		policy string
+		opts   []ResolverOption
+		n      int
+		// want 每个连接期望的比例, 允许20%误差
+		want map[string]int
+	}{
+		{"random", "random", nil, 3000, map[string]int{"a": 1, "b": 1, "c": 1}},
+		{"round robin", "round_robin", nil, 12, map[string]int{"a": 1, "b": 1, "c": 1}},
+		{"weighted", "weighted", []ResolverOption{WithWeights(0, 1, 4)}, 6000, map[string]int{"a": 1, "b": 1, "c": 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &resolverOptions{}
+			for _, opt := range tt.opts {
+				opt(o)
+			}
+			p := newPolicy(tt.policy, o)
+			if p == nil {
+				t.Fatalf("newPolicy(%s) = nil", tt.policy)
+			}
+			if b, ok := p.(*policy).balancer.(*weightedBalancer); ok {
+				for i := range pools {
+					b.record(i, pools[i])
+				}
+			}
+			var total int
+			for _, w := range tt.want {
+				total += w
+			}
+			got := resolveN(p, pools, tt.n)
+			for name, w := range tt.want {
+				want := tt.n * w / total
+				if got[name] < want*8/10 || got[name] > want*12/10 {
+					t.Errorf("Resolve() %s = %d, want about %d", name, got[name], want)
+				}
+			}
+		})
+	}
+	if newPolicy("unknown", &resolverOptions{}) != nil {
+		t.Errorf("newPolicy(unknown) != nil")
+	}
+}
+
+func TestPolicy_Health(t *testing.T) {
+	a, b := &fakePool{name: "a"}, &fakePool{name: "b", delay: 5 * time.Millisecond}
+	pools := []gorm.ConnPool{a, b}
+	p := newPolicy("least_latency", &resolverOptions{checkInterval: 10 * time.Millisecond, checkFailures: 2})
+	// 等待检查出延迟
+	wait := func(f func() bool) {
+		deadline := time.Now().Add(time.Second)
+		for !f() && time.Now().Before(deadline) {
+			p.Resolve(pools)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	h := p.(*policy).health
+	wait(func() bool { return h.latency(a) > 0 && h.latency(b) > 0 })
+	if got := resolveN(p, pools, 10); got["a"] != 10 {
+		t.Errorf("least latency = %v, want all a", got)
+	}
+
+	a.down.Store(true)
+	wait(func() bool { return !h.healthy(a) })
+	if got := resolveN(p, pools, 10); got["b"] != 10 {
+		t.Errorf("after a ejected = %v, want all b", got)
+	}
+
+	b.down.Store(true)
+	wait(func() bool { return !h.healthy(b) })
+	if got := resolveN(p, pools, 10); got["a"]+got["b"] != 10 {
+		t.Errorf("all ejected = %v, want fallback to all", got)
+	}
+
+	a.down.Store(false)
+	wait(func() bool { return h.healthy(a) })
+	if got := resolveN(p, pools, 10); got["a"] != 10 {
+		t.Errorf("after a recovered = %v, want all a", got)
+	}
+}
+
+// TestPolicy_Weights 权重按sources、replicas合并后的顺序对应
+func TestPolicy_Weights(t *testing.T) {
+	dir := t.TempDir()
+	open, _ := GetDriver("sqlite3")
+	source := filepath.Join(dir, "source.db")
+	replicas := []string{filepath.Join(dir, "replica1.db"), filepath.Join(dir, "replica2.db")}
+	for _, dsn := range replicas {
+		raw, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = raw.Exec("create table t (name text); insert into t values ('" + filepath.Base(dsn) + "')")
+		_ = raw.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := NewConfigure(source, 0, 0, 0, 0, []ResolverConfigure{
+		NewResolverConfigure([]string{source}, replicas, "weighted", nil, WithWeights(1, 3, 1)),
+	}).Init(&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}, open)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	n := 2000
+	got := make(map[string]int)
+	for i := 0; i < n; i++ {
+		var name string
+		db.Table("t").Select("name").Scan(&name)
+		got[name]++
+	}
+	for name, w := range map[string]int{"replica1.db": 3, "replica2.db": 1} {
+		want := n * w / 4
+		if got[name] < want*8/10 || got[name] > want*12/10 {
+			t.Errorf("reads from %s = %d, want about %d", name, got[name], want)
+		}
+	}
+}
+
+func TestHints(t *testing.T) {
+	dir := t.TempDir()
+	open, _ := GetDriver("sqlite3")
+	source, replica, reports := filepath.Join(dir, "source.db"), filepath.Join(dir, "replica.db"), filepath.Join(dir, "reports.db")
+	for _, dsn := range []string{replica, reports} {
+		raw, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = raw.Exec("create table t (name text); insert into t values ('" + filepath.Base(dsn) + "')")
+		_ = raw.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := NewConfigure(source, 0, 0, 0, 0, []ResolverConfigure{
+		NewResolverConfigure(nil, []string{replica}, "round_robin", nil),
+		NewResolverConfigure(nil, []string{reports}, "", nil, WithResolverName("reports")),
+	}).Init(&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}, open)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err = db.Exec("create table t (name text)").Error; err != nil {
+		t.Fatal(err)
+	}
+	read := func(db *gorm.DB) string {
+		var names []string
+		db.Table("t").Pluck("name", &names)
+		if len(names) == 0 {
+			return "source.db"
+		}
+		return names[0]
+	}
+
+	ctx := WithStickyPrimary(context.Background())
+	if got := read(db.WithContext(ctx)); got != "replica.db" {
+		t.Errorf("read before write = %s, want replica.db", got)
+	}
+	if got := read(db.Clauses(dbresolver.Use("reports"))); got != "reports.db" {
+		t.Errorf("read from named group = %s, want reports.db", got)
+	}
+	if err = db.WithContext(ctx).Exec("insert into t values ('written')").Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := read(db.WithContext(WithStickyPrimary(ctx))); got != "written" {
+		t.Errorf("read after write = %s, want written", got)
+	}
+	if got := read(db.WithContext(context.Background())); got != "replica.db" {
+		t.Errorf("read in other request = %s, want replica.db", got)
+	}
+	if got := read(db.WithContext(WithPrimary(context.Background()))); got != "written" {
+		t.Errorf("read with primary = %s, want written", got)
+	}
+}
@@ -5,10 +5,6 @@ import (
 	"gorm.io/plugin/dbresolver"
 )
 
-var policies = map[string]dbresolver.Policy{
-	"random": dbresolver.RandomPolicy{},
-}
-
 type Configure interface {
 	Init(*gorm.Config, func(string) gorm.Dialector) (*gorm.DB, error)
 }
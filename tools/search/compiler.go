@@ -0,0 +1,436 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidQuery 查询参数不是结构体
+	ErrInvalidQuery = errors.New("search: query must be a struct")
+	// ErrInvalidOrder 排序字段或方向不合法
+	ErrInvalidOrder = errors.New("search: invalid order")
+)
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// plans 按结构体类型缓存解析后的tag计划
+var plans sync.Map
+
+type options struct {
+	pageIndex   int
+	pageSize    int
+	maxPageSize int
+}
+
+type Option func(*options)
+
+func setDefaultOptions() options {
+	return options{
+		maxPageSize: 1000,
+	}
+}
+
+// WithPagination 分页, pageSize<=0时不分页
+func WithPagination(pageIndex, pageSize int) Option {
+	return func(o *options) {
+		o.pageIndex = pageIndex
+		o.pageSize = pageSize
+	}
+}
+
+// WithMaxPageSize 每页最大条数, 默认1000
+func WithMaxPageSize(n int) Option {
+	return func(o *options) {
+		o.maxPageSize = n
+	}
+}
+
+// planStep 一个带search标签的字段
+type planStep struct {
+	index    []int
+	tag      *resolveSearchTag
+	columns  map[string]bool
+	children []*planStep
+}
+
+type plan struct {
+	steps []*planStep
+	err   error
+}
+
+// compiledCond 已取值的条件, group非空时为and/or分组
+type compiledCond struct {
+	tag      *resolveSearchTag
+	value    reflect.Value
+	group    string
+	children []*compiledCond
+}
+
+type compiledOrder struct {
+	table  string
+	column string
+	desc   bool
+}
+
+type compiledQuery struct {
+	joins  []*resolveSearchTag
+	conds  []*compiledCond
+	orders []compiledOrder
+}
+
+// Compile 将搜索结构体编译为gorm scope
+/**
+ *	条件按字段声明顺序拼接, 标识符按数据库方言转义
+ *	sort 动态排序, 值为 name,-created_at 或 name desc,created_at asc,
+ *	     字段需在columns白名单内 e.g. search:"type:sort;table:orders;columns:name,created_at"
+ */
+func Compile(q interface{}, opts ...Option) (func(*gorm.DB) *gorm.DB, error) {
+	o := setDefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	v := reflect.Indirect(reflect.ValueOf(q))
+	if v.Kind() != reflect.Struct {
+		return nil, ErrInvalidQuery
+	}
+	p := loadPlan(v.Type())
+	if p.err != nil {
+		return nil, p.err
+	}
+	c := &compiledQuery{}
+	if err := c.walk(p.steps, v, &c.conds); err != nil {
+		return nil, err
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		db = c.apply(db)
+		if o.pageSize > 0 {
+			size := o.pageSize
+			if o.maxPageSize > 0 && size > o.maxPageSize {
+				size = o.maxPageSize
+			}
+			page := o.pageIndex
+			if page <= 0 {
+				page = 1
+			}
+			db = db.Offset((page - 1) * size).Limit(size)
+		}
+		return db
+	}, nil
+}
+
+func loadPlan(t reflect.Type) *plan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*plan)
+	}
+	p := &plan{}
+	p.steps, p.err = buildPlan(t, nil)
+	actual, _ := plans.LoadOrStore(t, p)
+	return actual.(*plan)
+}
+
+func buildPlan(t reflect.Type, prefix []int) ([]*planStep, error) {
+	steps := make([]*planStep, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append(make([]int, 0, len(prefix)+1), prefix...), i)
+		tag, ok := f.Tag.Lookup(FromQueryTag)
+		if !ok {
+			//递归调用
+			if f.Type.Kind() == reflect.Struct {
+				children, err := buildPlan(f.Type, index)
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, children...)
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+		step := &planStep{index: index, tag: makeTag(tag)}
+		if err := step.validate(f); err != nil {
+			return nil, fmt.Errorf("search: field %s.%s: %w", t.Name(), f.Name, err)
+		}
+		switch step.tag.Type {
+		case "left", "inner", "right", "or", "and":
+			children, err := buildPlan(f.Type, nil)
+			if err != nil {
+				return nil, err
+			}
+			step.children = children
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (e *planStep) validate(f reflect.StructField) error {
+	t := e.tag
+	switch t.Type {
+	case "or", "and":
+		if f.Type.Kind() != reflect.Struct {
+			return fmt.Errorf("%s group must be a struct", t.Type)
+		}
+		return nil
+	case "left", "inner", "right":
+		if f.Type.Kind() != reflect.Struct {
+			return fmt.Errorf("%s join must be a struct", t.Type)
+		}
+		if len(t.On) != 2 {
+			return errors.New("join needs on:column:column")
+		}
+		return checkIdentifier(t.Table, t.Join, t.On[0], t.On[1])
+	case "sort":
+		if f.Type.Kind() != reflect.String {
+			return errors.New("sort must be a string")
+		}
+		e.columns = make(map[string]bool)
+		for _, c := range t.Columns {
+			if c = strings.TrimSpace(c); c != "" {
+				e.columns[c] = true
+			}
+		}
+		if len(e.columns) == 0 {
+			return errors.New("sort needs columns")
+		}
+		for c := range e.columns {
+			if err := checkIdentifier(c); err != nil {
+				return err
+			}
+		}
+		if t.Table != "" {
+			return checkIdentifier(t.Table)
+		}
+		return nil
+	case "jsonexact":
+		if t.Path == "" {
+			return errors.New("jsonexact needs path")
+		}
+	case "exact", "iexact", "ne", "glt", "contains", "icontains", "containspath",
+		"gt", "gte", "lt", "lte", "startswith", "istartswith", "endswith", "iendswith",
+		"in", "notin", "between", "isnull", "isnotnull", "jsoncontains", "order":
+	default:
+		return fmt.Errorf("unknown type %q", t.Type)
+	}
+	if t.Table != "" {
+		if err := checkIdentifier(t.Table); err != nil {
+			return err
+		}
+	}
+	return checkIdentifier(t.Column)
+}
+
+func checkIdentifier(names ...string) error {
+	for _, name := range names {
+		if !identifier.MatchString(name) {
+			return fmt.Errorf("invalid identifier %q", name)
+		}
+	}
+	return nil
+}
+
+// walk 按计划取值, 跳过零值字段
+func (e *compiledQuery) walk(steps []*planStep, v reflect.Value, target *[]*compiledCond) error {
+	for _, step := range steps {
+		fv := v.FieldByIndex(step.index)
+		if fv.IsZero() {
+			continue
+		}
+		t := step.tag
+		switch t.Type {
+		case "left", "inner", "right":
+			e.joins = append(e.joins, t)
+			if err := e.walk(step.children, fv, &e.conds); err != nil {
+				return err
+			}
+		case "or", "and":
+			group := &compiledCond{group: t.Type}
+			if err := e.walk(step.children, fv, &group.children); err != nil {
+				return err
+			}
+			if len(group.children) > 0 {
+				*target = append(*target, group)
+			}
+		case "order":
+			switch strings.ToLower(fv.String()) {
+			case "asc":
+				e.orders = append(e.orders, compiledOrder{table: t.Table, column: t.Column})
+			case "desc":
+				e.orders = append(e.orders, compiledOrder{table: t.Table, column: t.Column, desc: true})
+			default:
+				return fmt.Errorf("%w: %s", ErrInvalidOrder, fv.String())
+			}
+		case "sort":
+			orders, err := parseSort(fv.String(), t.Table, step.columns)
+			if err != nil {
+				return err
+			}
+			e.orders = append(e.orders, orders...)
+		case "between":
+			if _, ok := betweenValues(fv); ok {
+				*target = append(*target, &compiledCond{tag: t, value: fv})
+			}
+		default:
+			*target = append(*target, &compiledCond{tag: t, value: fv})
+		}
+	}
+	return nil
+}
+
+// parseSort 解析动态排序, 字段需在白名单内
+func parseSort(s, table string, columns map[string]bool) ([]compiledOrder, error) {
+	orders := make([]compiledOrder, 0)
+	for _, item := range strings.Split(s, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		o := compiledOrder{table: table, column: fields[0]}
+		if strings.HasPrefix(o.column, "-") {
+			o.column = o.column[1:]
+			o.desc = true
+		}
+		if len(fields) > 2 || (len(fields) == 2 && o.desc) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOrder, item)
+		}
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				o.desc = true
+			default:
+				return nil, fmt.Errorf("%w: %s", ErrInvalidOrder, item)
+			}
+		}
+		if !columns[o.column] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOrder, o.column)
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+func (e *compiledQuery) apply(db *gorm.DB) *gorm.DB {
+	pg := db.Dialector.Name() == Postgres
+	quote := func(table, column string) string {
+		return db.Statement.Quote(clause.Column{Table: table, Name: column})
+	}
+	for _, t := range e.joins {
+		db = db.Joins(fmt.Sprintf("%s join %s on %s = %s",
+			t.Type, db.Statement.Quote(t.Join), quote(t.Join, t.On[0]), quote(t.Table, t.On[1])))
+	}
+	for _, c := range e.conds {
+		if expr := c.build(pg, quote); expr != nil {
+			db = db.Where(expr)
+		}
+	}
+	for _, o := range e.orders {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Table: o.table, Name: o.column}, Desc: o.desc})
+	}
+	return db
+}
+
+// build 生成条件表达式, 分组由gorm负责加括号
+func (e *compiledCond) build(pg bool, quote func(table, column string) string) clause.Expression {
+	if e.group != "" {
+		exprs := make([]clause.Expression, 0, len(e.children))
+		for _, c := range e.children {
+			if expr := c.build(pg, quote); expr != nil {
+				exprs = append(exprs, expr)
+			}
+		}
+		if len(exprs) == 0 {
+			return nil
+		}
+		if e.group == "or" {
+			return clause.Or(exprs...)
+		}
+		return clause.And(exprs...)
+	}
+	sql, vars := e.expr(pg, quote)
+	if sql == "" {
+		return nil
+	}
+	return clause.Expr{SQL: sql, Vars: vars}
+}
+
+// expr 生成单个条件语句及参数
+func (e *compiledCond) expr(pg bool, quote func(table, column string) string) (string, []interface{}) {
+
+	t := e.tag
+	col := quote(t.Table, t.Column)
+	like := "like"
+	if pg {
+		like = "ilike"
+	}
+	switch t.Type {
+	case "exact", "iexact":
+		return col + " = ?", []interface{}{e.value.Interface()}
+	case "ne", "glt":
+		return col + " <> ?", []interface{}{e.value.Interface()}
+	case "contains":
+		return col + " like ?", []interface{}{"%" + e.value.String() + "%"}
+	case "icontains":
+		return col + " " + like + " ?", []interface{}{"%" + e.value.String() + "%"}
+	case "containspath":
+		return col + " like ?", []interface{}{"%/" + e.value.String() + "/%"}
+	case "gt":
+		return col + " > ?", []interface{}{e.value.Interface()}
+	case "gte":
+		return col + " >= ?", []interface{}{e.value.Interface()}
+	case "lt":
+		return col + " < ?", []interface{}{e.value.Interface()}
+	case "lte":
+		return col + " <= ?", []interface{}{e.value.Interface()}
+	case "startswith":
+		return col + " like ?", []interface{}{e.value.String() + "%"}
+	case "istartswith":
+		return col + " " + like + " ?", []interface{}{e.value.String() + "%"}
+	case "endswith":
+		return col + " like ?", []interface{}{"%" + e.value.String()}
+	case "iendswith":
+		return col + " " + like + " ?", []interface{}{"%" + e.value.String()}
+	case "in":
+		return col + " in ?", []interface{}{e.value.Interface()}
+	case "notin":
+		return col + " not in ?", []interface{}{e.value.Interface()}
+	case "between":
+		v, _ := betweenValues(e.value)
+		return col + " between ? and ?", v
+	case "isnull":
+		return col + " is null", nil
+	case "isnotnull":
+		return col + " is not null", nil
+	case "jsoncontains":
+		v, err := json.Marshal(e.value.Interface())
+		if err != nil {
+			return "", nil
+		}
+		switch {
+		case pg && t.Path == "":
+			return col + "::jsonb @> ?::jsonb", []interface{}{string(v)}
+		case pg:
+			return col + "::jsonb #> ?::text[] @> ?::jsonb", []interface{}{pgJsonPath(t.Path), string(v)}
+		case t.Path == "":
+			return "JSON_CONTAINS(" + col + ", ?)", []interface{}{string(v)}
+		default:
+			return "JSON_CONTAINS(" + col + ", ?, ?)", []interface{}{string(v), t.Path}
+		}
+	case "jsonexact":
+		if pg {
+			return col + "::jsonb #>> ?::text[] = ?", []interface{}{pgJsonPath(t.Path), fmt.Sprint(e.value.Interface())}
+		}
+		return "JSON_UNQUOTE(JSON_EXTRACT(" + col + ", ?)) = ?", []interface{}{t.Path, fmt.Sprint(e.value.Interface())}
+	}
+	return "", nil
+}
@@ -0,0 +1,196 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type CompileOrder struct {
+	Id     int
+	Name   string
+	Status int
+	Amount int
+}
+
+type CompileKeyword struct {
+	Name string `search:"type:icontains;column:name;table:orders"`
+	Code string `search:"type:exact;column:code;table:orders"`
+}
+
+type CompileQuery struct {
+	Status  []int          `search:"type:in;column:status;table:orders"`
+	Keyword CompileKeyword `search:"type:or"`
+	Amount  []int          `search:"type:between;column:amount;table:orders"`
+	Goods   struct {
+		Sku string `search:"type:exact;column:sku;table:goods"`
+	} `search:"type:left;on:order_id:id;table:orders;join:goods"`
+	IdOrder string `search:"type:order;column:id;table:orders"`
+	Sort    string `search:"type:sort;table:orders;columns:name,amount"`
+}
+
+func newDryRunDB(t *testing.T, driver string) *gorm.DB {
+	var dialector gorm.Dialector
+	switch driver {
+	case Mysql:
+		dialector = mysql.New(mysql.Config{DSN: "root@tcp(127.0.0.1:3306)/test", SkipInitializeWithVersion: true})
+	case Postgres:
+		dialector = postgres.New(postgres.Config{DSN: "host=127.0.0.1 user=root dbname=test"})
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCompile(t *testing.T) {
+	q := CompileQuery{
+		Status:  []int{1, 2},
+		Keyword: CompileKeyword{Name: "n", Code: "c"},
+		Amount:  []int{10, 20},
+		IdOrder: "desc",
+		Sort:    "-amount, name asc",
+	}
+	q.Goods.Sku = "s"
+	tests := []struct {
+		name   string
+		driver string
+		opts   []Option
+		sql    string
+		vars   []interface{}
+	}{
+		{
+			name:   "mysql",
+			driver: Mysql,
+			opts:   []Option{WithPagination(2, 10)},
+			sql: "SELECT `compile_orders`.`id`,`compile_orders`.`name`,`compile_orders`.`status`,`compile_orders`.`amount` FROM `compile_orders` " +
+				"left join `goods` on `goods`.`order_id` = `orders`.`id` " +
+				"WHERE `orders`.`status` in (?,?) AND (`orders`.`name` like ? OR `orders`.`code` = ?) AND (`orders`.`amount` between ? and ?) AND `goods`.`sku` = ? " +
+				"ORDER BY `orders`.`id` DESC,`orders`.`amount` DESC,`orders`.`name` LIMIT 10 OFFSET 10",
+			vars: []interface{}{1, 2, "%n%", "c", 10, 20, "s"},
+		},
+		{
+			name:   "postgres",
+			driver: Postgres,
+			opts:   []Option{WithPagination(0, 5000), WithMaxPageSize(100)},
+			sql: `SELECT "compile_orders"."id","compile_orders"."name","compile_orders"."status","compile_orders"."amount" FROM "compile_orders" ` +
+				`left join "goods" on "goods"."order_id" = "orders"."id" ` +
+				`WHERE "orders"."status" in ($1,$2) AND ("orders"."name" ilike $3 OR "orders"."code" = $4) AND ("orders"."amount" between $5 and $6) AND "goods"."sku" = $7 ` +
+				`ORDER BY "orders"."id" DESC,"orders"."amount" DESC,"orders"."name" LIMIT 100`,
+			vars: []interface{}{1, 2, "%n%", "c", 10, 20, "s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := Compile(q, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stmt := newDryRunDB(t, tt.driver).Scopes(scope).Find(&[]CompileOrder{}).Statement
+			if got := stmt.SQL.String(); got != tt.sql {
+				t.Errorf("sql = %s\nwant  %s", got, tt.sql)
+			}
+			if !reflect.DeepEqual(stmt.Vars, tt.vars) {
+				t.Errorf("vars = %v, want %v", stmt.Vars, tt.vars)
+			}
+		})
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	type unknownType struct {
+		Name string `search:"type:like;column:name"`
+	}
+	type badColumn struct {
+		Name string `search:"type:exact;column:name;table:orders where 1=1"`
+	}
+	tests := []struct {
+		name  string
+		query interface{}
+		order bool
+	}{
+		{name: "not struct", query: 1},
+		{name: "unknown type", query: unknownType{Name: "a"}},
+		{name: "bad column", query: badColumn{Name: "a"}},
+		{name: "sort not in whitelist", query: CompileQuery{Sort: "status"}, order: true},
+		{name: "sort injection", query: CompileQuery{Sort: "name;drop table orders"}, order: true},
+		{name: "sort bad direction", query: CompileQuery{Sort: "name sideways"}, order: true},
+		{name: "order bad direction", query: CompileQuery{IdOrder: "id desc"}, order: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.query)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.order != errors.Is(err, ErrInvalidOrder) {
+				t.Errorf("err = %v", err)
+			}
+		})
+	}
+}
+
+func TestCompile_PlanCache(t *testing.T) {
+	if _, err := Compile(&CompileQuery{}); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := plans.Load(reflect.TypeOf(CompileQuery{}))
+	if !ok {
+		t.Fatal("plan not cached")
+	}
+	if loadPlan(reflect.TypeOf(CompileQuery{})) != p.(*plan) {
+		t.Error("plan rebuilt")
+	}
+}
+
+func TestCompile_Sqlite(t *testing.T) {
+	type query struct {
+		Status  int `search:"type:exact;column:status;table:compile_orders"`
+		Keyword struct {
+			Name   string `search:"type:startswith;column:name;table:compile_orders"`
+			Amount int    `search:"type:gte;column:amount;table:compile_orders"`
+		} `search:"type:or"`
+		Sort string `search:"type:sort;table:compile_orders;columns:amount"`
+	}
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err = db.AutoMigrate(&CompileOrder{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&[]CompileOrder{
+		{Name: "apple", Status: 1, Amount: 5},
+		{Name: "banana", Status: 1, Amount: 50},
+		{Name: "cherry", Status: 1, Amount: 1},
+		{Name: "avocado", Status: 2, Amount: 100},
+	})
+
+	q := query{Status: 1, Sort: "-amount"}
+	q.Keyword.Name = "a"
+	q.Keyword.Amount = 10
+	scope, err := Compile(q, WithPagination(1, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []CompileOrder
+	if err = db.Scopes(scope).Find(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(list))
+	for _, o := range list {
+		names = append(names, o.Name)
+	}
+	if want := []string{"banana", "apple"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
@@ -24,10 +24,15 @@ type GormJoin struct {
 	Type   string
 	JoinOn string
 	GormPublic
+	root *GormCondition
 }
 
+// SetJoinOn 嵌套关联统一追加到根条件, 保证按声明顺序拼接join
 func (e *GormJoin) SetJoinOn(t, on string) Condition {
-	return nil
+	if e.root == nil {
+		return nil
+	}
+	return e.root.SetJoinOn(t, on)
 }
 
 func (e *GormPublic) SetWhere(k string, v []interface{}) {
@@ -59,17 +64,20 @@ func (e *GormCondition) SetJoinOn(t, on string) Condition {
 		Type:       t,
 		JoinOn:     on,
 		GormPublic: GormPublic{},
+		root:       e,
 	}
 	e.Join = append(e.Join, join)
 	return join
 }
 
 type resolveSearchTag struct {
-	Type   string
-	Column string
-	Table  string
-	On     []string
-	Join   string
+	Type    string
+	Column  string
+	Table   string
+	On      []string
+	Join    string
+	Path    string
+	Columns []string
 }
 
 // makeTag 解析search的tag标签
@@ -103,7 +111,56 @@ func makeTag(tag string) *resolveSearchTag {
 			if len(ts) > 1 {
 				r.Join = ts[1]
 			}
+		case "path":
+			if len(ts) > 1 {
+				r.Path = ts[1]
+			}
+		case "columns":
+			if len(ts) > 1 {
+				r.Columns = strings.Split(ts[1], ",")
+			}
 		}
 	}
 	return r
 }
+
+// groupCondition 收集嵌套结构体的条件, 以and/or连接成一个带括号的条件写入parent
+type groupCondition struct {
+	parent Condition
+	sep    string
+	keys   []string
+	values []interface{}
+}
+
+func newGroupCondition(parent Condition, t string) *groupCondition {
+	sep := " AND "
+	if t == "or" {
+		sep = " OR "
+	}
+	return &groupCondition{parent: parent, sep: sep}
+}
+
+func (e *groupCondition) SetWhere(k string, v []interface{}) {
+	e.keys = append(e.keys, k)
+	e.values = append(e.values, v...)
+}
+
+func (e *groupCondition) SetOr(k string, v []interface{}) {
+	e.SetWhere(k, v)
+}
+
+func (e *groupCondition) SetOrder(k string) {
+	e.parent.SetOrder(k)
+}
+
+func (e *groupCondition) SetJoinOn(t, on string) Condition {
+	return e.parent.SetJoinOn(t, on)
+}
+
+// flush 将分组条件写入parent, 空分组忽略
+func (e *groupCondition) flush() {
+	if len(e.keys) == 0 {
+		return
+	}
+	e.parent.SetWhere("("+strings.Join(e.keys, e.sep)+")", e.values)
+}
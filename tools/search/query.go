@@ -1,6 +1,7 @@
 package search
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -23,8 +24,14 @@ const (
  *	lt / lte 小于 / 小于等于
  *	startswith / istartswith 以…起始
  *	endswith / iendswith 以…结束
- *	in
- *	isnull
+ *	ne / glt 不等于
+ *	in / notin
+ *	between 区间, 字段为两个元素的切片或数组
+ *	isnull / isnotnull
+ *	jsoncontains JSON包含, 可用path指定JSON路径 e.g. path:$.tags
+ *	jsonexact JSON路径等于, 需指定path
+ *	left / inner / right 关联, 可嵌套
+ *	or / and 条件分组, 嵌套结构体内的条件以or/and连接, 可嵌套
  *  order 排序		e.g. order[key]=desc     order[key]=asc
  */
 func ResolveSearchQuery(driver string, q interface{}, condition Condition) {
@@ -44,7 +51,9 @@ func ResolveSearchQuery(driver string, q interface{}, condition Condition) {
 		tag, ok = qType.Field(i).Tag.Lookup(FromQueryTag)
 		if !ok {
 			//递归调用
-			ResolveSearchQuery(driver, qValue.Field(i).Interface(), condition)
+			if qValue.Field(i).Kind() == reflect.Struct {
+				ResolveSearchQuery(driver, qValue.Field(i).Interface(), condition)
+			}
 			continue
 		}
 		switch tag {
@@ -55,6 +64,14 @@ func ResolveSearchQuery(driver string, q interface{}, condition Condition) {
 		if qValue.Field(i).IsZero() {
 			continue
 		}
+		switch t.Type {
+		case "or", "and":
+			//条件分组
+			group := newGroupCondition(condition, t.Type)
+			ResolveSearchQuery(driver, qValue.Field(i).Interface(), group)
+			group.flush()
+			continue
+		}
 		//解析 Postgres `语法不支持，单独适配
 		if driver == Postgres {
 			pgSql(driver, t, condition, qValue, i)
@@ -66,15 +83,15 @@ func ResolveSearchQuery(driver string, q interface{}, condition Condition) {
 
 func pgSql(driver string, t *resolveSearchTag, condition Condition, qValue reflect.Value, i int) {
 	switch t.Type {
-	case "left":
-		//左关联
+	case "left", "inner", "right":
+		//关联
 		join := condition.SetJoinOn(t.Type, fmt.Sprintf(
-			"left join %s on %s.%s = %s.%s", t.Join, t.Join, t.On[0], t.Table, t.On[1],
+			"%s join %s on %s.%s = %s.%s", t.Type, t.Join, t.Join, t.On[0], t.Table, t.On[1],
 		))
 		ResolveSearchQuery(driver, qValue.Field(i).Interface(), join)
 	case "exact", "iexact":
 		condition.SetWhere(fmt.Sprintf("%s.%s = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
-	case "glt":
+	case "ne", "glt":
 		condition.SetWhere(fmt.Sprintf("%s.%s <> ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
 	case "icontains":
 		condition.SetWhere(fmt.Sprintf("%s.%s ilike ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String() + "%"})
@@ -100,10 +117,35 @@ func pgSql(driver string, t *resolveSearchTag, condition Condition, qValue refle
 		condition.SetWhere(fmt.Sprintf("%s.%s like ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String()})
 	case "in":
 		condition.SetWhere(fmt.Sprintf("%s.%s in (?)", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+	case "notin":
+		condition.SetWhere(fmt.Sprintf("%s.%s not in (?)", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+	case "between":
+		if v, ok := betweenValues(qValue.Field(i)); ok {
+			condition.SetWhere(fmt.Sprintf("%s.%s between ? and ?", t.Table, t.Column), v)
+		}
 	case "isnull":
 		if !(qValue.Field(i).IsZero() && qValue.Field(i).IsNil()) {
 			condition.SetWhere(fmt.Sprintf("%s.%s isnull", t.Table, t.Column), make([]interface{}, 0))
 		}
+	case "isnotnull":
+		condition.SetWhere(fmt.Sprintf("%s.%s is not null", t.Table, t.Column), make([]interface{}, 0))
+	case "jsoncontains":
+		v, err := json.Marshal(qValue.Field(i).Interface())
+		if err != nil {
+			return
+		}
+		if t.Path == "" {
+			condition.SetWhere(fmt.Sprintf("%s.%s::jsonb @> ?::jsonb", t.Table, t.Column), []interface{}{string(v)})
+			return
+		}
+		condition.SetWhere(fmt.Sprintf("%s.%s::jsonb #> ?::text[] @> ?::jsonb", t.Table, t.Column),
+			[]interface{}{pgJsonPath(t.Path), string(v)})
+	case "jsonexact":
+		if t.Path == "" {
+			return
+		}
+		condition.SetWhere(fmt.Sprintf("%s.%s::jsonb #>> ?::text[] = ?", t.Table, t.Column),
+			[]interface{}{pgJsonPath(t.Path), fmt.Sprint(qValue.Field(i).Interface())})
 	case "order":
 		switch strings.ToLower(qValue.Field(i).String()) {
 		case "desc", "asc":
@@ -114,10 +156,11 @@ func pgSql(driver string, t *resolveSearchTag, condition Condition, qValue refle
 
 func otherSql(driver string, t *resolveSearchTag, condition Condition, qValue reflect.Value, i int) {
 	switch t.Type {
-	case "left":
-		//左关联
+	case "left", "inner", "right":
+		//关联
 		join := condition.SetJoinOn(t.Type, fmt.Sprintf(
-			"left join `%s` on `%s`.`%s` = `%s`.`%s`",
+			"%s join `%s` on `%s`.`%s` = `%s`.`%s`",
+			t.Type,
 			t.Join,
 			t.Join,
 			t.On[0],
@@ -127,6 +170,8 @@ func otherSql(driver string, t *resolveSearchTag, condition Condition, qValue re
 		ResolveSearchQuery(driver, qValue.Field(i).Interface(), join)
 	case "exact", "iexact":
 		condition.SetWhere(fmt.Sprintf("`%s`.`%s` = ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+	case "ne", "glt":
+		condition.SetWhere(fmt.Sprintf("`%s`.`%s` <> ?", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
 	case "contains", "icontains":
 		condition.SetWhere(fmt.Sprintf("`%s`.`%s` like ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String() + "%"})
 	case "containspath":
@@ -145,10 +190,34 @@ func otherSql(driver string, t *resolveSearchTag, condition Condition, qValue re
 		condition.SetWhere(fmt.Sprintf("`%s`.`%s` like ?", t.Table, t.Column), []interface{}{"%" + qValue.Field(i).String()})
 	case "in":
 		condition.SetWhere(fmt.Sprintf("`%s`.`%s` in (?)", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+	case "notin":
+		condition.SetWhere(fmt.Sprintf("`%s`.`%s` not in (?)", t.Table, t.Column), []interface{}{qValue.Field(i).Interface()})
+	case "between":
+		if v, ok := betweenValues(qValue.Field(i)); ok {
+			condition.SetWhere(fmt.Sprintf("`%s`.`%s` between ? and ?", t.Table, t.Column), v)
+		}
 	case "isnull":
 		if !(qValue.Field(i).IsZero() && qValue.Field(i).IsNil()) {
-			condition.SetWhere(fmt.Sprintf("`%s`.`%s` isnull", t.Table, t.Column), make([]interface{}, 0))
+			condition.SetWhere(fmt.Sprintf("`%s`.`%s` is null", t.Table, t.Column), make([]interface{}, 0))
+		}
+	case "isnotnull":
+		condition.SetWhere(fmt.Sprintf("`%s`.`%s` is not null", t.Table, t.Column), make([]interface{}, 0))
+	case "jsoncontains":
+		v, err := json.Marshal(qValue.Field(i).Interface())
+		if err != nil {
+			return
 		}
+		if t.Path == "" {
+			condition.SetWhere(fmt.Sprintf("JSON_CONTAINS(`%s`.`%s`, ?)", t.Table, t.Column), []interface{}{string(v)})
+			return
+		}
+		condition.SetWhere(fmt.Sprintf("JSON_CONTAINS(`%s`.`%s`, ?, ?)", t.Table, t.Column), []interface{}{string(v), t.Path})
+	case "jsonexact":
+		if t.Path == "" {
+			return
+		}
+		condition.SetWhere(fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(`%s`.`%s`, ?)) = ?", t.Table, t.Column),
+			[]interface{}{t.Path, fmt.Sprint(qValue.Field(i).Interface())})
 	case "order":
 		switch strings.ToLower(qValue.Field(i).String()) {
 		case "desc", "asc":
@@ -156,3 +225,22 @@ func otherSql(driver string, t *resolveSearchTag, condition Condition, qValue re
 		}
 	}
 }
+
+// betweenValues 取区间的上下限, 字段需为两个元素的切片或数组
+func betweenValues(v reflect.Value) ([]interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, false
+	}
+	if v.Len() != 2 {
+		return nil, false
+	}
+	return []interface{}{v.Index(0).Interface(), v.Index(1).Interface()}, true
+}
+
+// pgJsonPath 将 $.a.b 形式的路径转换为postgres的 {a,b}
+func pgJsonPath(path string) string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	return "{" + strings.ReplaceAll(path, ".", ",") + "}"
+}
@@ -2,6 +2,7 @@ package search
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -49,3 +50,201 @@ func TestResolveSearchQuery(t *testing.T) {
 		fmt.Println(condition)
 	})
 }
+
+type OperatorQuery struct {
+	Status   int      `search:"type:ne;column:status;table:orders"`
+	Ids      []int    `search:"type:notin;column:id;table:orders"`
+	Amount   []int    `search:"type:between;column:amount;table:orders"`
+	PaidAt   bool     `search:"type:isnotnull;column:paid_at;table:orders"`
+	Tags     []string `search:"type:jsoncontains;column:tags;table:orders"`
+	Level    int      `search:"type:jsoncontains;column:extra;table:orders;path:$.levels"`
+	City     string   `search:"type:jsonexact;column:extra;table:orders;path:$.address.city"`
+	Pagesize int
+}
+
+type KeywordGroup struct {
+	Name  string `search:"type:contains;column:name;table:orders"`
+	Phone string `search:"type:exact;column:phone;table:orders"`
+	AmountGroup
+}
+
+type AmountGroup struct {
+	Min int `search:"type:gte;column:amount;table:orders"`
+	Max int `search:"type:lte;column:amount;table:orders"`
+}
+
+type GroupQuery struct {
+	Status  int          `search:"type:exact;column:status;table:orders"`
+	Keyword KeywordGroup `search:"type:or"`
+}
+
+type NestedGroupQuery struct {
+	Keyword struct {
+		Name   string      `search:"type:exact;column:name;table:orders"`
+		Amount AmountGroup `search:"type:and"`
+	} `search:"type:or"`
+}
+
+type GoodsJoin struct {
+	Sku       string `search:"type:exact;column:sku;table:goods"`
+	SkuVendor struct {
+		Vendor string `search:"type:exact;column:name;table:vendors"`
+	} `search:"type:right;on:id:vendor_id;table:goods;join:vendors"`
+}
+
+type JoinQuery struct {
+	Goods GoodsJoin `search:"type:inner;on:order_id:id;table:orders;join:goods"`
+}
+
+func TestResolveSearchQuery_Dialect(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		query  interface{}
+		where  map[string][]interface{}
+		joins  []*GormJoin
+	}{
+		{
+			name:   "mysql operators",
+			driver: Mysql,
+			query: OperatorQuery{
+				Status: 1, Ids: []int{1, 2}, Amount: []int{10, 20}, PaidAt: true,
+				Tags: []string{"a"}, Level: 3, City: "sh", Pagesize: 10,
+			},
+			where: map[string][]interface{}{
+				"`orders`.`status` <> ?":                              {1},
+				"`orders`.`id` not in (?)":                            {[]int{1, 2}},
+				"`orders`.`amount` between ? and ?":                   {10, 20},
+				"`orders`.`paid_at` is not null":                      {},
+				"JSON_CONTAINS(`orders`.`tags`, ?)":                   {`["a"]`},
+				"JSON_CONTAINS(`orders`.`extra`, ?, ?)":               {"3", "$.levels"},
+				"JSON_UNQUOTE(JSON_EXTRACT(`orders`.`extra`, ?)) = ?": {"$.address.city", "sh"},
+			},
+		},
+		{
+			name:   "postgres operators",
+			driver: Postgres,
+			query: OperatorQuery{
+				Status: 1, Ids: []int{1, 2}, Amount: []int{10, 20}, PaidAt: true,
+				Tags: []string{"a"}, Level: 3, City: "sh",
+			},
+			where: map[string][]interface{}{
+				"orders.status <> ?":                           {1},
+				"orders.id not in (?)":                         {[]int{1, 2}},
+				"orders.amount between ? and ?":                {10, 20},
+				"orders.paid_at is not null":                   {},
+				"orders.tags::jsonb @> ?::jsonb":               {`["a"]`},
+				"orders.extra::jsonb #> ?::text[] @> ?::jsonb": {"{levels}", "3"},
+				"orders.extra::jsonb #>> ?::text[] = ?":        {"{address,city}", "sh"},
+			},
+		},
+		{
+			name:   "between needs two values",
+			driver: Mysql,
+			query:  OperatorQuery{Amount: []int{10}},
+			where:  nil,
+		},
+		{
+			name:   "mysql or group",
+			driver: Mysql,
+			query: GroupQuery{
+				Status:  1,
+				Keyword: KeywordGroup{Name: "n", Phone: "p", AmountGroup: AmountGroup{Min: 1}},
+			},
+			where: map[string][]interface{}{
+				"`orders`.`status` = ?": {1},
+				"(`orders`.`name` like ? OR `orders`.`phone` = ? OR `orders`.`amount` >= ?)": {"%n%", "p", 1},
+			},
+		},
+		{
+			name:   "postgres nested and in or",
+			driver: Postgres,
+			query: func() NestedGroupQuery {
+				q := NestedGroupQuery{}
+				q.Keyword.Name = "n"
+				q.Keyword.Amount = AmountGroup{Min: 1, Max: 9}
+				return q
+			}(),
+			where: map[string][]interface{}{
+				"(orders.name = ? OR (orders.amount >= ? AND orders.amount <= ?))": {"n", 1, 9},
+			},
+		},
+		{
+			name:   "mysql nested joins",
+			driver: Mysql,
+			query: func() JoinQuery {
+				q := JoinQuery{}
+				q.Goods.Sku = "s"
+				q.Goods.SkuVendor.Vendor = "v"
+				return q
+			}(),
+			joins: []*GormJoin{
+				{
+					Type:       "inner",
+					JoinOn:     "inner join `goods` on `goods`.`order_id` = `orders`.`id`",
+					GormPublic: GormPublic{Where: map[string][]interface{}{"`goods`.`sku` = ?": {"s"}}},
+				},
+				{
+					Type:       "right",
+					JoinOn:     "right join `vendors` on `vendors`.`id` = `goods`.`vendor_id`",
+					GormPublic: GormPublic{Where: map[string][]interface{}{"`vendors`.`name` = ?": {"v"}}},
+				},
+			},
+		},
+		{
+			name:   "postgres nested joins",
+			driver: Postgres,
+			query: func() JoinQuery {
+				q := JoinQuery{}
+				q.Goods.SkuVendor.Vendor = "v"
+				return q
+			}(),
+			joins: []*GormJoin{
+				{
+					Type:   "inner",
+					JoinOn: "inner join goods on goods.order_id = orders.id",
+				},
+				{
+					Type:       "right",
+					JoinOn:     "right join vendors on vendors.id = goods.vendor_id",
+					GormPublic: GormPublic{Where: map[string][]interface{}{"vendors.name = ?": {"v"}}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := &GormCondition{}
+			ResolveSearchQuery(tt.driver, tt.query, condition)
+			if len(condition.Where) != len(tt.where) {
+				t.Fatalf("where = %v, want %v", condition.Where, tt.where)
+			}
+			for k, v := range tt.where {
+				got, ok := condition.Where[k]
+				if !ok {
+					t.Fatalf("missing where %q in %v", k, condition.Where)
+				}
+				if len(got) != len(v) || (len(v) > 0 && !reflect.DeepEqual(got, v)) {
+					t.Errorf("where %q args = %v, want %v", k, got, v)
+				}
+			}
+			if len(condition.Join) != len(tt.joins) {
+				t.Fatalf("joins = %d, want %d", len(condition.Join), len(tt.joins))
+			}
+			for i, want := range tt.joins {
+				got := condition.Join[i]
+				if got.Type != want.Type || got.JoinOn != want.JoinOn {
+					t.Errorf("join[%d] = %s %q, want %s %q", i, got.Type, got.JoinOn, want.Type, want.JoinOn)
+				}
+				if len(got.Where) != len(want.Where) {
+					t.Errorf("join[%d] where = %v, want %v", i, got.Where, want.Where)
+				}
+				for k, v := range want.Where {
+					if !reflect.DeepEqual(got.Where[k], v) {
+						t.Errorf("join[%d] where %q = %v, want %v", i, k, got.Where[k], v)
+					}
+				}
+			}
+		})
+	}
+}